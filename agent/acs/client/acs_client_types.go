@@ -17,6 +17,7 @@ import (
 	"reflect"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	wsclient "github.com/aws/amazon-ecs-agent/agent/websocket/client"
 )
 
 var acsTypeMappings map[string]reflect.Type
@@ -53,9 +54,21 @@ func init() {
 // typeMappings implements wsclient.TypeMappings.
 type typeMappings struct{}
 
+// NewTypeMappings returns the wsclient.TypeMappings of the messages
+// exchanged with ACS
+func NewTypeMappings() wsclient.TypeMappings {
+	return &typeMappings{}
+}
+
 // decoder implments wsclient.TypeDecoder.
 type decoder struct{}
 
+// NewDecoder returns a wsclient.TypeDecoder of the messages exchanged with
+// ACS
+func NewDecoder() wsclient.TypeDecoder {
+	return &decoder{}
+}
+
 func (dc *decoder) NewOfType(acsType string) (interface{}, bool) {
 	rtype, ok := acsTypeMappings[acsType]
 	if !ok {
@@ -29,6 +29,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/version"
@@ -41,10 +42,18 @@ var log = logger.ForModule("acs handler")
 const heartbeatTimeout = 5 * time.Minute
 const heartbeatJitter = 3 * time.Minute
 
+var (
+	acsConnected = metrics.NewGauge("ecs_agent_acs_connected",
+		"Whether the agent is connected to ACS.")
+	acsReconnects = metrics.NewCounter("ecs_agent_acs_reconnects_total",
+		"Connections to ACS made after the first.")
+)
+
 // StartSession creates a session with ACS and handles requests using the passed
 // in arguments.
 func StartSession(containerInstanceArn string, credentialProvider credentials.AWSCredentialProvider, cfg *config.Config, taskEngine engine.TaskEngine, ecsclient api.ECSClient, stateManager statemanager.StateManager, acceptInvalidCert bool) error {
 	backoff := utils.NewSimpleBackoff(time.Second, 1*time.Minute, 0.2, 2)
+	hasConnected := false
 	return utils.RetryWithBackoff(backoff, func() error {
 		acsEndpoint, err := ecsclient.DiscoverPollEndpoint(containerInstanceArn)
 		if err != nil {
@@ -66,6 +75,12 @@ func StartSession(containerInstanceArn string, credentialProvider credentials.AW
 			log.Error("Error connecting to ACS: " + err.Error())
 			return err
 		}
+		if hasConnected {
+			acsReconnects.Inc()
+		}
+		hasConnected = true
+		acsConnected.Set(1)
+		defer acsConnected.Set(0)
 		return client.Serve()
 	})
 }
@@ -134,6 +149,7 @@ func payloadMessageHandler(cs wsclient.ClientServer, cluster, containerInstanceA
 				return
 			}
 			// Else, no error converting, add to engine
+			apiTask.ReceivedAt = time.Now()
 			err = taskEngine.AddTask(apiTask)
 			if err != nil {
 				log.Warn("Could not add task; taskengine probably disabled")
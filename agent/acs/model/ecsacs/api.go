@@ -280,6 +280,8 @@ type Task struct {
 
 	Overrides *string `locationName:"overrides" type:"string"`
 
+	RoleArn *string `locationName:"roleArn" type:"string"`
+
 	TaskDefinitionAccountId *string `locationName:"taskDefinitionAccountId" type:"string"`
 
 	Version *string `locationName:"version" type:"string"`
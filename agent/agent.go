@@ -22,13 +22,17 @@ import (
 	acshandler "github.com/aws/amazon-ecs-agent/agent/acs/handler"
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/attributes"
 	"github.com/aws/amazon-ecs-agent/agent/auth"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/ec2"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/hooks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/tasktransformer"
 	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
 	"github.com/aws/amazon-ecs-agent/agent/handlers"
+	"github.com/aws/amazon-ecs-agent/agent/hostid"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
@@ -68,15 +72,61 @@ func main() {
 		os.Exit(exitcodes.ExitSuccess)
 	}
 
+	if err := api.ValidateInstanceAttributes(cfg.InstanceAttributes); err != nil {
+		log.Crit("Invalid instance attributes", "err", err)
+		os.Exit(exitcodes.ExitTerminal)
+	}
+
+	if _, err := tasktransformer.NewChain(cfg.TaskTransformers); err != nil {
+		log.Crit("Invalid task transformer configuration", "err", err, "available", tasktransformer.Registered())
+		os.Exit(exitcodes.ExitTerminal)
+	}
+	if _, err := hooks.NewRunner(cfg.LifecycleHooks); err != nil {
+		log.Crit("Invalid lifecycle hook configuration", "err", err)
+		os.Exit(exitcodes.ExitTerminal)
+	}
+	if err := handlers.CheckIntrospectionConfig(cfg); err != nil {
+		log.Crit("Invalid introspection API configuration", "err", err)
+		os.Exit(exitcodes.ExitTerminal)
+	}
+
+	if cfg.Checkpoint {
+		// Keep offline tools from editing the state file while the agent
+		// runs. The lock is held until the agent exits.
+		dataDirLock, err := statemanager.LockDataDir(cfg.DataDir)
+		if err != nil {
+			log.Crit("Unable to lock the data directory", "dataDir", cfg.DataDir, "err", err)
+			os.Exit(exitcodes.ExitTerminal)
+		}
+		defer dataDirLock.Close()
+	}
+
+	if cfg.StandaloneTaskDir != "" {
+		runStandalone(cfg, log)
+		return
+	}
+
+	dockerClient, err := engine.NewDockerGoClient()
+	if err != nil {
+		log.Warn("Docker is unavailable; Docker attributes will not be detected", "err", err)
+	}
+	discoveredAttributes := attributes.NewDefaultDiscoverer(cfg, dockerClient).Discover()
+	log.Info("Discovered instance attributes", "attributes", discoveredAttributes)
+	cfg.InstanceAttributes = attributes.Merge(cfg.InstanceAttributes, discoveredAttributes)
+
 	var currentEc2InstanceID, containerInstanceArn string
 	var taskEngine engine.TaskEngine
+	// registeredAttributes are the instance attributes the backend is known to
+	// have for our containerInstanceArn
+	var registeredAttributes map[string]string
 
 	if cfg.Checkpoint {
 		var previousCluster, previousEc2InstanceID, previousContainerInstanceArn string
+		var previousAttributes map[string]string
 		previousTaskEngine := engine.NewTaskEngine(cfg)
 		// previousState is used to verify that our current runtime configuration is
 		// compatible with our past configuration as reflected by our state-file
-		previousState, err := initializeStateManager(cfg, previousTaskEngine, &previousCluster, &previousContainerInstanceArn, &previousEc2InstanceID)
+		previousState, err := initializeStateManager(cfg, previousTaskEngine, &previousCluster, &previousContainerInstanceArn, &previousEc2InstanceID, &previousAttributes)
 		if err != nil {
 			log.Crit("Error creating state manager", "err", err)
 			os.Exit(exitcodes.ExitTerminal)
@@ -102,7 +152,15 @@ func main() {
 			log.Info("Restored cluster", "cluster", cfg.Cluster)
 		}
 
-		if instanceIdentityDoc, err := ec2.GetInstanceIdentityDocument(); err == nil {
+		if cfg.ExternalInstance {
+			// External hosts have no instance ID; a generated, persistent host
+			// ID takes its place
+			currentEc2InstanceID, err = hostid.LoadOrCreate(cfg.DataDir)
+			if err != nil {
+				log.Crit("Unable to load or create host ID for external instance", "dataDir", cfg.DataDir, "err", err)
+				os.Exit(exitcodes.ExitTerminal)
+			}
+		} else if instanceIdentityDoc, err := ec2.GetInstanceIdentityDocument(); err == nil {
 			currentEc2InstanceID = instanceIdentityDoc.InstanceId
 		} else {
 			log.Crit("Unable to access EC2 Metadata service to determine EC2 ID", "err", err)
@@ -116,6 +174,7 @@ func main() {
 		} else {
 			// Use the values we loaded if there's no issue
 			containerInstanceArn = previousContainerInstanceArn
+			registeredAttributes = previousAttributes
 			taskEngine = previousTaskEngine
 		}
 	} else {
@@ -123,13 +182,16 @@ func main() {
 		taskEngine = engine.NewTaskEngine(cfg)
 	}
 
-	stateManager, err := initializeStateManager(cfg, taskEngine, &cfg.Cluster, &containerInstanceArn, &currentEc2InstanceID)
+	stateManager, err := initializeStateManager(cfg, taskEngine, &cfg.Cluster, &containerInstanceArn, &currentEc2InstanceID, &registeredAttributes)
 	if err != nil {
 		log.Crit("Error creating state manager", "err", err)
 		os.Exit(exitcodes.ExitTerminal)
 	}
 
 	credentialProvider := auth.NewBasicAWSCredentialProvider()
+	if cfg.ExternalInstance {
+		credentialProvider = auth.NewExternalAWSCredentialProvider()
+	}
 	client := api.NewECSClient(credentialProvider, cfg, *acceptInsecureCert)
 
 	if containerInstanceArn == "" {
@@ -143,26 +205,29 @@ func main() {
 			os.Exit(exitcodes.ExitError)
 		}
 		log.Info("Registration completed successfully", "containerInstance", containerInstanceArn, "cluster", cfg.Cluster)
+		registeredAttributes = cfg.InstanceAttributes
 		// Save our shiny new containerInstanceArn
 		stateManager.Save()
 	} else {
 		log.Info("Restored state", "containerInstance", containerInstanceArn, "cluster", cfg.Cluster)
+		if reconcileInstanceAttributes(client, containerInstanceArn, registeredAttributes, cfg.InstanceAttributes, log) {
+			registeredAttributes = cfg.InstanceAttributes
+			stateManager.Save()
+		}
 	}
 
-	// Begin listening to the docker daemon and saving changes
-	taskEngine.SetSaver(stateManager)
-	taskEngine.MustInit()
-
-	go sighandlers.StartTerminationHandler(stateManager, taskEngine)
+	startEngine(cfg, taskEngine, stateManager, containerInstanceArn)
 
-	// Agent introspection api
-	go handlers.ServeHttp(&containerInstanceArn, taskEngine, cfg)
+	// The stats engine is shared by the metrics session and the agent
+	// introspection api
+	statsEngine := newStatsEngine(taskEngine, &cfg.Cluster, &containerInstanceArn, log)
+	serveIntrospection(cfg, &containerInstanceArn, taskEngine, statsEngine)
 
 	// Start sending events to the backend
 	go eventhandler.HandleEngineEvents(taskEngine, client, stateManager)
 
 	// Start metrics session in a go routine
-	go startMetricsSession(containerInstanceArn, credentialProvider, cfg, true, log, taskEngine)
+	go startMetricsSession(containerInstanceArn, credentialProvider, cfg, true, log, statsEngine)
 
 	log.Info("Beginning Polling for updates")
 	err = acshandler.StartSession(containerInstanceArn, credentialProvider, cfg, taskEngine, client, stateManager, *acceptInsecureCert)
@@ -172,15 +237,53 @@ func main() {
 	}
 }
 
-func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, cluster, containerInstanceArn, savedInstanceID *string) (statemanager.StateManager, error) {
+// startEngine initializes the task engine, restoring the state of tasks
+// which changed while the agent was down, and starts handling signals
+func startEngine(cfg *config.Config, taskEngine engine.TaskEngine, stateManager statemanager.StateManager, containerInstanceArn string) {
+	// Dump diagnostics on SIGUSR1 from here on, in case initialization wedges
+	go sighandlers.StartDiagnosticHandler(cfg, taskEngine)
+
+	// Begin listening to the docker daemon and saving changes
+	taskEngine.SetContainerInstanceArn(containerInstanceArn)
+	taskEngine.SetSaver(stateManager)
+	taskEngine.MustInit()
+
+	go sighandlers.StartTerminationHandler(cfg, stateManager, taskEngine)
+	if drainer, ok := taskEngine.(sighandlers.Drainer); ok {
+		// Start draining before any new tasks can arrive, if configured to
+		if cfg.Draining {
+			drainer.Drain(cfg.DrainTimeout)
+		}
+		go sighandlers.StartDrainHandler(drainer, cfg.DrainTimeout)
+	}
+}
+
+// serveIntrospection starts the introspection API, along with its admin
+// socket and the task metadata server if they are enabled
+func serveIntrospection(cfg *config.Config, containerInstanceArn *string, taskEngine engine.TaskEngine, statsEngine stats.Engine) {
+	go handlers.ServeHttp(containerInstanceArn, taskEngine, statsEngine, cfg)
+	if cfg.IntrospectionAdminSocket != "" {
+		go handlers.ServeAdminHttp(taskEngine, cfg)
+	}
+	if cfg.TaskMetadataEnabled || cfg.TaskIAMRoleEnabled {
+		go handlers.ServeTaskMetadataHttp(taskEngine, cfg)
+	}
+}
+
+func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, cluster, containerInstanceArn, savedInstanceID *string, instanceAttributes *map[string]string) (statemanager.StateManager, error) {
 	if !cfg.Checkpoint {
 		return statemanager.NewNoopStateManager(), nil
 	}
-	stateManager, err := statemanager.NewStateManager(cfg,
+	newStateManager := statemanager.NewStateManager
+	if cfg.StateBackend == config.StateBackendKV {
+		newStateManager = statemanager.NewKVStateManager
+	}
+	stateManager, err := newStateManager(cfg,
 		statemanager.AddSaveable("TaskEngine", taskEngine),
 		statemanager.AddSaveable("ContainerInstanceArn", containerInstanceArn),
 		statemanager.AddSaveable("Cluster", cluster),
 		statemanager.AddSaveable("EC2InstanceID", savedInstanceID),
+		statemanager.AddSaveable("InstanceAttributes", instanceAttributes),
 	)
 	if err != nil {
 		return nil, err
@@ -188,24 +291,51 @@ func initializeStateManager(cfg *config.Config, taskEngine engine.TaskEngine, cl
 	return stateManager, nil
 }
 
-func startMetricsSession(containerInstanceArn string, credentialProvider credentials.AWSCredentialProvider, cfg *config.Config, acceptInvalidCert bool, log log15.Logger, taskEngine engine.TaskEngine) {
-	if stats.IsMetricCollectionEnabled() {
-		statsEngine := stats.NewDockerStatsEngine()
-		err := statsEngine.MustInit(taskEngine, &ecstcs.MetricsMetadata{
-			Cluster:           &cfg.Cluster,
-			ContainerInstance: &containerInstanceArn,
-		})
+// reconcileInstanceAttributes pushes any difference between the attributes we
+// last registered and the currently configured ones to the backend. It returns
+// true if the backend is now up to date with the current attributes.
+func reconcileInstanceAttributes(client api.ECSClient, containerInstanceArn string, previous, current map[string]string, log log15.Logger) bool {
+	updated, removed := api.DiffInstanceAttributes(previous, current)
+	if len(updated) == 0 && len(removed) == 0 {
+		return false
+	}
+	log.Info("Instance attributes changed since last run", "updated", updated, "removed", removed)
+	if err := client.PutContainerInstanceAttributes(containerInstanceArn, updated); err != nil {
+		log.Error("Unable to update instance attributes", "err", err)
+		return false
+	}
+	if err := client.DeleteContainerInstanceAttributes(containerInstanceArn, removed); err != nil {
+		log.Error("Unable to remove instance attributes", "err", err)
+		return false
+	}
+	return true
+}
 
-		if err != nil {
-			log.Warn("Error initializing metrics engine", "err", err)
-			return
-		}
-		err = tcs.StartSession(containerInstanceArn, credentialProvider, cfg, acceptInvalidCert, statsEngine)
-		if err != nil {
-			log.Warn("Error starting metrics session with backend", "err", err)
-			return
-		}
-	} else {
+// newStatsEngine initializes the stats engine. It returns nil if metric
+// collection is disabled or the engine cannot be initialized.
+func newStatsEngine(taskEngine engine.TaskEngine, cluster, containerInstanceArn *string, log log15.Logger) stats.Engine {
+	if !stats.IsMetricCollectionEnabled() {
 		log.Warn("Metric collection disabled")
+		return nil
+	}
+	statsEngine := stats.NewDockerStatsEngine()
+	err := statsEngine.MustInit(taskEngine, &ecstcs.MetricsMetadata{
+		Cluster:           cluster,
+		ContainerInstance: containerInstanceArn,
+	})
+	if err != nil {
+		log.Warn("Error initializing metrics engine", "err", err)
+		return nil
+	}
+	return statsEngine
+}
+
+func startMetricsSession(containerInstanceArn string, credentialProvider credentials.AWSCredentialProvider, cfg *config.Config, acceptInvalidCert bool, log log15.Logger, statsEngine stats.Engine) {
+	if statsEngine == nil {
+		return
+	}
+	err := tcs.StartSession(containerInstanceArn, credentialProvider, cfg, acceptInvalidCert, statsEngine)
+	if err != nil {
+		log.Warn("Error starting metrics session with backend", "err", err)
 	}
 }
@@ -43,6 +43,8 @@ type ECSClient interface {
 	SubmitTaskStateChange(change ContainerStateChange) utils.RetriableError
 	SubmitContainerStateChange(change ContainerStateChange) utils.RetriableError
 	DiscoverPollEndpoint(containerInstanceArn string) (string, error)
+	PutContainerInstanceAttributes(containerInstanceArn string, attributes map[string]string) error
+	DeleteContainerInstanceAttributes(containerInstanceArn string, names []string) error
 }
 
 type ApiECSClient struct {
@@ -60,6 +62,10 @@ const (
 
 const EcsMaxReasonLength = 255
 
+// containerInstanceTargetType is the attribute target type for attributes
+// attached to a container instance
+const containerInstanceTargetType = "container-instance"
+
 func NewECSClient(credentialProvider credentials.AWSCredentialProvider, config *config.Config, insecureSkipVerify bool) ECSClient {
 	client := &ApiECSClient{credentialProvider: credentialProvider,
 		config:             config,
@@ -197,27 +203,14 @@ func (client *ApiECSClient) registerContainerInstance(clusterRef string) (string
 	svcRequest := svc.NewRegisterContainerInstanceRequest()
 	svcRequest.SetCluster(&clusterRef)
 
-	instanceIdentityDoc, err := ec2.ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_RESOURCE)
-	iidRetrieved := true
-	if err != nil {
-		log.Error("Unable to get instance identity document", "err", err)
-		iidRetrieved = false
-		instanceIdentityDoc = []byte{}
-	}
-	strIid := string(instanceIdentityDoc)
-	svcRequest.SetInstanceIdentityDocument(&strIid)
-
-	instanceIdentitySignature := []byte{}
-	if iidRetrieved {
-		instanceIdentitySignature, err = ec2.ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_SIGNATURE_RESOURCE)
-		if err != nil {
-			log.Error("Unable to get instance identity signature", "err", err)
-		}
+	// External instances have no identity document; they are registered
+	// purely on the strength of their credentials
+	if !client.config.ExternalInstance {
+		iid, iidSignature := instanceIdentity()
+		svcRequest.SetInstanceIdentityDocument(&iid)
+		svcRequest.SetInstanceIdentityDocumentSignature(&iidSignature)
 	}
 
-	strIidSig := string(instanceIdentitySignature)
-	svcRequest.SetInstanceIdentityDocumentSignature(&strIidSig)
-
 	integerStr := "INTEGER"
 
 	cpu, mem := getCpuAndMemory()
@@ -239,6 +232,7 @@ func (client *ApiECSClient) registerContainerInstance(clusterRef string) (string
 
 	resources := []svc.Resource{cpuResource, memResource, portResource}
 	svcRequest.SetTotalResources(resources)
+	svcRequest.SetAttributes(buildAttributes(client.config.InstanceAttributes, nil))
 
 	ecs, err := client.serviceClient()
 	if err != nil {
@@ -255,6 +249,28 @@ func (client *ApiECSClient) registerContainerInstance(clusterRef string) (string
 	return *resp.ContainerInstance().ContainerInstanceArn(), nil
 }
 
+// instanceIdentity returns the instance identity document and its signature
+// from the EC2 metadata service. Either may be empty if it could not be read.
+func instanceIdentity() (string, string) {
+	instanceIdentityDoc, err := ec2.ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_RESOURCE)
+	iidRetrieved := true
+	if err != nil {
+		log.Error("Unable to get instance identity document", "err", err)
+		iidRetrieved = false
+		instanceIdentityDoc = []byte{}
+	}
+
+	instanceIdentitySignature := []byte{}
+	if iidRetrieved {
+		instanceIdentitySignature, err = ec2.ReadResource(ec2.INSTANCE_IDENTITY_DOCUMENT_SIGNATURE_RESOURCE)
+		if err != nil {
+			log.Error("Unable to get instance identity signature", "err", err)
+		}
+	}
+
+	return string(instanceIdentityDoc), string(instanceIdentitySignature)
+}
+
 func (client *ApiECSClient) SubmitTaskStateChange(change ContainerStateChange) utils.RetriableError {
 	if change.TaskStatus == TaskStatusNone {
 		log.Warn("SubmitTaskStateChange called with an invalid change", "change", change)
@@ -355,3 +371,75 @@ func (client *ApiECSClient) DiscoverPollEndpoint(containerInstanceArn string) (s
 
 	return *resp.Endpoint(), nil
 }
+
+// PutContainerInstanceAttributes creates or updates the given attributes on a
+// registered container instance
+func (client *ApiECSClient) PutContainerInstanceAttributes(containerInstanceArn string, attributes map[string]string) error {
+	if len(attributes) == 0 {
+		return nil
+	}
+	req := svc.NewPutAttributesRequest()
+	req.SetCluster(&client.config.Cluster)
+	req.SetAttributes(buildAttributes(attributes, &containerInstanceArn))
+
+	c, err := client.serviceClient()
+	if err != nil {
+		return NewAPIError(err)
+	}
+	_, err = c.PutAttributes(req)
+	if err != nil {
+		log.Warn("Could not put container instance attributes", "err", err)
+		return NewAPIError(err)
+	}
+	return nil
+}
+
+// DeleteContainerInstanceAttributes removes the named attributes from a
+// registered container instance
+func (client *ApiECSClient) DeleteContainerInstanceAttributes(containerInstanceArn string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	attributes := make([]svc.Attribute, len(names))
+	for i, name := range names {
+		attributes[i] = newAttribute(name, nil, &containerInstanceArn)
+	}
+	req := svc.NewDeleteAttributesRequest()
+	req.SetCluster(&client.config.Cluster)
+	req.SetAttributes(attributes)
+
+	c, err := client.serviceClient()
+	if err != nil {
+		return NewAPIError(err)
+	}
+	_, err = c.DeleteAttributes(req)
+	if err != nil {
+		log.Warn("Could not delete container instance attributes", "err", err)
+		return NewAPIError(err)
+	}
+	return nil
+}
+
+// buildAttributes converts a map of attributes into their service
+// representation, ordered by name. If containerInstanceArn is nil, the
+// attributes are not given an explicit target, as is the case during
+// registration.
+func buildAttributes(attributes map[string]string, containerInstanceArn *string) []svc.Attribute {
+	svcAttributes := make([]svc.Attribute, 0, len(attributes))
+	for _, name := range sortedAttributeNames(attributes) {
+		value := attributes[name]
+		svcAttributes = append(svcAttributes, newAttribute(name, &value, containerInstanceArn))
+	}
+	return svcAttributes
+}
+
+func newAttribute(name string, value *string, containerInstanceArn *string) svc.Attribute {
+	attribute := svc.NewAttribute()
+	attribute.SetName(&name)
+	attribute.SetValue(value)
+	if containerInstanceArn != nil {
+		attribute.SetTargetType(utils.Strptr(containerInstanceTargetType))
+		attribute.SetTargetId(containerInstanceArn)
+	}
+	return attribute
+}
@@ -182,6 +182,106 @@ func TestRegisterContainerInstance(t *testing.T) {
 	// TODO, test instance identity document and resources
 }
 
+func TestRegisterContainerInstanceAttributes(t *testing.T) {
+	client, mockSvcClient := NewMockClient()
+	client.(*ApiECSClient).config.InstanceAttributes = map[string]string{"team": "web", "stack": "prod"}
+	_, err := client.RegisterContainerInstance()
+	if err != nil {
+		t.Error("Unexpected register error")
+	}
+	req := mockSvcClient.lastRequest().(svc.RegisterContainerInstanceRequest)
+	attributes := req.Attributes()
+	if len(attributes) != 2 {
+		t.Fatal("Expected two attributes to be registered")
+	}
+	if *attributes[0].Name() != "stack" || *attributes[0].Value() != "prod" {
+		t.Error("Unexpected first attribute", *attributes[0].Name())
+	}
+	if *attributes[1].Name() != "team" || *attributes[1].Value() != "web" {
+		t.Error("Unexpected second attribute", *attributes[1].Name())
+	}
+	if attributes[0].TargetId() != nil {
+		t.Error("Registration attributes should not have a target")
+	}
+}
+
+func TestRegisterExternalContainerInstance(t *testing.T) {
+	client, mockSvcClient := NewMockClient()
+	client.(*ApiECSClient).config.ExternalInstance = true
+	_, err := client.RegisterContainerInstance()
+	if err != nil {
+		t.Error("Unexpected register error")
+	}
+	req := mockSvcClient.lastRequest().(svc.RegisterContainerInstanceRequest)
+	if req.InstanceIdentityDocument() != nil || req.InstanceIdentityDocumentSignature() != nil {
+		t.Error("External instances should not send an instance identity document")
+	}
+	if len(req.TotalResources()) != 3 {
+		t.Error("Expected resources to be registered")
+	}
+}
+
+func (mock *mockAmazonEC2ContainerServiceV20141113Client) PutAttributes(req svc.PutAttributesRequest) (svc.PutAttributesResponse, error) {
+	mock.addRequest(req)
+	resp, err := mock.getResponse("PutAttributes", svc.NewPutAttributesResponse(), nil)
+	return resp.(svc.PutAttributesResponse), err
+}
+
+func (mock *mockAmazonEC2ContainerServiceV20141113Client) DeleteAttributes(req svc.DeleteAttributesRequest) (svc.DeleteAttributesResponse, error) {
+	mock.addRequest(req)
+	resp, err := mock.getResponse("DeleteAttributes", svc.NewDeleteAttributesResponse(), nil)
+	return resp.(svc.DeleteAttributesResponse), err
+}
+
+func TestPutContainerInstanceAttributes(t *testing.T) {
+	client, mockSvcClient := NewMockClient()
+	err := client.PutContainerInstanceAttributes("arn", map[string]string{"stack": "prod"})
+	if err != nil {
+		t.Error("Unexpected error putting attributes", err)
+	}
+	req := mockSvcClient.lastRequest().(svc.PutAttributesRequest)
+	if *req.Cluster() != configuredCluster {
+		t.Error("Put attributes for wrong cluster")
+	}
+	attributes := req.Attributes()
+	if len(attributes) != 1 {
+		t.Fatal("Expected one attribute")
+	}
+	if *attributes[0].Name() != "stack" || *attributes[0].Value() != "prod" {
+		t.Error("Put wrong attribute")
+	}
+	if *attributes[0].TargetId() != "arn" || *attributes[0].TargetType() != "container-instance" {
+		t.Error("Put attribute with wrong target")
+	}
+
+	err = client.PutContainerInstanceAttributes("arn", nil)
+	if err != nil || len(mockSvcClient.requests) != 1 {
+		t.Error("Putting no attributes should not make a request")
+	}
+}
+
+func TestDeleteContainerInstanceAttributes(t *testing.T) {
+	client, mockSvcClient := NewMockClient()
+	err := client.DeleteContainerInstanceAttributes("arn", []string{"old"})
+	if err != nil {
+		t.Error("Unexpected error deleting attributes", err)
+	}
+	req := mockSvcClient.lastRequest().(svc.DeleteAttributesRequest)
+	attributes := req.Attributes()
+	if len(attributes) != 1 || *attributes[0].Name() != "old" || attributes[0].Value() != nil {
+		t.Error("Deleted wrong attributes")
+	}
+	if *attributes[0].TargetId() != "arn" {
+		t.Error("Deleted attribute with wrong target")
+	}
+
+	mockSvcClient.addResponse("DeleteAttributes", svc.NewDeleteAttributesResponse(), svc.NewClientException())
+	err = client.DeleteContainerInstanceAttributes("arn", []string{"old"})
+	if err == nil {
+		t.Error("Expected delete error to be returned")
+	}
+}
+
 func (mock *mockAmazonEC2ContainerServiceV20141113Client) CreateCluster(req svc.CreateClusterRequest) (svc.CreateClusterResponse, error) {
 	mock.addRequest(req)
 	defaultCreateClusterResponse := svc.NewCreateClusterResponse()
@@ -0,0 +1,90 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+const (
+	// MaxAttributeNameLength and MaxAttributeValueLength are the limits the
+	// backend places on container instance attributes.
+	MaxAttributeNameLength  = 128
+	MaxAttributeValueLength = 128
+)
+
+var (
+	attributeNameRegex  = regexp.MustCompile(`^[a-zA-Z0-9_./-]+$`)
+	attributeValueRegex = regexp.MustCompile(`^[a-zA-Z0-9_.@/: -]*$`)
+)
+
+// ValidateInstanceAttributes checks that every name and value in the given
+// attributes is acceptable to the backend. Names must be 1-128 letters,
+// numbers, hyphens, underscores, periods or slashes; values may additionally
+// contain at signs, colons and spaces, but may not begin or end with a space.
+// All problems are reported at once.
+func ValidateInstanceAttributes(attributes map[string]string) error {
+	var errs []error
+	for _, name := range sortedAttributeNames(attributes) {
+		value := attributes[name]
+		if len(name) == 0 || len(name) > MaxAttributeNameLength {
+			errs = append(errs, fmt.Errorf("Attribute name %q must be between 1 and %d characters", name, MaxAttributeNameLength))
+		} else if !attributeNameRegex.MatchString(name) {
+			errs = append(errs, fmt.Errorf("Attribute name %q contains invalid characters", name))
+		}
+		if len(value) > MaxAttributeValueLength {
+			errs = append(errs, fmt.Errorf("Attribute %q has a value longer than %d characters", name, MaxAttributeValueLength))
+		} else if !attributeValueRegex.MatchString(value) {
+			errs = append(errs, fmt.Errorf("Attribute %q has a value with invalid characters", name))
+		} else if len(value) > 0 && (value[0] == ' ' || value[len(value)-1] == ' ') {
+			errs = append(errs, fmt.Errorf("Attribute %q has a value with leading or trailing whitespace", name))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return utils.NewMultiError(errs...)
+}
+
+// DiffInstanceAttributes compares a previously registered set of attributes
+// with the current one. It returns the attributes which are new or have a
+// changed value, and the names of attributes which are no longer present.
+func DiffInstanceAttributes(previous, current map[string]string) (map[string]string, []string) {
+	updated := make(map[string]string)
+	for name, value := range current {
+		if oldValue, ok := previous[name]; !ok || oldValue != value {
+			updated[name] = value
+		}
+	}
+	removed := []string{}
+	for _, name := range sortedAttributeNames(previous) {
+		if _, ok := current[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	return updated, removed
+}
+
+func sortedAttributeNames(attributes map[string]string) []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateInstanceAttributes(t *testing.T) {
+	valid := map[string]string{
+		"stack":                  "prod",
+		"com.example/team":       "web-frontend",
+		"owner":                  "ops@example.com",
+		"window":                 "Sat 02:00",
+		"empty":                  "",
+		strings.Repeat("a", 128): strings.Repeat("b", 128),
+	}
+	if err := ValidateInstanceAttributes(valid); err != nil {
+		t.Error("Expected attributes to be valid", err)
+	}
+	if err := ValidateInstanceAttributes(nil); err != nil {
+		t.Error("Expected no attributes to be valid", err)
+	}
+
+	invalid := []map[string]string{
+		{"": "value"},
+		{strings.Repeat("a", 129): "value"},
+		{"has space": "value"},
+		{"colon:name": "value"},
+		{"name": strings.Repeat("b", 129)},
+		{"name": "semi;colon"},
+		{"name": " leading"},
+		{"name": "trailing "},
+	}
+	for _, attributes := range invalid {
+		if err := ValidateInstanceAttributes(attributes); err == nil {
+			t.Error("Expected attributes to be invalid", attributes)
+		}
+	}
+}
+
+func TestDiffInstanceAttributes(t *testing.T) {
+	previous := map[string]string{"stack": "prod", "team": "web", "old": "x"}
+	current := map[string]string{"stack": "prod", "team": "api", "new": "y"}
+
+	updated, removed := DiffInstanceAttributes(previous, current)
+	if !reflect.DeepEqual(updated, map[string]string{"team": "api", "new": "y"}) {
+		t.Error("Unexpected updated attributes", updated)
+	}
+	if !reflect.DeepEqual(removed, []string{"old"}) {
+		t.Error("Unexpected removed attributes", removed)
+	}
+
+	updated, removed = DiffInstanceAttributes(current, current)
+	if len(updated) != 0 || len(removed) != 0 {
+		t.Error("Expected no difference between identical attributes")
+	}
+}
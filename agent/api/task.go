@@ -32,8 +32,8 @@ const emptyHostVolumeName = "~internal~ecs-emptyvolume-source"
 // run. It is possible it will be subsequently called after that and should be
 // able to handle such an occurrence appropriately (e.g. behave idempotently).
 func (task *Task) PostUnmarshalTask() {
-	// TODO, add rudimentary plugin support and call any plugins that want to
-	// hook into this
+	// Configurable plugins are applied separately by the engine; see the
+	// tasktransformer package
 
 	task.initializeEmptyVolumes()
 }
@@ -137,16 +137,35 @@ func (task *Task) UpdateMountPoints(cont *Container, vols map[string]string) {
 // auxilery containers into terminal states (e.g. the essential containers died
 // already)
 func (task *Task) InferContainerDesiredStatus() {
+	desiredStatus := task.maxStatus().ContainerStatus()
 	for _, c := range task.Containers {
-		c.DesiredStatus = task.maxStatus().ContainerStatus()
+		c.StatusLock.Lock()
+		c.DesiredStatus = desiredStatus
+		c.StatusLock.Unlock()
 	}
 }
 
 func (task *Task) maxStatus() *TaskStatus {
-	if task.KnownStatus > task.DesiredStatus {
+	desiredStatus := task.GetDesiredStatus()
+	if task.KnownStatus > desiredStatus {
 		return &task.KnownStatus
 	}
-	return &task.DesiredStatus
+	return &desiredStatus
+}
+
+// GetDesiredStatus returns the status the task should be at
+func (task *Task) GetDesiredStatus() TaskStatus {
+	task.desiredStatusLock.RLock()
+	defer task.desiredStatusLock.RUnlock()
+	return task.DesiredStatus
+}
+
+// SetDesiredStatus sets the status the task should be at. It is safe to call
+// while the task is being processed.
+func (task *Task) SetDesiredStatus(status TaskStatus) {
+	task.desiredStatusLock.Lock()
+	defer task.desiredStatusLock.Unlock()
+	task.DesiredStatus = status
 }
 
 // UpdateTaskState updates the given task's status based on its container's status.
@@ -161,6 +180,12 @@ func (task *Task) UpdateTaskStatus() (newStatus TaskStatus) {
 	defer func() {
 		if newStatus != TaskStatusNone {
 			task.KnownTime = time.Now()
+			if newStatus == TaskRunning && task.StartedAt.IsZero() {
+				task.StartedAt = task.KnownTime
+			}
+			if newStatus.Terminal() && task.StoppedAt.IsZero() {
+				task.StoppedAt = task.KnownTime
+			}
 		}
 	}()
 
@@ -113,6 +113,7 @@ func TestTaskFromACS(t *testing.T) {
 		DesiredStatus: strptr("RUNNING"),
 		Family:        strptr("myFamily"),
 		Version:       strptr("1"),
+		RoleArn:       strptr("arn:aws:iam::123456789012:role/myRole"),
 		Containers: []*ecsacs.Container{
 			&ecsacs.Container{
 				Name:        strptr("myName"),
@@ -212,4 +213,7 @@ func TestTaskFromACS(t *testing.T) {
 	if !reflect.DeepEqual(task.Containers, expectedTask.Containers) {
 		t.Fatal("Should be equal")
 	}
+	if task.RoleArn != "arn:aws:iam::123456789012:role/myRole" {
+		t.Error("Task role was not converted", task.RoleArn)
+	}
 }
@@ -65,10 +65,33 @@ type Task struct {
 	KnownStatus   TaskStatus
 	KnownTime     time.Time
 
+	// ReceivedAt is when the agent first received the task from ACS
+	ReceivedAt time.Time
+
+	// StartedAt and StoppedAt are when the task was first known to be running
+	// and stopped
+	StartedAt time.Time
+	StoppedAt time.Time
+
 	SentStatus TaskStatus
 
+	// RoleArn is the IAM role whose credentials are served to this task's
+	// containers, if any
+	RoleArn string `json:"roleArn,omitempty"`
+	// CredentialsId is a secret which identifies this task to the credentials
+	// endpoint. It is only given to the task's own containers.
+	CredentialsId string `json:",omitempty"`
+
+	// MetadataToken is a secret which identifies this task to the task
+	// metadata server. It is only given to the task's own containers.
+	MetadataToken string `json:",omitempty"`
+
 	containersByNameLock sync.Mutex
 	containersByName     map[string]*Container
+
+	// desiredStatusLock guards DesiredStatus once the task is being managed;
+	// see GetDesiredStatus and SetDesiredStatus
+	desiredStatusLock sync.RWMutex
 }
 
 // TaskVolume is a definition of all the volumes available for containers to
@@ -127,7 +150,8 @@ type ContainerStateChange struct {
 }
 
 func (t *Task) String() string {
-	res := fmt.Sprintf("%s-%s %s, Overrides: %s Status: %s(%s)", t.Family, t.Version, t.Arn, t.Overrides, t.KnownStatus.String(), t.DesiredStatus.String())
+	desiredStatus := t.GetDesiredStatus()
+	res := fmt.Sprintf("%s-%s %s, Overrides: %s Status: %s(%s)", t.Family, t.Version, t.Arn, t.Overrides, t.KnownStatus.String(), desiredStatus.String())
 	res += " Containers: "
 	for _, c := range t.Containers {
 		res += c.Name + ","
@@ -184,6 +208,16 @@ type Container struct {
 
 	KnownExitCode     *int
 	KnownPortBindings []PortBinding
+	// KnownImageId is the id of the image the container was created from
+	KnownImageId string
+
+	// PulledAt, CreatedAt, StartedAt and FinishedAt record when the container
+	// went through each step of its lifecycle. The last three are as reported
+	// by docker.
+	PulledAt   time.Time
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
 
 	// Not upstream; todo move this out into a wrapper type
 	StatusLock sync.Mutex
@@ -0,0 +1,143 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"code.google.com/p/gomock/gomock"
+	"github.com/aws/amazon-ecs-agent/agent/ec2"
+	"github.com/aws/amazon-ecs-agent/agent/engine/mocks"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+type fakeIIDClient struct {
+	iid *ec2.InstanceIdentityDocument
+	err error
+}
+
+func (c fakeIIDClient) InstanceIdentityDocument() (*ec2.InstanceIdentityDocument, error) {
+	return c.iid, c.err
+}
+
+func TestEC2Detector(t *testing.T) {
+	detector := &EC2Detector{client: fakeIIDClient{iid: &ec2.InstanceIdentityDocument{
+		InstanceType:     "c4.large",
+		ImageId:          "ami-1234",
+		AvailabilityZone: "us-east-1a",
+		Architecture:     utils.Strptr("x86_64"),
+	}}}
+	attributes, err := detector.Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		InstanceTypeAttribute:     "c4.large",
+		AMIIDAttribute:            "ami-1234",
+		AvailabilityZoneAttribute: "us-east-1a",
+		CPUArchitectureAttribute:  "x86_64",
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Error("Unexpected ec2 attributes", attributes)
+	}
+
+	detector = &EC2Detector{client: fakeIIDClient{err: errors.New("no metadata")}}
+	if _, err := detector.Detect(); err == nil {
+		t.Error("Expected an error without metadata")
+	}
+}
+
+func TestDockerDetector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_engine.NewMockDockerClient(ctrl)
+
+	info := &docker.Env{}
+	info.Set("ServerVersion", "1.9.1")
+	info.Set("Driver", "devicemapper")
+	info.SetJSON("Plugins", map[string][]string{"Volume": {"local"}, "Log": {"json-file", "syslog"}})
+	client.EXPECT().Info().Return(info, nil)
+
+	attributes, err := NewDockerDetector(client).Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		DockerVersionAttribute:                      "1.9.1",
+		DockerStorageDriverAttribute:                "devicemapper",
+		LoggingDriverCapabilityPrefix + "json-file": "",
+		LoggingDriverCapabilityPrefix + "syslog":    "",
+		VolumeDriverCapabilityPrefix + "local":      "",
+	}
+	if !reflect.DeepEqual(attributes, expected) {
+		t.Error("Unexpected docker attributes", attributes)
+	}
+}
+
+func TestDockerDetectorDefaultLoggingDriver(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_engine.NewMockDockerClient(ctrl)
+
+	info := &docker.Env{}
+	info.Set("ServerVersion", "1.7.0")
+	info.Set("LoggingDriver", "json-file")
+	client.EXPECT().Info().Return(info, nil)
+
+	attributes, err := NewDockerDetector(client).Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := attributes[LoggingDriverCapabilityPrefix+"json-file"]; !ok {
+		t.Error("Expected the default logging driver to be reported", attributes)
+	}
+}
+
+func TestDockerDetectorUnavailable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	client := mock_engine.NewMockDockerClient(ctrl)
+	client.EXPECT().Info().Return(nil, errors.New("no docker"))
+
+	if _, err := NewDockerDetector(client).Detect(); err == nil {
+		t.Error("Expected an error when docker is unavailable")
+	}
+}
+
+func TestKernelDetector(t *testing.T) {
+	file, err := ioutil.TempFile("", "osrelease")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("3.14.35-28.38.amzn1.x86_64\n")
+	file.Close()
+
+	attributes, err := (&KernelDetector{releasePath: file.Name()}).Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attributes[KernelVersionAttribute] != "3.14.35-28.38.amzn1.x86_64" {
+		t.Error("Unexpected kernel version", attributes)
+	}
+
+	if _, err := (&KernelDetector{releasePath: file.Name() + ".missing"}).Detect(); err == nil {
+		t.Error("Expected an error for a missing release file")
+	}
+}
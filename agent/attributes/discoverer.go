@@ -0,0 +1,102 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/ec2"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("attributes")
+
+// A Detector discovers a set of attributes from a single source. Detectors
+// should return an error if their source is unavailable; the attributes they
+// would have provided are then simply omitted.
+type Detector interface {
+	// Name identifies the detector in logs
+	Name() string
+	// Detect returns the attributes this detector knows about
+	Detect() (map[string]string, error)
+}
+
+// Discoverer runs a list of Detectors and combines their results
+type Discoverer struct {
+	detectors []Detector
+}
+
+// NewDiscoverer creates a Discoverer which runs the given detectors
+func NewDiscoverer(detectors ...Detector) *Discoverer {
+	return &Discoverer{detectors: detectors}
+}
+
+// NewDefaultDiscoverer creates a Discoverer with the built-in Docker and
+// kernel detectors, plus the EC2 detector unless cfg is for an external
+// instance
+func NewDefaultDiscoverer(cfg *config.Config, dockerClient engine.DockerClient) *Discoverer {
+	discoverer := NewDiscoverer(
+		NewDockerDetector(dockerClient),
+		NewKernelDetector(),
+	)
+	if !cfg.ExternalInstance {
+		discoverer.AddDetector(NewEC2Detector(ec2.DefaultClient))
+	}
+	return discoverer
+}
+
+// AddDetector allows a custom detector to be added. Detectors added later
+// take precedence over earlier ones if they report the same attribute.
+func (d *Discoverer) AddDetector(detector Detector) {
+	d.detectors = append(d.detectors, detector)
+}
+
+// Discover runs every detector and returns the combined attributes. Detectors
+// which fail and attributes which would not be accepted by the backend are
+// logged and skipped.
+func (d *Discoverer) Discover() map[string]string {
+	discovered := make(map[string]string)
+	for _, detector := range d.detectors {
+		attributes, err := detector.Detect()
+		if err != nil {
+			log.Warn("Unable to detect attributes", "detector", detector.Name(), "err", err)
+			continue
+		}
+		for name, value := range attributes {
+			if err := api.ValidateInstanceAttributes(map[string]string{name: value}); err != nil {
+				log.Warn("Ignoring invalid detected attribute", "detector", detector.Name(), "name", name, "value", value)
+				continue
+			}
+			discovered[name] = value
+		}
+	}
+	return discovered
+}
+
+// Merge combines configured attributes with discovered ones. Configured
+// attributes take precedence so that users may override any detected value.
+func Merge(configured, discovered map[string]string) map[string]string {
+	if len(configured) == 0 && len(discovered) == 0 {
+		return configured
+	}
+	merged := make(map[string]string, len(configured)+len(discovered))
+	for name, value := range discovered {
+		merged[name] = value
+	}
+	for name, value := range configured {
+		merged[name] = value
+	}
+	return merged
+}
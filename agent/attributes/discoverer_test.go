@@ -0,0 +1,59 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type staticDetector struct {
+	name       string
+	attributes map[string]string
+	err        error
+}
+
+func (d *staticDetector) Name() string {
+	return d.name
+}
+
+func (d *staticDetector) Detect() (map[string]string, error) {
+	return d.attributes, d.err
+}
+
+func TestDiscover(t *testing.T) {
+	discoverer := NewDiscoverer(
+		&staticDetector{name: "first", attributes: map[string]string{"a": "1", "b": "1"}},
+		&staticDetector{name: "broken", attributes: map[string]string{"c": "1"}, err: errors.New("unavailable")},
+	)
+	discoverer.AddDetector(&staticDetector{name: "last", attributes: map[string]string{"b": "2", "bad name": "x", "d": "bad;value"}})
+
+	discovered := discoverer.Discover()
+	expected := map[string]string{"a": "1", "b": "2"}
+	if !reflect.DeepEqual(discovered, expected) {
+		t.Error("Unexpected discovered attributes", discovered)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	merged := Merge(map[string]string{"a": "configured"}, map[string]string{"a": "discovered", "b": "discovered"})
+	expected := map[string]string{"a": "configured", "b": "discovered"}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Error("Configured attributes should take precedence", merged)
+	}
+	if Merge(nil, nil) != nil {
+		t.Error("Merging nothing should result in nothing")
+	}
+}
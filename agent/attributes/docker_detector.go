@@ -0,0 +1,82 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+const (
+	DockerVersionAttribute       = "ecs.docker-version"
+	DockerStorageDriverAttribute = "ecs.docker-storage-driver"
+
+	// Each supported log and volume driver is reported as an attribute with
+	// one of these prefixes and an empty value, e.g.
+	// "ecs.capability.logging-driver.json-file"
+	LoggingDriverCapabilityPrefix = "ecs.capability.logging-driver."
+	VolumeDriverCapabilityPrefix  = "ecs.capability.volume-driver."
+)
+
+// dockerPlugins is the "Plugins" section of the Docker info response. Only
+// newer daemons report it.
+type dockerPlugins struct {
+	Volume []string
+	Log    []string
+}
+
+// DockerDetector reports attributes describing the Docker daemon
+type DockerDetector struct {
+	client engine.DockerClient
+}
+
+// NewDockerDetector creates a DockerDetector which queries the given client
+func NewDockerDetector(client engine.DockerClient) *DockerDetector {
+	return &DockerDetector{client: client}
+}
+
+func (d *DockerDetector) Name() string {
+	return "docker"
+}
+
+func (d *DockerDetector) Detect() (map[string]string, error) {
+	info, err := d.client.Info()
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	setIfPresent(attributes, DockerVersionAttribute, info.Get("ServerVersion"))
+	setIfPresent(attributes, DockerStorageDriverAttribute, info.Get("Driver"))
+
+	var plugins dockerPlugins
+	if info.Exists("Plugins") {
+		if err := info.GetJSON("Plugins", &plugins); err != nil {
+			log.Warn("Unable to parse Docker plugins", "err", err)
+		}
+	}
+	logDrivers := plugins.Log
+	if len(logDrivers) == 0 && info.Get("LoggingDriver") != "" {
+		// Daemons which don't list their log plugins still report the
+		// default driver
+		logDrivers = []string{info.Get("LoggingDriver")}
+	}
+	for _, driver := range logDrivers {
+		attributes[LoggingDriverCapabilityPrefix+strings.TrimSpace(driver)] = ""
+	}
+	for _, driver := range plugins.Volume {
+		attributes[VolumeDriverCapabilityPrefix+strings.TrimSpace(driver)] = ""
+	}
+	return attributes, nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"github.com/aws/amazon-ecs-agent/agent/ec2"
+)
+
+const (
+	InstanceTypeAttribute     = "ecs.instance-type"
+	AMIIDAttribute            = "ecs.ami-id"
+	AvailabilityZoneAttribute = "ecs.availability-zone"
+	CPUArchitectureAttribute  = "ecs.cpu-architecture"
+)
+
+// instanceIdentityDocumentClient is the subset of the ec2 metadata client
+// the EC2 detector needs
+type instanceIdentityDocumentClient interface {
+	InstanceIdentityDocument() (*ec2.InstanceIdentityDocument, error)
+}
+
+// EC2Detector reports attributes from the EC2 instance identity document
+type EC2Detector struct {
+	client instanceIdentityDocumentClient
+}
+
+// NewEC2Detector creates an EC2Detector which reads from the given metadata
+// client
+func NewEC2Detector(client *ec2.EC2MetadataClient) *EC2Detector {
+	return &EC2Detector{client: client}
+}
+
+func (d *EC2Detector) Name() string {
+	return "ec2"
+}
+
+func (d *EC2Detector) Detect() (map[string]string, error) {
+	iid, err := d.client.InstanceIdentityDocument()
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	setIfPresent(attributes, InstanceTypeAttribute, iid.InstanceType)
+	setIfPresent(attributes, AMIIDAttribute, iid.ImageId)
+	setIfPresent(attributes, AvailabilityZoneAttribute, iid.AvailabilityZone)
+	if iid.Architecture != nil {
+		setIfPresent(attributes, CPUArchitectureAttribute, *iid.Architecture)
+	}
+	return attributes, nil
+}
+
+func setIfPresent(attributes map[string]string, name, value string) {
+	if value != "" {
+		attributes[name] = value
+	}
+}
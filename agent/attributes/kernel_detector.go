@@ -0,0 +1,49 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package attributes
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	KernelVersionAttribute = "ecs.kernel-version"
+
+	kernelReleasePath = "/proc/sys/kernel/osrelease"
+)
+
+// KernelDetector reports the version of the running kernel
+type KernelDetector struct {
+	releasePath string
+}
+
+// NewKernelDetector creates a KernelDetector
+func NewKernelDetector() *KernelDetector {
+	return &KernelDetector{releasePath: kernelReleasePath}
+}
+
+func (d *KernelDetector) Name() string {
+	return "kernel"
+}
+
+func (d *KernelDetector) Detect() (map[string]string, error) {
+	release, err := ioutil.ReadFile(d.releasePath)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]string)
+	setIfPresent(attributes, KernelVersionAttribute, strings.TrimSpace(string(release)))
+	return attributes, nil
+}
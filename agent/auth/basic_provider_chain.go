@@ -40,6 +40,17 @@ func NewBasicAWSCredentialProvider() *BasicAWSCredentialProvider {
 	return provider
 }
 
+// NewExternalAWSCredentialProvider creates a credential provider chain for a
+// host outside of EC2, which has no instance metadata to fall back to. It
+// pulls credentials from the environment only.
+func NewExternalAWSCredentialProvider() *BasicAWSCredentialProvider {
+	provider := newBasicAWSCredentialProvider()
+
+	provider.AddProvider(NewEnvironmentCredentialProvider())
+
+	return provider
+}
+
 // AddProvider allows a custom provider to be added. Calling this will add the
 // provider as the first in order of preference for usage
 func (bcp *BasicAWSCredentialProvider) AddProvider(acp AWSCredentialProvider) {
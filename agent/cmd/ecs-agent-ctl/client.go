@@ -0,0 +1,171 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// requestTimeout bounds each request to the agent
+const requestTimeout = 30 * time.Second
+
+// tlsOptions configures how the agent's certificate is verified and which
+// certificate, if any, is presented to it
+type tlsOptions struct {
+	enabled    bool
+	caFile     string
+	certFile   string
+	keyFile    string
+	serverName string
+	insecure   bool
+}
+
+// client makes requests to one of the agent's introspection listeners
+type client struct {
+	http    *http.Client
+	baseURL string
+	token   string
+}
+
+// newClient returns a client of the listener at address, which is either a
+// URL such as http://localhost:51678 or the path of a unix socket, optionally
+// prefixed with unix://. Unix sockets use TLS only if opts enables it.
+func newClient(address string, opts tlsOptions, token string) (*client, error) {
+	transport := &http.Transport{}
+	var baseURL string
+	switch {
+	case strings.HasPrefix(address, "unix://") || strings.HasPrefix(address, "/"):
+		path := strings.TrimPrefix(address, "unix://")
+		transport.Dial = func(string, string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+		baseURL = "http://localhost"
+		if opts.enabled {
+			baseURL = "https://localhost"
+		}
+	case strings.HasPrefix(address, "http://") || strings.HasPrefix(address, "https://"):
+		baseURL = strings.TrimSuffix(address, "/")
+	default:
+		return nil, fmt.Errorf("unsupported address %q; expected a URL or a unix socket", address)
+	}
+
+	if strings.HasPrefix(baseURL, "https://") {
+		tlsConfig, err := opts.config()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &client{
+		http:    &http.Client{Transport: transport, Timeout: requestTimeout},
+		baseURL: baseURL,
+		token:   token,
+	}, nil
+}
+
+func (opts tlsOptions) config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.insecure}
+	if opts.caFile != "" {
+		caPEM, err := ioutil.ReadFile(opts.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in " + opts.caFile)
+		}
+	}
+	if opts.certFile != "" || opts.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.certFile, opts.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	// A unix socket has no host name to verify the agent's certificate
+	// against but "localhost"
+	tlsConfig.ServerName = opts.serverName
+	return tlsConfig, nil
+}
+
+// do makes a request and returns the response if it succeeded. Otherwise the
+// response body, which is usually a short explanation, becomes the error.
+func (c *client) do(method, path string, query url.Values) (*http.Response, error) {
+	requestURL := c.baseURL + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, requestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	return nil, &apiError{status: resp.StatusCode, message: errorMessage(body)}
+}
+
+// doJSON makes a request and decodes its JSON response into v
+func (c *client) doJSON(method, path string, query url.Values, v interface{}) error {
+	resp, err := c.do(method, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// apiError is an unsuccessful response from the agent
+type apiError struct {
+	status  int
+	message string
+}
+
+func (err *apiError) Error() string {
+	if err.message == "" {
+		return fmt.Sprintf("agent responded %d %s", err.status, http.StatusText(err.status))
+	}
+	return fmt.Sprintf("agent responded %d: %s", err.status, err.message)
+}
+
+// errorMessage extracts the message from an error response, which is either
+// plain text or, from the v2 API, a JSON object with an Error field
+func errorMessage(body []byte) string {
+	var v2Error struct {
+		Error string
+	}
+	if json.Unmarshal(body, &v2Error) == nil && v2Error.Error != "" {
+		return v2Error.Error
+	}
+	return strings.TrimSpace(string(body))
+}
@@ -0,0 +1,500 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers"
+)
+
+const (
+	// shortIdLength is how much of a docker id is shown in tables, as docker
+	// itself does
+	shortIdLength = 12
+	// maxLogLines is the most log lines the agent returns at once
+	maxLogLines = 10000
+)
+
+// followInterval is how often logs are polled for new lines when following
+// them
+var followInterval = 2 * time.Second
+
+var errNoAdminSocket = errors.New("this command needs the admin socket; set -admin-socket or ECS_INTROSPECTION_ADMIN_SOCKET")
+
+// ctl runs commands against the agent and writes their output
+type ctl struct {
+	out  io.Writer
+	json bool
+	api  *client
+	// admin is nil if no admin socket is configured
+	admin *client
+}
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *ctl, flags *flag.FlagSet, args []string) error
+	// setFlags adds the command's flags, if it has any
+	setFlags func(flags *flag.FlagSet)
+}
+
+var commands = []*command{
+	{name: "tasks", args: "[-status STATUS] [-family FAMILY]", summary: "List tasks", run: (*ctl).tasks, setFlags: taskFilterFlags},
+	{name: "containers", args: "[-status STATUS] [-family FAMILY]", summary: "List containers and the tasks they belong to", run: (*ctl).containers, setFlags: taskFilterFlags},
+	{name: "task", args: "TASK", summary: "Show a task and its containers in detail", run: (*ctl).task},
+	{name: "logs", args: "[-n LINES] [-since TIME] [-f] TASK [CONTAINER]", summary: "Show a container's logs", run: (*ctl).logs, setFlags: logsFlags},
+	{name: "stats", args: "[TASK]", summary: "Show the CPU and memory utilization of containers", run: (*ctl).stats},
+	{name: "metadata", summary: "Show the agent's cluster, container instance and version", run: (*ctl).metadata},
+	{name: "config", summary: "Show the agent's configuration, with secrets redacted (admin)", run: (*ctl).config},
+	{name: "drain", args: "[status | start [-timeout DURATION] | stop]", summary: "Show or change whether the instance is draining (start and stop are admin)", run: (*ctl).drain, setFlags: drainFlags},
+	{name: "cleanup", summary: "Remove all stopped tasks and their containers now (admin)", run: (*ctl).cleanup},
+}
+
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func taskFilterFlags(flags *flag.FlagSet) {
+	flags.String("status", "", "Only list tasks known to be PENDING, RUNNING or STOPPED")
+	flags.String("family", "", "Only list tasks of this task definition family")
+}
+
+func taskFilterQuery(flags *flag.FlagSet) url.Values {
+	query := url.Values{}
+	if status := flagValue(flags, "status"); status != "" {
+		query.Set("status", strings.ToUpper(status))
+	}
+	if family := flagValue(flags, "family"); family != "" {
+		query.Set("family", family)
+	}
+	return query
+}
+
+func logsFlags(flags *flag.FlagSet) {
+	flags.Int("n", 100, "Number of lines to show from the end of the logs")
+	flags.String("since", "", "Only show lines logged after this unix timestamp or RFC 3339 time")
+	flags.Bool("f", false, "Follow the logs, polling for new lines")
+}
+
+func drainFlags(flags *flag.FlagSet) {
+	flags.Duration("timeout", 0, "Stop running tasks after this long; the agent's ECS_DRAIN_TIMEOUT by default")
+}
+
+func flagValue(flags *flag.FlagSet, name string) string {
+	return flags.Lookup(name).Value.String()
+}
+
+func (c *ctl) listTasks(query url.Values) (*handlers.TasksV2Response, error) {
+	resp := &handlers.TasksV2Response{}
+	err := c.api.doJSON("GET", "/v2/tasks", query, resp)
+	return resp, err
+}
+
+func (c *ctl) tasks(flags *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	resp, err := c.listTasks(taskFilterQuery(flags))
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	return c.table(func(w io.Writer) {
+		fmt.Fprintln(w, "TASK\tTASK DEFINITION\tDESIRED\tKNOWN\tCONTAINERS")
+		for _, task := range resp.Tasks {
+			fmt.Fprintf(w, "%s\t%s:%s\t%s\t%s\t%d\n", taskId(task.Arn), task.Family, task.Version,
+				task.DesiredStatus, task.KnownStatus, len(task.Containers))
+		}
+	})
+}
+
+func (c *ctl) containers(flags *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	resp, err := c.listTasks(taskFilterQuery(flags))
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	return c.table(func(w io.Writer) {
+		fmt.Fprintln(w, "DOCKER ID\tDOCKER NAME\tCONTAINER\tTASK\tIMAGE\tKNOWN\tPORTS")
+		for _, task := range resp.Tasks {
+			for _, container := range task.Containers {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(shortId(container.DockerId)),
+					orDash(strings.TrimPrefix(container.DockerName, "/")), container.Name,
+					taskId(task.Arn), container.Image, container.KnownStatus, ports(container.Ports))
+			}
+		}
+	})
+}
+
+func (c *ctl) task(flags *flag.FlagSet, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	task, _, err := c.resolveTask(args[0])
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(task)
+	}
+	return c.table(func(w io.Writer) {
+		fmt.Fprintf(w, "Arn:\t%s\n", task.Arn)
+		fmt.Fprintf(w, "Task definition:\t%s:%s\n", task.Family, task.Version)
+		fmt.Fprintf(w, "Desired status:\t%s\n", task.DesiredStatus)
+		fmt.Fprintf(w, "Known status:\t%s\n", task.KnownStatus)
+		fmt.Fprintf(w, "Sent status:\t%s\n", task.SentStatus)
+		fmt.Fprintf(w, "Started:\t%s\n", formatTime(task.StartedAt))
+		fmt.Fprintf(w, "Stopped:\t%s\n", formatTime(task.StoppedAt))
+		for _, container := range task.Containers {
+			fmt.Fprintf(w, "\nContainer %s\n", container.Name)
+			fmt.Fprintf(w, "  Docker id:\t%s\n", orDash(container.DockerId))
+			fmt.Fprintf(w, "  Docker name:\t%s\n", orDash(strings.TrimPrefix(container.DockerName, "/")))
+			fmt.Fprintf(w, "  Image:\t%s\n", container.Image)
+			fmt.Fprintf(w, "  Desired status:\t%s\n", container.DesiredStatus)
+			fmt.Fprintf(w, "  Known status:\t%s\n", container.KnownStatus)
+			if container.ExitCode != nil {
+				fmt.Fprintf(w, "  Exit code:\t%d\n", *container.ExitCode)
+			}
+			if container.Reason != "" {
+				fmt.Fprintf(w, "  Reason:\t%s\n", container.Reason)
+			}
+			fmt.Fprintf(w, "  Ports:\t%s\n", ports(container.Ports))
+			fmt.Fprintf(w, "  Started:\t%s\n", formatTime(container.StartedAt))
+			fmt.Fprintf(w, "  Finished:\t%s\n", formatTime(container.FinishedAt))
+		}
+	})
+}
+
+// logs writes a container's logs. Following polls for lines logged since the
+// previous poll, so it assumes the agent's clock agrees with this one.
+func (c *ctl) logs(flags *flag.FlagSet, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	lines, _ := strconv.Atoi(flagValue(flags, "n"))
+	follow := flagValue(flags, "f") == "true"
+
+	task, containerName, err := c.resolveTask(args[0])
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		containerName = args[1]
+	}
+	if containerName == "" {
+		if len(task.Containers) != 1 {
+			return fmt.Errorf("task %s has %d containers; name one of them", taskId(task.Arn), len(task.Containers))
+		}
+		containerName = task.Containers[0].Name
+	}
+
+	query := url.Values{}
+	query.Set("taskarn", task.Arn)
+	query.Set("container", containerName)
+	query.Set("tail", strconv.Itoa(lines))
+	if since := flagValue(flags, "since"); since != "" {
+		query.Set("since", since)
+	}
+	for {
+		polled := time.Now()
+		if err := c.copyLogs(query); err != nil {
+			return err
+		}
+		if !follow {
+			return nil
+		}
+		time.Sleep(followInterval)
+		query.Set("tail", strconv.Itoa(maxLogLines))
+		query.Set("since", polled.UTC().Format(time.RFC3339Nano))
+	}
+}
+
+func (c *ctl) copyLogs(query url.Values) error {
+	resp, err := c.api.do("GET", "/v1/logs", query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(c.out, resp.Body)
+	return err
+}
+
+func (c *ctl) stats(flags *flag.FlagSet, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	query := url.Values{}
+	if len(args) == 1 {
+		task, _, err := c.resolveTask(args[0])
+		if err != nil {
+			return err
+		}
+		query.Set("taskarn", task.Arn)
+	}
+	resp := &handlers.StatsV1Response{}
+	if err := c.api.doJSON("GET", "/v1/stats", query, resp); err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	return c.table(func(w io.Writer) {
+		fmt.Fprintln(w, "DOCKER ID\tCONTAINER\tTASK\tCPU %\tMEMORY (MiB)")
+		for _, task := range resp.Tasks {
+			for _, container := range task.Containers {
+				cpu, memory := "-", "-"
+				if container.Current != nil {
+					cpu = strconv.FormatFloat(float64(container.Current.CPUUsagePerc), 'f', 2, 32)
+					memory = strconv.FormatUint(uint64(container.Current.MemoryUsageInMegs), 10)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", shortId(container.DockerId),
+					orDash(container.Name), taskId(task.Arn), cpu, memory)
+			}
+		}
+	})
+}
+
+func (c *ctl) metadata(flags *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	resp := &handlers.MetadataResponse{}
+	if err := c.api.doJSON("GET", "/v1/metadata", nil, resp); err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	return c.table(func(w io.Writer) {
+		fmt.Fprintf(w, "Cluster:\t%s\n", resp.Cluster)
+		containerInstanceArn := ""
+		if resp.ContainerInstanceArn != nil {
+			containerInstanceArn = *resp.ContainerInstanceArn
+		}
+		fmt.Fprintf(w, "Container instance:\t%s\n", orDash(containerInstanceArn))
+		fmt.Fprintf(w, "Version:\t%s\n", resp.Version)
+		names := make([]string, 0, len(resp.Attributes))
+		for name := range resp.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "Attribute %s:\t%s\n", name, resp.Attributes[name])
+		}
+		if recovery := resp.StateRecovery; recovery != nil {
+			fmt.Fprintf(w, "State recovered:\tfrom %s at %s; the corrupt state file is %s\n",
+				recovery.Snapshot, recovery.Time.Format(time.RFC3339), recovery.CorruptFile)
+		}
+	})
+}
+
+// config writes the agent's configuration as JSON whether or not JSON output
+// was asked for, as it has no more readable form
+func (c *ctl) config(flags *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	if c.admin == nil {
+		return errNoAdminSocket
+	}
+	var resp json.RawMessage
+	if err := c.admin.doJSON("GET", "/v1/config", nil, &resp); err != nil {
+		return err
+	}
+	return c.writeJSON(resp)
+}
+
+func (c *ctl) drain(flags *flag.FlagSet, args []string) error {
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	if len(args) > 1 {
+		return errUsage
+	}
+
+	resp := &handlers.DrainV1Response{}
+	var err error
+	switch action {
+	case "status":
+		err = c.api.doJSON("GET", "/v1/drain", nil, resp)
+	case "start", "stop":
+		if c.admin == nil {
+			return errNoAdminSocket
+		}
+		if action == "stop" {
+			err = c.admin.doJSON("DELETE", "/v1/drain", nil, resp)
+			break
+		}
+		query := url.Values{}
+		if timeout := flagValue(flags, "timeout"); timeout != "0s" {
+			query.Set("timeout", timeout)
+		}
+		err = c.admin.doJSON("POST", "/v1/drain", query, resp)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+
+	if !resp.Draining {
+		fmt.Fprintln(c.out, "Not draining")
+		return nil
+	}
+	fmt.Fprintf(c.out, "Draining since %s", formatTime(resp.Since))
+	if resp.Deadline != nil {
+		fmt.Fprintf(c.out, "; running tasks will be stopped at %s", formatTime(resp.Deadline))
+	}
+	fmt.Fprintf(c.out, "\n%d running tasks, %d tasks rejected\n", resp.RunningTasks, resp.RejectedTasks)
+	return nil
+}
+
+func (c *ctl) cleanup(flags *flag.FlagSet, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	if c.admin == nil {
+		return errNoAdminSocket
+	}
+	resp := &handlers.CleanupV1Response{}
+	if err := c.admin.doJSON("POST", "/v1/cleanup", nil, resp); err != nil {
+		return err
+	}
+	if c.json {
+		return c.writeJSON(resp)
+	}
+	fmt.Fprintf(c.out, "Removed %d stopped tasks\n", len(resp.RemovedTasks))
+	for _, arn := range resp.RemovedTasks {
+		fmt.Fprintln(c.out, arn)
+	}
+	return nil
+}
+
+// resolveTask finds the task a TASK argument refers to. If it refers to one
+// of the task's containers, that container's name is also returned.
+func (c *ctl) resolveTask(ref string) (*handlers.TaskV2Response, string, error) {
+	if strings.HasPrefix(ref, "arn:") {
+		task := &handlers.TaskV2Response{}
+		err := c.api.doJSON("GET", "/v2/tasks", url.Values{"taskarn": {ref}}, task)
+		return task, "", err
+	}
+
+	resp, err := c.listTasks(nil)
+	if err != nil {
+		return nil, "", err
+	}
+	var found *handlers.TaskV2Response
+	var containerName string
+	matches := 0
+	for _, task := range resp.Tasks {
+		if taskId(task.Arn) == ref {
+			found, containerName = task, ""
+			matches++
+			continue
+		}
+		for _, container := range task.Containers {
+			if strings.HasPrefix(container.DockerId, ref) || strings.TrimPrefix(container.DockerName, "/") == strings.TrimPrefix(ref, "/") {
+				found, containerName = task, container.Name
+				matches++
+			}
+		}
+	}
+	switch matches {
+	case 0:
+		return nil, "", fmt.Errorf("no task or container matches %q", ref)
+	case 1:
+		return found, containerName, nil
+	}
+	return nil, "", fmt.Errorf("%q matches %d tasks and containers; be more specific", ref, matches)
+}
+
+func (c *ctl) writeJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.out, "%s\n", data)
+	return err
+}
+
+func (c *ctl) table(write func(w io.Writer)) error {
+	w := tabwriter.NewWriter(c.out, 0, 8, 2, ' ', 0)
+	write(w)
+	return w.Flush()
+}
+
+// taskId is the last part of a task arn, which is unique enough to show
+func taskId(arn string) string {
+	return arn[strings.LastIndex(arn, "/")+1:]
+}
+
+func shortId(dockerId string) string {
+	if len(dockerId) > shortIdLength {
+		return dockerId[:shortIdLength]
+	}
+	return dockerId
+}
+
+func ports(bindings []handlers.PortV2Response) string {
+	mapped := []string{}
+	for _, binding := range bindings {
+		mapped = append(mapped, fmt.Sprintf("%d->%d", binding.HostPort, binding.ContainerPort))
+	}
+	if len(mapped) == 0 {
+		return "-"
+	}
+	return strings.Join(mapped, ",")
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,142 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Command ecs-agent-ctl inspects and controls a running agent through its
+// introspection API. It connects to the read-only listener over TCP or a unix
+// socket, and to the admin socket for the commands which change the agent's
+// behaviour or expose its configuration.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+// errUsage is returned by a command given the wrong arguments
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ecs-agent-ctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	host := flags.String("host", defaultHost(), "Introspection API URL, or the path of its unix socket")
+	adminSocket := flags.String("admin-socket", os.Getenv("ECS_INTROSPECTION_ADMIN_SOCKET"), "Path of the admin socket")
+	token := flags.String("token", os.Getenv("ECS_INTROSPECTION_AUTH_TOKEN"), "Bearer token to authenticate with")
+	jsonOutput := flags.Bool("json", false, "Write JSON instead of tables")
+	opts := tlsOptions{}
+	flags.BoolVar(&opts.enabled, "tls", false, "Use TLS over unix sockets; URLs use it if their scheme is https")
+	flags.StringVar(&opts.caFile, "cacert", "", "PEM file of the certificate authorities to verify the agent with")
+	flags.StringVar(&opts.certFile, "cert", "", "PEM client certificate to authenticate with")
+	flags.StringVar(&opts.keyFile, "key", "", "PEM key of -cert")
+	flags.StringVar(&opts.serverName, "server-name", "", "Name to verify the agent's certificate against")
+	flags.BoolVar(&opts.insecure, "insecure", false, "Do not verify the agent's certificate")
+	flags.Usage = func() { usage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		usage(flags, stderr)
+		return 2
+	}
+	cmd := findCommand(flags.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command %q\n", flags.Arg(0))
+		usage(flags, stderr)
+		return 2
+	}
+
+	c := &ctl{out: stdout, json: *jsonOutput}
+	var err error
+	if c.api, err = newClient(*host, opts, *token); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *adminSocket != "" {
+		if c.admin, err = newClient(*adminSocket, opts, *token); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	cmdFlags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmdFlags.SetOutput(stderr)
+	if cmd.setFlags != nil {
+		cmd.setFlags(cmdFlags)
+	}
+	cmdFlags.Usage = func() { commandUsage(cmd, cmdFlags, stderr) }
+	if err := cmdFlags.Parse(flags.Args()[1:]); err != nil {
+		return 2
+	}
+	args = cmdFlags.Args()
+	// The drain action comes before its flags
+	if cmd.name == "drain" && len(args) > 0 {
+		if err := cmdFlags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		args = append([]string{args[0]}, cmdFlags.Args()...)
+	}
+
+	err = cmd.run(c, cmdFlags, args)
+	if err == errUsage {
+		commandUsage(cmd, cmdFlags, stderr)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultHost is where the agent serves the introspection API unless
+// configured otherwise, going by the same environment variables as the agent
+func defaultHost() string {
+	if socket := os.Getenv("ECS_INTROSPECTION_SOCKET"); socket != "" {
+		return socket
+	}
+	port := strconv.Itoa(config.AGENT_INTROSPECTION_PORT)
+	if value := os.Getenv("ECS_INTROSPECTION_PORT"); value != "" {
+		port = value
+	}
+	return "http://localhost:" + port
+}
+
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: ecs-agent-ctl [flags] COMMAND [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-11s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nTASK may be a task arn or its last part, or the full or abbreviated id")
+	fmt.Fprintln(w, "or the name of one of the task's docker containers.")
+	fmt.Fprintln(w, "\nFlags:")
+	flags.PrintDefaults()
+}
+
+func commandUsage(cmd *command, flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintf(w, "Usage: ecs-agent-ctl [flags] %s %s\n\n%s\n", cmd.name, cmd.args, cmd.summary)
+	if cmd.setFlags != nil {
+		fmt.Fprintln(w, "\nFlags:")
+		flags.PrintDefaults()
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/handlers"
+)
+
+var testTasks = &handlers.TasksV2Response{Tasks: []*handlers.TaskV2Response{
+	{
+		Arn:           "arn:aws:ecs:us-west-2:123456789012:task/web-1",
+		Family:        "web",
+		Version:       "3",
+		DesiredStatus: "RUNNING",
+		KnownStatus:   "RUNNING",
+		Containers: []handlers.ContainerV2Response{
+			{Name: "nginx", DockerId: "abcdef0123456789", DockerName: "/ecs-web-3-nginx", Image: "nginx",
+				KnownStatus: "RUNNING", Ports: []handlers.PortV2Response{{ContainerPort: 80, HostPort: 8080}}},
+			{Name: "sidecar", DockerId: "abc9990123456789", DockerName: "/ecs-web-3-sidecar", Image: "busybox",
+				KnownStatus: "RUNNING"},
+		},
+	},
+	{
+		Arn:           "arn:aws:ecs:us-west-2:123456789012:task/batch-1",
+		Family:        "batch",
+		Version:       "1",
+		DesiredStatus: "STOPPED",
+		KnownStatus:   "STOPPED",
+		Containers: []handlers.ContainerV2Response{
+			{Name: "job", DockerId: "0123456789abcdef", DockerName: "/ecs-batch-1-job", Image: "job",
+				KnownStatus: "STOPPED"},
+		},
+	},
+}}
+
+// fakeAgent serves canned introspection responses and records the requests
+// it was sent
+type fakeAgent struct {
+	requests []*http.Request
+}
+
+func (a *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.requests = append(a.requests, r)
+	if r.Header.Get("Authorization") != "Bearer token" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	query := r.URL.Query()
+	switch r.URL.Path {
+	case "/v2/tasks":
+		if arn := query.Get("taskarn"); arn != "" {
+			for _, task := range testTasks.Tasks {
+				if task.Arn == arn {
+					json.NewEncoder(w).Encode(task)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"Error":"No such task"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(testTasks)
+	case "/v1/logs":
+		w.Write([]byte("logs of " + query.Get("container") + "\n"))
+	case "/v1/metadata":
+		arn := "arn:aws:ecs:us-west-2:123456789012:container-instance/1"
+		json.NewEncoder(w).Encode(&handlers.MetadataResponse{Cluster: "default", ContainerInstanceArn: &arn, Version: "1.0"})
+	case "/v1/drain":
+		draining := r.Method == "POST"
+		since := time.Now()
+		resp := &handlers.DrainV1Response{Draining: draining, RunningTasks: 1}
+		if draining {
+			resp.Since = &since
+		}
+		json.NewEncoder(w).Encode(resp)
+	case "/v1/cleanup":
+		json.NewEncoder(w).Encode(&handlers.CleanupV1Response{RemovedTasks: []string{testTasks.Tasks[1].Arn}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func runCtl(t *testing.T, args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := run(append([]string{"-token", "token"}, args...), stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestTasksAndContainers(t *testing.T) {
+	server := httptest.NewServer(&fakeAgent{})
+	defer server.Close()
+
+	status, out, stderr := runCtl(t, "-host", server.URL, "tasks")
+	if status != 0 {
+		t.Fatal("Expected tasks to succeed", stderr)
+	}
+	if !strings.Contains(out, "web-1") || !strings.Contains(out, "web:3") || !strings.Contains(out, "batch-1") {
+		t.Error("Expected both tasks to be listed, got", out)
+	}
+
+	status, out, _ = runCtl(t, "-host", server.URL, "containers")
+	if status != 0 || !strings.Contains(out, "abcdef012345 ") || !strings.Contains(out, "ecs-web-3-nginx") ||
+		!strings.Contains(out, "8080->80") {
+		t.Error("Expected containers with short docker ids, got", out)
+	}
+
+	status, out, _ = runCtl(t, "-host", server.URL, "-json", "tasks")
+	var resp handlers.TasksV2Response
+	if status != 0 || json.Unmarshal([]byte(out), &resp) != nil || len(resp.Tasks) != 2 {
+		t.Error("Expected the tasks as JSON, got", out)
+	}
+}
+
+func TestTaskResolution(t *testing.T) {
+	agent := &fakeAgent{}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	for _, ref := range []string{"web-1", "abcdef", "ecs-web-3-nginx", testTasks.Tasks[0].Arn} {
+		status, out, stderr := runCtl(t, "-host", server.URL, "task", ref)
+		if status != 0 || !strings.Contains(out, testTasks.Tasks[0].Arn) {
+			t.Error("Expected", ref, "to find the web task, got", out, stderr)
+		}
+	}
+
+	status, _, stderr := runCtl(t, "-host", server.URL, "task", "abc")
+	if status != 1 || !strings.Contains(stderr, "matches 2") {
+		t.Error("Expected an ambiguous docker id to be refused, got", stderr)
+	}
+	status, _, stderr = runCtl(t, "-host", server.URL, "task", "arn:aws:ecs:us-west-2:123456789012:task/gone")
+	if status != 1 || !strings.Contains(stderr, "404: No such task") {
+		t.Error("Expected the agent's error, got", stderr)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	agent := &fakeAgent{}
+	server := httptest.NewServer(agent)
+	defer server.Close()
+
+	status, out, _ := runCtl(t, "-host", server.URL, "logs", "-n", "5", "abc999")
+	if status != 0 || out != "logs of sidecar\n" {
+		t.Error("Expected the logs of the container named by its docker id, got", out)
+	}
+	query := agent.requests[len(agent.requests)-1].URL.Query()
+	if query.Get("taskarn") != testTasks.Tasks[0].Arn || query.Get("tail") != "5" {
+		t.Error("Unexpected logs request", query)
+	}
+
+	status, _, stderr := runCtl(t, "-host", server.URL, "logs", "web-1")
+	if status != 1 || !strings.Contains(stderr, "name one of them") {
+		t.Error("Expected a container to be needed, got", stderr)
+	}
+	status, out, _ = runCtl(t, "-host", server.URL, "logs", "batch-1")
+	if status != 0 || out != "logs of job\n" {
+		t.Error("Expected the logs of the only container, got", out)
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ecs-agent-ctl")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "admin.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &fakeAgent{}
+	go http.Serve(listener, admin)
+	defer listener.Close()
+
+	status, _, stderr := runCtl(t, "cleanup")
+	if status != 1 || !strings.Contains(stderr, "admin socket") {
+		t.Error("Expected cleanup to need the admin socket, got", stderr)
+	}
+
+	status, out, stderr := runCtl(t, "-admin-socket", socket, "cleanup")
+	if status != 0 || !strings.Contains(out, "Removed 1 stopped tasks") {
+		t.Error("Expected the stopped task to be removed, got", out, stderr)
+	}
+
+	status, out, stderr = runCtl(t, "-admin-socket", "unix://"+socket, "drain", "start", "-timeout", "5m")
+	if status != 0 || !strings.Contains(out, "Draining since") {
+		t.Error("Expected draining to start, got", out, stderr)
+	}
+	last := admin.requests[len(admin.requests)-1]
+	if last.Method != "POST" || last.URL.Query().Get("timeout") != "5m0s" {
+		t.Error("Unexpected drain request", last.Method, last.URL)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	status, _, stderr := runCtl(t, "frobnicate")
+	if status != 2 || !strings.Contains(stderr, "Unknown command") {
+		t.Error("Expected an unknown command to be refused, got", stderr)
+	}
+	status, _, stderr = runCtl(t, "task")
+	if status != 2 || !strings.Contains(stderr, "ecs-agent-ctl [flags] task TASK") {
+		t.Error("Expected the command's usage, got", stderr)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Command ecs-agent-state validates, prints, summarizes and edits the agent's
+// state file while the agent is stopped. Reading the state is safe while the
+// agent runs, but editing it is refused until the agent has stopped, as the
+// agent would overwrite the edit with its own state.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+// errUsage is returned by a command given the wrong arguments
+var errUsage = errors.New("usage")
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(s *stateTool, args []string) error
+}
+
+var commands = []*command{
+	{name: "validate", summary: "Check that this agent can load the state file", run: (*stateTool).validate},
+	{name: "show", summary: "Pretty-print the state file", run: (*stateTool).show},
+	{name: "summary", summary: "Summarize the instance and tasks in the state file", run: (*stateTool).summary},
+	{name: "drop-task", args: "TASK", summary: "Remove a task, given its arn or the last part of it, from the state file", run: (*stateTool).dropTask},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit status
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ecs-agent-state", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dataDir := flags.String("data-dir", defaultDataDir(), "The agent's data directory")
+	file := flags.String("file", "", "State file to read in place of the one in -data-dir, such as a backup; it cannot be edited")
+	keyFile := flags.String("key-file", "", "File of the keys the state is encrypted with, if not the agent's configured ECS_STATE_ENCRYPTION_KEY or ECS_STATE_ENCRYPTION_KEY_FILE")
+	flags.Usage = func() { usage(flags, stderr) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		usage(flags, stderr)
+		return 2
+	}
+	var cmd *command
+	for _, c := range commands {
+		if c.name == flags.Arg(0) {
+			cmd = c
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command %q\n", flags.Arg(0))
+		usage(flags, stderr)
+		return 2
+	}
+
+	cipher, err := stateCipher(*keyFile)
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+
+	s := &stateTool{out: stdout, dataDir: *dataDir, path: *file, cipher: cipher}
+	if s.path == "" {
+		s.path = statemanager.DataFile(*dataDir)
+	}
+	err = cmd.run(s, flags.Args()[1:])
+	if err == errUsage {
+		fmt.Fprintf(stderr, "Usage: ecs-agent-state [flags] %s %s\n", cmd.name, cmd.args)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// defaultDataDir is the agent's data directory unless configured otherwise
+func defaultDataDir() string {
+	if dataDir := os.Getenv("ECS_DATADIR"); dataDir != "" {
+		return dataDir
+	}
+	return config.DefaultConfig().DataDir
+}
+
+// stateCipher returns the cipher for the state's encryption keys, which are
+// those the agent is configured with unless keyFile is given
+func stateCipher(keyFile string) (*statemanager.Cipher, error) {
+	cfg := config.EnvironmentConfig()
+	cfg.Merge(config.FileConfig())
+	if keyFile != "" {
+		cfg.StateEncryptionKey = ""
+		cfg.StateEncryptionKeyFile = keyFile
+		cfg.StateKeyProvider = ""
+	}
+	return statemanager.CipherFromConfig(&cfg)
+}
+
+func usage(flags *flag.FlagSet, w io.Writer) {
+	fmt.Fprintln(w, "Usage: ecs-agent-state [flags] COMMAND [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	flags.PrintDefaults()
+}
@@ -0,0 +1,134 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+// testDataDir copies the statemanager's version 1 fixture into a temporary
+// data directory
+func testDataDir(t *testing.T) string {
+	data, err := ioutil.ReadFile(filepath.Join("..", "..", "statemanager", "testdata", "ecs_agent_data_v1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataDir, err := ioutil.TempDir("", "ecs-agent-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(statemanager.DataFile(dataDir), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return dataDir
+}
+
+func runTool(t *testing.T, dataDir string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(append([]string{"-data-dir", dataDir}, args...), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestValidate(t *testing.T) {
+	dataDir := testDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	status, out, errOut := runTool(t, dataDir, "validate")
+	if status != 0 {
+		t.Fatalf("Expected success, got %d: %s", status, errOut)
+	}
+	if !strings.Contains(out, "data version 1, 2 tasks") {
+		t.Error("Unexpected output:", out)
+	}
+
+	ioutil.WriteFile(statemanager.DataFile(dataDir), []byte(`{"Version":1,"Data":{"TaskEngine":"bogus"}}`), 0600)
+	status, _, errOut = runTool(t, dataDir, "validate")
+	if status != 1 || !strings.Contains(errOut, "TaskEngine") {
+		t.Errorf("Expected the bad TaskEngine to be reported, got %d: %s", status, errOut)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dataDir := testDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	status, out, errOut := runTool(t, dataDir, "summary")
+	if status != 0 {
+		t.Fatalf("Expected success, got %d: %s", status, errOut)
+	}
+	for _, expected := range []string{"i-0123abcd", "Attribute stack:", "task/web-1", "RUNNING", "nginx=3f2c9a1b7d4e", "2 tasks"} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in the summary:\n%s", expected, out)
+		}
+	}
+}
+
+func TestDropTask(t *testing.T) {
+	dataDir := testDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	status, out, errOut := runTool(t, dataDir, "drop-task", "batch-1")
+	if status != 0 {
+		t.Fatalf("Expected success, got %d: %s", status, errOut)
+	}
+	if !strings.Contains(out, "task/batch-1") {
+		t.Error("Unexpected output:", out)
+	}
+	backups, _ := filepath.Glob(statemanager.DataFile(dataDir) + ".bak-*")
+	if len(backups) != 1 {
+		t.Errorf("Expected one backup, got %v", backups)
+	}
+
+	_, out, _ = runTool(t, dataDir, "summary")
+	if strings.Contains(out, "batch-1") || !strings.Contains(out, "web-1") {
+		t.Error("Expected only batch-1 to be dropped:\n", out)
+	}
+
+	status, _, errOut = runTool(t, dataDir, "drop-task", "batch-1")
+	if status != 1 || !strings.Contains(errOut, "no task") {
+		t.Errorf("Expected an unknown task error, got %d: %s", status, errOut)
+	}
+}
+
+func TestDropTaskWhileLocked(t *testing.T) {
+	dataDir := testDataDir(t)
+	defer os.RemoveAll(dataDir)
+
+	lock, err := statemanager.LockDataDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Close()
+
+	status, _, errOut := runTool(t, dataDir, "drop-task", "batch-1")
+	if status != 1 || !strings.Contains(errOut, "stop it") {
+		t.Errorf("Expected the locked data directory to be reported, got %d: %s", status, errOut)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if status, _, _ := runTool(t, "/nonexistent", "bogus"); status != 2 {
+		t.Error("Expected an unknown command to be a usage error")
+	}
+	if status, _, _ := runTool(t, "/nonexistent", "drop-task"); status != 2 {
+		t.Error("Expected drop-task without a task to be a usage error")
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+// backupTimeFormat names the backup taken before an edit
+const backupTimeFormat = "20060102T150405Z"
+
+// stateTool reads and edits one state file
+type stateTool struct {
+	out     io.Writer
+	dataDir string
+	path    string
+	cipher  *statemanager.Cipher // decrypts and encrypts the state, if it is encrypted
+}
+
+// instance is the state file's contents, loaded as the agent would load them.
+// The names match those the agent adds its saveables under.
+type instance struct {
+	TaskEngine           *dockerstate.DockerTaskEngineState
+	ContainerInstanceArn string
+	Cluster              string
+	EC2InstanceID        string
+	InstanceAttributes   map[string]string
+}
+
+// read reads the state file and upgrades it to the current data version. It
+// returns the version it was saved at.
+func (s *stateTool) read() (*statemanager.RawState, int, error) {
+	raw, err := statemanager.ReadRawState(s.path, s.cipher)
+	if err != nil {
+		return nil, 0, err
+	}
+	version := raw.Version
+	if err := raw.Upgrade(); err != nil {
+		return nil, 0, err
+	}
+	return raw, version, nil
+}
+
+// load decodes each part of the state the agent knows of. It returns the
+// names of any it does not know of.
+func load(raw *statemanager.RawState) (*instance, []string, error) {
+	inst := &instance{TaskEngine: dockerstate.NewDockerTaskEngineState()}
+	saveables := map[string]interface{}{
+		"TaskEngine":           inst.TaskEngine,
+		"ContainerInstanceArn": &inst.ContainerInstanceArn,
+		"Cluster":              &inst.Cluster,
+		"EC2InstanceID":        &inst.EC2InstanceID,
+		"InstanceAttributes":   &inst.InstanceAttributes,
+	}
+	unknown := []string{}
+	for name, data := range raw.Data {
+		saveable, ok := saveables[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		if err := json.Unmarshal(data, saveable); err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	sort.Strings(unknown)
+	return inst, unknown, nil
+}
+
+func (s *stateTool) validate(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	raw, version, err := s.read()
+	if err != nil {
+		return err
+	}
+	inst, unknown, err := load(raw)
+	if err != nil {
+		return err
+	}
+	for _, name := range unknown {
+		fmt.Fprintf(s.out, "Warning: %s is not known to this agent and would be ignored\n", name)
+	}
+	fmt.Fprintf(s.out, "%s is valid: data version %d, %d tasks\n", s.path, version, len(inst.TaskEngine.AllTasks()))
+	for _, migration := range statemanager.PendingMigrations(version) {
+		fmt.Fprintf(s.out, "It would be migrated from version %s\n", migration)
+	}
+	return nil
+}
+
+// show prints the state file as it is on disk, without upgrading it
+func (s *stateTool) show(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	raw, err := statemanager.ReadRawState(s.path, s.cipher)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "%s\n", data)
+	return err
+}
+
+func (s *stateTool) summary(args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	raw, version, err := s.read()
+	if err != nil {
+		return err
+	}
+	inst, _, err := load(raw)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(s.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "Data version:\t%d\n", version)
+	fmt.Fprintf(w, "Cluster:\t%s\n", inst.Cluster)
+	fmt.Fprintf(w, "Container instance:\t%s\n", inst.ContainerInstanceArn)
+	fmt.Fprintf(w, "EC2 instance:\t%s\n", inst.EC2InstanceID)
+	names := make([]string, 0, len(inst.InstanceAttributes))
+	for name := range inst.InstanceAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "Attribute %s:\t%s\n", name, inst.InstanceAttributes[name])
+	}
+	w.Flush()
+
+	tasks := inst.TaskEngine.AllTasks()
+	sort.Sort(tasksByArn(tasks))
+	fmt.Fprintf(s.out, "\n%d tasks\n", len(tasks))
+	w = tabwriter.NewWriter(s.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tTASK DEFINITION\tDESIRED\tKNOWN\tSENT\tCONTAINERS")
+	for _, task := range tasks {
+		containerMap, _ := inst.TaskEngine.ContainerMapByArn(task.Arn)
+		containers := []string{}
+		for _, container := range task.Containers {
+			dockerId := "-"
+			if dockerContainer, ok := containerMap[container.Name]; ok && dockerContainer.DockerId != "" {
+				dockerId = shortId(dockerContainer.DockerId)
+			}
+			containers = append(containers, container.Name+"="+dockerId)
+		}
+		fmt.Fprintf(w, "%s\t%s:%s\t%s\t%s\t%s\t%s\n", task.Arn, task.Family, task.Version,
+			task.DesiredStatus.BackendStatus(), task.KnownStatus.BackendStatus(),
+			task.SentStatus.BackendStatus(), strings.Join(containers, ","))
+	}
+	return w.Flush()
+}
+
+// dropTask removes a task the agent should forget, such as one whose
+// containers were removed by hand. The agent's data directory is locked while
+// the file is edited, and the file is backed up first.
+func (s *stateTool) dropTask(args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if s.path != statemanager.DataFile(s.dataDir) {
+		return errors.New("only the state file in the data directory can be edited")
+	}
+	lock, err := statemanager.LockDataDir(s.dataDir)
+	if err == statemanager.ErrDataDirLocked {
+		return errors.New("the agent appears to be running; stop it before editing its state")
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	raw, _, err := s.read()
+	if err != nil {
+		return err
+	}
+	inst, _, err := load(raw)
+	if err != nil {
+		return err
+	}
+	task, err := findTask(inst.TaskEngine, args[0])
+	if err != nil {
+		return err
+	}
+	inst.TaskEngine.RemoveTask(task)
+	raw.Data["TaskEngine"], err = json.Marshal(inst.TaskEngine)
+	if err != nil {
+		return err
+	}
+
+	backup, err := s.backup()
+	if err != nil {
+		return err
+	}
+	if err := statemanager.WriteRawState(s.path, raw, s.cipher); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "Dropped task %s; the previous state was saved to %s\n", task.Arn, backup)
+	return nil
+}
+
+// backup copies the state file alongside itself and returns the copy's path
+func (s *stateTool) backup() (string, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	backup := s.path + ".bak-" + time.Now().UTC().Format(backupTimeFormat)
+	return backup, ioutil.WriteFile(backup, data, 0600)
+}
+
+// findTask finds a task by its arn or the last part of it
+func findTask(state *dockerstate.DockerTaskEngineState, ref string) (*api.Task, error) {
+	if task, ok := state.TaskByArn(ref); ok {
+		return task, nil
+	}
+	var found *api.Task
+	for _, task := range state.AllTasks() {
+		if strings.HasSuffix(task.Arn, "/"+ref) {
+			if found != nil {
+				return nil, fmt.Errorf("%q matches more than one task; give its arn", ref)
+			}
+			found = task
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no task %q in the state file", ref)
+	}
+	return found, nil
+}
+
+func shortId(dockerId string) string {
+	if len(dockerId) > 12 {
+		return dockerId[:12]
+	}
+	return dockerId
+}
+
+type tasksByArn []*api.Task
+
+func (tasks tasksByArn) Len() int           { return len(tasks) }
+func (tasks tasksByArn) Less(i, j int) bool { return tasks[i].Arn < tasks[j].Arn }
+func (tasks tasksByArn) Swap(i, j int)      { tasks[i], tasks[j] = tasks[j], tasks[i] }
@@ -0,0 +1,94 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Command ecs-backend-emulator runs the ECS API and the ACS and TCS websocket
+// backends locally so the agent can be tested end to end. Point the agent at
+// it with:
+//
+//	ECS_BACKEND_HOST=localhost:8443
+//	ECS_METRICS_BACKEND_HOST=https://localhost:8443/tcs/
+//	ECS_EXTERNAL_INSTANCE=true
+//
+// along with any AWS credentials and region, and run the agent with -k so it
+// accepts the emulator's self-signed certificate. The emulator is scripted
+// through the control API; see emulator.ControlHandler.
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
+	"github.com/aws/amazon-ecs-agent/agent/emulator"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("ecs-backend-emulator")
+
+func main() {
+	listen := flag.String("listen", ":8443", "Address to serve the backend on, with TLS")
+	control := flag.String("control", "127.0.0.1:8080", "Address to serve the control API on")
+	certFile := flag.String("cert", "", "TLS certificate; a self-signed one is generated if unset")
+	keyFile := flag.String("key", "", "TLS key for -cert")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "Comma separated hosts of the generated certificate")
+	heartbeat := flag.Duration("heartbeat", time.Minute, "Interval to send ACS and TCS heartbeats at; 0 to only send them through the control API")
+	flag.Parse()
+
+	var cert tls.Certificate
+	var err error
+	if *certFile != "" {
+		cert, err = tls.LoadX509KeyPair(*certFile, *keyFile)
+	} else {
+		cert, err = emulator.SelfSignedCertificate(strings.Split(*hosts, ","))
+	}
+	if err != nil {
+		log.Crit("Unable to load the TLS certificate", "err", err)
+		os.Exit(1)
+	}
+
+	e := emulator.New()
+	if *heartbeat > 0 {
+		go sendHeartbeats(e, *heartbeat)
+	}
+	go func() {
+		log.Info("Serving the control API", "addr", *control)
+		err := http.ListenAndServe(*control, e.ControlHandler())
+		log.Crit("Control API stopped", "err", err)
+		os.Exit(1)
+	}()
+
+	server := &http.Server{
+		Addr:      *listen,
+		Handler:   e.Handler(),
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	log.Info("Serving the backend", "addr", *listen)
+	err = server.ListenAndServeTLS("", "")
+	log.Crit("Backend stopped", "err", err)
+	os.Exit(1)
+}
+
+// sendHeartbeats keeps the agent's connections alive; the agent disconnects
+// when it has not heard a heartbeat for several minutes
+func sendHeartbeats(e *emulator.Emulator, interval time.Duration) {
+	healthy := true
+	for range time.Tick(interval) {
+		e.Send(emulator.ServiceACS, &ecsacs.HeartbeatMessage{Healthy: &healthy})
+		e.Send(emulator.ServiceTCS, &ecstcs.HeartbeatMessage{Healthy: &healthy})
+	}
+}
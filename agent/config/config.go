@@ -15,14 +15,18 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/ec2"
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 )
@@ -37,10 +41,51 @@ const (
 	SSH_PORT = 22
 
 	AGENT_INTROSPECTION_PORT = 51678
+	// TASK_METADATA_PORT is reserved in addition to the introspection port
+	// when the task metadata server is enabled
+	TASK_METADATA_PORT = 51679
+
+	DEFAULT_TASK_METADATA_ADDRESS = "172.17.42.1"
 
 	DEFAULT_CLUSTER_NAME = "default"
+
+	DEFAULT_TASK_STOP_TIMEOUT = 2 * time.Minute
+
+	DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+
+	DEFAULT_STATE_SNAPSHOTS = 3
+)
+
+// Shutdown policies, which decide what the agent does with its tasks when it
+// is asked to exit
+const (
+	// ShutdownPolicyLeaveRunning saves state and exits straight away,
+	// leaving tasks running and any unsubmitted state changes to be
+	// resubmitted when the agent next starts
+	ShutdownPolicyLeaveRunning = "leave-running"
+	// ShutdownPolicyStopTasks stops every task and waits for their final
+	// state changes to be submitted before exiting
+	ShutdownPolicyStopTasks = "stop-tasks"
+	// ShutdownPolicyWait leaves tasks running, but waits for transitions
+	// already in progress and the state changes they cause to be submitted
+	// before exiting
+	ShutdownPolicyWait = "wait"
+)
+
+// State backends, which decide how the agent saves its state when Checkpoint
+// is enabled
+const (
+	// StateBackendJSON saves the whole state to a JSON file at most every
+	// ten seconds
+	StateBackendJSON = "json"
+	// StateBackendKV saves each change to tasks and containers as it
+	// happens to a key-value store
+	StateBackendKV = "kv"
 )
 
+// redactedValue replaces the values of secret fields in Redacted configs
+const redactedValue = "REDACTED"
+
 // Merge merges two config files, preferring the ones on the left. Any nil or
 // zero values present in the left that are not present in the right will be
 // overridden
@@ -105,14 +150,47 @@ func (cfg *Config) CheckMissingAndDepreciated() {
 	}
 }
 
+// Redacted returns a copy of the config with the values of all fields tagged
+// `secret:"true"` replaced, such that it may be logged or written to disk
+func (cfg *Config) Redacted() Config {
+	redacted := *cfg
+	cfgElem := reflect.ValueOf(&redacted).Elem()
+	cfgStructField := cfgElem.Type()
+
+	for i := 0; i < cfgElem.NumField(); i++ {
+		cfgField := cfgElem.Field(i)
+		if cfgStructField.Field(i).Tag.Get("secret") != "true" || utils.ZeroOrNil(cfgField.Interface()) {
+			continue
+		}
+		switch cfgField.Interface().(type) {
+		case string:
+			cfgField.SetString(redactedValue)
+		case json.RawMessage:
+			cfgField.SetBytes([]byte(`"` + redactedValue + `"`))
+		default:
+			cfgField.Set(reflect.Zero(cfgField.Type()))
+		}
+	}
+	return redacted
+}
+
 func DefaultConfig() Config {
 	awsRegion := "us-west-2"
 	return Config{
-		APIEndpoint:    ecsEndpoint(awsRegion),
-		DockerEndpoint: "unix:///var/run/docker.sock",
-		AWSRegion:      awsRegion,
-		ReservedPorts:  []uint16{SSH_PORT, DOCKER_RESERVED_PORT, DOCKER_RESERVED_SSL_PORT, AGENT_INTROSPECTION_PORT},
-		DataDir:        "/data/",
+		APIEndpoint:     ecsEndpoint(awsRegion),
+		DockerEndpoint:  "unix:///var/run/docker.sock",
+		AWSRegion:       awsRegion,
+		ReservedPorts:   []uint16{SSH_PORT, DOCKER_RESERVED_PORT, DOCKER_RESERVED_SSL_PORT, AGENT_INTROSPECTION_PORT},
+		DataDir:         "/data/",
+		TaskStopTimeout: DEFAULT_TASK_STOP_TIMEOUT,
+		StateBackend:    StateBackendJSON,
+		StateSnapshots:  DEFAULT_STATE_SNAPSHOTS,
+
+		ShutdownPolicy:  ShutdownPolicyLeaveRunning,
+		ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+
+		TaskMetadataAddress: DEFAULT_TASK_METADATA_ADDRESS,
+		IntrospectionPort:   AGENT_INTROSPECTION_PORT,
 	}
 }
 
@@ -182,22 +260,172 @@ func EnvironmentConfig() Config {
 		log.Warn("Invalid format for \"ECS_RESERVED_PORTS\" environment variable; expected a JSON array like [1,2,3].", "err", err)
 	}
 
+	// Format: json object, e.g. {"stack":"prod","team":"web"}
+	instanceAttributesEnv := os.Getenv("ECS_INSTANCE_ATTRIBUTES")
+	attributeDecoder := json.NewDecoder(strings.NewReader(instanceAttributesEnv))
+	var instanceAttributes map[string]string
+	err = attributeDecoder.Decode(&instanceAttributes)
+	if err != io.EOF && err != nil {
+		log.Warn("Invalid format for \"ECS_INSTANCE_ATTRIBUTES\" environment variable; expected a JSON object like {\"key\":\"value\"}.", "err", err)
+		instanceAttributes = nil
+	}
+
+	// Format: go duration, e.g. 90s or 5m
+	var taskStopTimeout time.Duration
+	if taskStopTimeoutEnv := os.Getenv("ECS_TASK_STOP_TIMEOUT"); taskStopTimeoutEnv != "" {
+		taskStopTimeout, err = time.ParseDuration(taskStopTimeoutEnv)
+		if err != nil || taskStopTimeout <= 0 {
+			log.Warn("Invalid format for \"ECS_TASK_STOP_TIMEOUT\" environment variable; expected a positive duration like 90s.", "err", err)
+			taskStopTimeout = 0
+		}
+	}
+
+	// Format: go duration, e.g. 30m
+	var drainTimeout time.Duration
+	if drainTimeoutEnv := os.Getenv("ECS_DRAIN_TIMEOUT"); drainTimeoutEnv != "" {
+		drainTimeout, err = time.ParseDuration(drainTimeoutEnv)
+		if err != nil || drainTimeout < 0 {
+			log.Warn("Invalid format for \"ECS_DRAIN_TIMEOUT\" environment variable; expected a duration like 30m.", "err", err)
+			drainTimeout = 0
+		}
+	}
+
+	stateBackend := os.Getenv("ECS_STATE_BACKEND")
+	switch stateBackend {
+	case "", StateBackendJSON, StateBackendKV:
+	default:
+		log.Warn("Invalid value for \"ECS_STATE_BACKEND\" environment variable; expected json or kv.", "backend", stateBackend)
+		stateBackend = ""
+	}
+
+	var stateSnapshots int
+	if stateSnapshotsEnv := os.Getenv("ECS_STATE_SNAPSHOTS"); stateSnapshotsEnv != "" {
+		stateSnapshots, err = strconv.Atoi(stateSnapshotsEnv)
+		if err != nil || stateSnapshots <= 0 {
+			log.Warn("Invalid format for \"ECS_STATE_SNAPSHOTS\" environment variable; expected a positive number.", "err", err)
+			stateSnapshots = 0
+		}
+	}
+
+	shutdownPolicy := os.Getenv("ECS_SHUTDOWN_POLICY")
+	switch shutdownPolicy {
+	case "", ShutdownPolicyLeaveRunning, ShutdownPolicyStopTasks, ShutdownPolicyWait:
+	default:
+		log.Warn("Invalid value for \"ECS_SHUTDOWN_POLICY\" environment variable; expected one of leave-running, stop-tasks or wait.", "policy", shutdownPolicy)
+		shutdownPolicy = ""
+	}
+
+	// Format: go duration, e.g. 90s or 5m
+	var shutdownTimeout time.Duration
+	if shutdownTimeoutEnv := os.Getenv("ECS_SHUTDOWN_TIMEOUT"); shutdownTimeoutEnv != "" {
+		shutdownTimeout, err = time.ParseDuration(shutdownTimeoutEnv)
+		if err != nil || shutdownTimeout <= 0 {
+			log.Warn("Invalid format for \"ECS_SHUTDOWN_TIMEOUT\" environment variable; expected a positive duration like 90s.", "err", err)
+			shutdownTimeout = 0
+		}
+	}
+
+	// Format: json array, e.g. ["sidecar","labels"]
+	taskTransformersEnv := os.Getenv("ECS_TASK_TRANSFORMERS")
+	transformerDecoder := json.NewDecoder(strings.NewReader(taskTransformersEnv))
+	var taskTransformers []string
+	err = transformerDecoder.Decode(&taskTransformers)
+	if err != io.EOF && err != nil {
+		log.Warn("Invalid format for \"ECS_TASK_TRANSFORMERS\" environment variable; expected a JSON array like [\"name\"].", "err", err)
+		taskTransformers = nil
+	}
+
+	// Format: json object of hook lists, e.g. {"post-start":[{"Path":"/usr/local/bin/register"}]}
+	lifecycleHooksEnv := os.Getenv("ECS_LIFECYCLE_HOOKS")
+	hookDecoder := json.NewDecoder(strings.NewReader(lifecycleHooksEnv))
+	var lifecycleHooks map[string][]HookConfig
+	err = hookDecoder.Decode(&lifecycleHooks)
+	if err != io.EOF && err != nil {
+		log.Warn("Invalid format for \"ECS_LIFECYCLE_HOOKS\" environment variable; expected a JSON object of hook lists.", "err", err)
+		lifecycleHooks = nil
+	}
+
+	externalInstance := utils.ParseBool(os.Getenv("ECS_EXTERNAL_INSTANCE"), false)
+
+	taskMetadataEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_METADATA"), false)
+	taskMetadataAddress := os.Getenv("ECS_TASK_METADATA_ADDRESS")
+	taskIAMRoleEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_TASK_IAM_ROLE"), false)
+	containerMetadataEnabled := utils.ParseBool(os.Getenv("ECS_ENABLE_CONTAINER_METADATA"), false)
+	dataDirOnHost := os.Getenv("ECS_HOST_DATA_DIR")
+
+	var introspectionPort uint16
+	if introspectionPortEnv := os.Getenv("ECS_INTROSPECTION_PORT"); introspectionPortEnv != "" {
+		port, err := strconv.ParseUint(introspectionPortEnv, 10, 16)
+		if err != nil || port == 0 {
+			log.Warn("Invalid format for \"ECS_INTROSPECTION_PORT\" environment variable; expected a port number.", "err", err)
+		} else {
+			introspectionPort = uint16(port)
+		}
+	}
+
 	updateDownloadDir := os.Getenv("ECS_UPDATE_DOWNLOAD_DIR")
 	updatesEnabled := utils.ParseBool(os.Getenv("ECS_UPDATES_ENABLED"), false)
 
 	return Config{
-		Cluster:           clusterRef,
-		APIEndpoint:       endpoint,
-		AWSRegion:         awsRegion,
-		DockerEndpoint:    dockerEndpoint,
-		ReservedPorts:     reservedPorts,
-		DataDir:           dataDir,
-		Checkpoint:        checkpoint,
-		EngineAuthType:    engineAuthType,
-		EngineAuthData:    []byte(engineAuthData),
-		UpdatesEnabled:    updatesEnabled,
-		UpdateDownloadDir: updateDownloadDir,
+		Cluster:            clusterRef,
+		APIEndpoint:        endpoint,
+		AWSRegion:          awsRegion,
+		DockerEndpoint:     dockerEndpoint,
+		ExternalInstance:   externalInstance,
+		ReservedPorts:      reservedPorts,
+		InstanceAttributes: instanceAttributes,
+		DataDir:            dataDir,
+		Checkpoint:         checkpoint,
+		StateBackend:       stateBackend,
+		StateSnapshots:     stateSnapshots,
+		TaskStopTimeout:    taskStopTimeout,
+		TaskTransformers:   taskTransformers,
+		LifecycleHooks:     lifecycleHooks,
+		EngineAuthType:     engineAuthType,
+		EngineAuthData:     []byte(engineAuthData),
+		UpdatesEnabled:     updatesEnabled,
+		UpdateDownloadDir:  updateDownloadDir,
+
+		TaskMetadataEnabled: taskMetadataEnabled,
+		TaskMetadataAddress: taskMetadataAddress,
+		TaskIAMRoleEnabled:  taskIAMRoleEnabled,
+
+		ContainerMetadataEnabled: containerMetadataEnabled,
+		DataDirOnHost:            dataDirOnHost,
+
+		IntrospectionAddress:      os.Getenv("ECS_INTROSPECTION_ADDRESS"),
+		IntrospectionPort:         introspectionPort,
+		IntrospectionSocket:       os.Getenv("ECS_INTROSPECTION_SOCKET"),
+		IntrospectionTLSCertFile:  os.Getenv("ECS_INTROSPECTION_TLS_CERT"),
+		IntrospectionTLSKeyFile:   os.Getenv("ECS_INTROSPECTION_TLS_KEY"),
+		IntrospectionClientCAFile: os.Getenv("ECS_INTROSPECTION_CLIENT_CA"),
+		IntrospectionAuthToken:    os.Getenv("ECS_INTROSPECTION_AUTH_TOKEN"),
+		IntrospectionAdminSocket:  os.Getenv("ECS_INTROSPECTION_ADMIN_SOCKET"),
+		ProfilingEnabled:          utils.ParseBool(os.Getenv("ECS_ENABLE_PROFILING"), false),
+
+		Draining:     utils.ParseBool(os.Getenv("ECS_DRAINING"), false),
+		DrainTimeout: drainTimeout,
+
+		ShutdownPolicy:  shutdownPolicy,
+		ShutdownTimeout: shutdownTimeout,
+
+		StandaloneTaskDir:    os.Getenv("ECS_STANDALONE_TASK_DIR"),
+		StandaloneStatusFile: os.Getenv("ECS_STANDALONE_STATUS_FILE"),
+
+		StateEncryptionKey:     os.Getenv("ECS_STATE_ENCRYPTION_KEY"),
+		StateEncryptionKeyFile: os.Getenv("ECS_STATE_ENCRYPTION_KEY_FILE"),
+		StateKeyProvider:       os.Getenv("ECS_STATE_KEY_PROVIDER"),
+	}
+}
+
+// reservePort ensures tasks are not placed on a port the agent listens on
+func (config *Config) reservePort(port uint16) {
+	for _, reserved := range config.ReservedPorts {
+		if reserved == port {
+			return
+		}
 	}
+	config.ReservedPorts = append(config.ReservedPorts, port)
 }
 
 func EC2MetadataConfig() Config {
@@ -219,19 +447,52 @@ func NewConfig() (*Config, error) {
 	defer func() {
 		config.CheckMissingAndDepreciated()
 		config.Merge(DefaultConfig())
+		if config.TaskMetadataEnabled || config.TaskIAMRoleEnabled {
+			config.reservePort(TASK_METADATA_PORT)
+		}
+		if config.IntrospectionSocket == "" {
+			config.reservePort(config.IntrospectionPort)
+		}
 	}()
 
 	if config.Complete() {
 		// No need to do file / network IO
+		if err := config.checkCredentials(); err != nil {
+			return nil, err
+		}
 		return config, nil
 	}
 
 	config.Merge(FileConfig())
 
-	if config.AWSRegion == "" || config.APIEndpoint == "" {
+	if config.ExternalInstance {
+		// There is no metadata service to fall back to, and silently using
+		// the default region would register into the wrong place
+		if config.AWSRegion == "" {
+			return nil, errors.New("AWSRegion must be configured explicitly (e.g. with AWS_DEFAULT_REGION) when running as an external instance")
+		}
+		if config.APIEndpoint == "" {
+			config.APIEndpoint = ecsEndpoint(config.AWSRegion)
+		}
+	} else if config.AWSRegion == "" || config.APIEndpoint == "" {
 		// Get it from metadata only if we need to (network io)
 		config.Merge(EC2MetadataConfig())
 	}
+	if err := config.checkCredentials(); err != nil {
+		return nil, err
+	}
 
 	return config, nil
 }
+
+// checkCredentials makes sure that an external instance, which has no
+// instance metadata to fall back to, has credentials configured explicitly
+func (config *Config) checkCredentials() error {
+	if !config.ExternalInstance {
+		return nil
+	}
+	if _, err := credentials.NewEnvironmentCredentialProvider().Credentials(); err != nil {
+		return errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be configured explicitly when running as an external instance")
+	}
+	return nil
+}
@@ -13,7 +13,12 @@
 
 package config
 
-import "testing"
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestMerge(t *testing.T) {
 	conf1 := &Config{Cluster: "Foo"}
@@ -32,3 +37,250 @@ func TestMerge(t *testing.T) {
 		t.Error("Incorrect region")
 	}
 }
+
+func TestMergeInstanceAttributes(t *testing.T) {
+	conf1 := &Config{}
+	conf2 := Config{InstanceAttributes: map[string]string{"stack": "prod"}}
+
+	conf1.Merge(conf2)
+	if conf1.InstanceAttributes["stack"] != "prod" {
+		t.Error("InstanceAttributes should have been merged in")
+	}
+
+	conf3 := Config{InstanceAttributes: map[string]string{"stack": "ignored"}}
+	conf1.Merge(conf3)
+	if conf1.InstanceAttributes["stack"] != "prod" {
+		t.Error("InstanceAttributes should not have been overridden")
+	}
+}
+
+func TestEnvironmentConfigInstanceAttributes(t *testing.T) {
+	os.Setenv("ECS_INSTANCE_ATTRIBUTES", `{"stack":"prod","team":"web"}`)
+	defer os.Unsetenv("ECS_INSTANCE_ATTRIBUTES")
+
+	conf := EnvironmentConfig()
+	if len(conf.InstanceAttributes) != 2 || conf.InstanceAttributes["team"] != "web" {
+		t.Error("Unexpected instance attributes", conf.InstanceAttributes)
+	}
+
+	os.Setenv("ECS_INSTANCE_ATTRIBUTES", `["not", "an", "object"]`)
+	conf = EnvironmentConfig()
+	if conf.InstanceAttributes != nil {
+		t.Error("Invalid instance attributes should be ignored", conf.InstanceAttributes)
+	}
+}
+
+func TestExternalInstanceConfig(t *testing.T) {
+	os.Setenv("ECS_AGENT_CONFIG_FILE_PATH", "/does/not/exist")
+	os.Setenv("ECS_EXTERNAL_INSTANCE", "true")
+	os.Unsetenv("AWS_DEFAULT_REGION")
+	os.Unsetenv("ECS_BACKEND_HOST")
+	defer os.Unsetenv("ECS_AGENT_CONFIG_FILE_PATH")
+	defer os.Unsetenv("ECS_EXTERNAL_INSTANCE")
+
+	_, err := NewConfig()
+	if err == nil {
+		t.Error("Expected an error when no region is configured for an external instance")
+	}
+
+	os.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+	defer os.Unsetenv("AWS_DEFAULT_REGION")
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	_, err = NewConfig()
+	if err == nil || !strings.Contains(err.Error(), "AWS_ACCESS_KEY_ID") {
+		t.Error("Expected an error when no credentials are configured for an external instance, got", err)
+	}
+
+	os.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.ExternalInstance {
+		t.Error("Expected an external instance config")
+	}
+	if cfg.APIEndpoint != "ecs.eu-west-1.amazonaws.com" {
+		t.Error("Expected the endpoint to be derived from the region", cfg.APIEndpoint)
+	}
+}
+
+func TestEnvironmentConfigTaskStopTimeout(t *testing.T) {
+	os.Setenv("ECS_TASK_STOP_TIMEOUT", "90s")
+	defer os.Unsetenv("ECS_TASK_STOP_TIMEOUT")
+
+	conf := EnvironmentConfig()
+	if conf.TaskStopTimeout != 90*time.Second {
+		t.Error("Unexpected task stop timeout", conf.TaskStopTimeout)
+	}
+
+	os.Setenv("ECS_TASK_STOP_TIMEOUT", "-5s")
+	conf = EnvironmentConfig()
+	if conf.TaskStopTimeout != 0 {
+		t.Error("Invalid task stop timeouts should be ignored", conf.TaskStopTimeout)
+	}
+}
+
+func TestEnvironmentConfigDrain(t *testing.T) {
+	os.Setenv("ECS_DRAINING", "true")
+	os.Setenv("ECS_DRAIN_TIMEOUT", "30m")
+	defer os.Unsetenv("ECS_DRAINING")
+	defer os.Unsetenv("ECS_DRAIN_TIMEOUT")
+
+	conf := EnvironmentConfig()
+	if !conf.Draining {
+		t.Error("Expected the agent to start draining")
+	}
+	if conf.DrainTimeout != 30*time.Minute {
+		t.Error("Unexpected drain timeout", conf.DrainTimeout)
+	}
+
+	os.Setenv("ECS_DRAIN_TIMEOUT", "soon")
+	conf = EnvironmentConfig()
+	if conf.DrainTimeout != 0 {
+		t.Error("Invalid drain timeouts should be ignored", conf.DrainTimeout)
+	}
+}
+
+func TestEnvironmentConfigShutdown(t *testing.T) {
+	os.Setenv("ECS_SHUTDOWN_POLICY", "stop-tasks")
+	os.Setenv("ECS_SHUTDOWN_TIMEOUT", "2m")
+	defer os.Unsetenv("ECS_SHUTDOWN_POLICY")
+	defer os.Unsetenv("ECS_SHUTDOWN_TIMEOUT")
+
+	conf := EnvironmentConfig()
+	if conf.ShutdownPolicy != ShutdownPolicyStopTasks {
+		t.Error("Unexpected shutdown policy", conf.ShutdownPolicy)
+	}
+	if conf.ShutdownTimeout != 2*time.Minute {
+		t.Error("Unexpected shutdown timeout", conf.ShutdownTimeout)
+	}
+
+	os.Setenv("ECS_SHUTDOWN_POLICY", "explode")
+	conf = EnvironmentConfig()
+	if conf.ShutdownPolicy != "" {
+		t.Error("Invalid shutdown policies should be ignored", conf.ShutdownPolicy)
+	}
+	if DefaultConfig().ShutdownPolicy != ShutdownPolicyLeaveRunning {
+		t.Error("Expected tasks to be left running by default")
+	}
+}
+
+func TestEnvironmentConfigStateBackend(t *testing.T) {
+	os.Setenv("ECS_STATE_BACKEND", "kv")
+	defer os.Unsetenv("ECS_STATE_BACKEND")
+	if conf := EnvironmentConfig(); conf.StateBackend != StateBackendKV {
+		t.Error("Unexpected state backend", conf.StateBackend)
+	}
+
+	os.Setenv("ECS_STATE_BACKEND", "sqlite")
+	if conf := EnvironmentConfig(); conf.StateBackend != "" {
+		t.Error("Invalid state backends should be ignored", conf.StateBackend)
+	}
+	if DefaultConfig().StateBackend != StateBackendJSON {
+		t.Error("Expected the json state backend by default")
+	}
+}
+
+func TestEnvironmentConfigStateSnapshots(t *testing.T) {
+	os.Setenv("ECS_STATE_SNAPSHOTS", "5")
+	defer os.Unsetenv("ECS_STATE_SNAPSHOTS")
+	if conf := EnvironmentConfig(); conf.StateSnapshots != 5 {
+		t.Error("Unexpected state snapshots", conf.StateSnapshots)
+	}
+
+	os.Setenv("ECS_STATE_SNAPSHOTS", "-1")
+	if conf := EnvironmentConfig(); conf.StateSnapshots != 0 {
+		t.Error("Invalid state snapshots should be ignored", conf.StateSnapshots)
+	}
+	if DefaultConfig().StateSnapshots != DEFAULT_STATE_SNAPSHOTS {
+		t.Error("Unexpected default state snapshots", DefaultConfig().StateSnapshots)
+	}
+}
+
+func TestEnvironmentConfigLifecycleHooks(t *testing.T) {
+	os.Setenv("ECS_LIFECYCLE_HOOKS", `{"post-start":[{"Path":"/bin/notify","Args":["up"],"TimeoutSeconds":5,"FailurePolicy":"fail"}]}`)
+	defer os.Unsetenv("ECS_LIFECYCLE_HOOKS")
+
+	conf := EnvironmentConfig()
+	hooks := conf.LifecycleHooks["post-start"]
+	if len(hooks) != 1 || hooks[0].Path != "/bin/notify" || hooks[0].TimeoutSeconds != 5 || hooks[0].FailurePolicy != "fail" {
+		t.Error("Unexpected lifecycle hooks", conf.LifecycleHooks)
+	}
+
+	os.Setenv("ECS_LIFECYCLE_HOOKS", `[{"Path":"/bin/notify"}]`)
+	conf = EnvironmentConfig()
+	if conf.LifecycleHooks != nil {
+		t.Error("Invalid lifecycle hooks should be ignored", conf.LifecycleHooks)
+	}
+}
+
+func TestReservePort(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.reservePort(TASK_METADATA_PORT)
+	cfg.reservePort(TASK_METADATA_PORT)
+
+	reserved := 0
+	for _, port := range cfg.ReservedPorts {
+		if port == TASK_METADATA_PORT {
+			reserved++
+		}
+	}
+	if reserved != 1 {
+		t.Error("Expected the task metadata port to be reserved once", cfg.ReservedPorts)
+	}
+}
+
+func TestEnvironmentConfigIntrospection(t *testing.T) {
+	defer os.Unsetenv("ECS_INTROSPECTION_PORT")
+	defer os.Unsetenv("ECS_INTROSPECTION_ADDRESS")
+
+	os.Setenv("ECS_INTROSPECTION_PORT", "8080")
+	os.Setenv("ECS_INTROSPECTION_ADDRESS", "127.0.0.1")
+	conf := EnvironmentConfig()
+	if conf.IntrospectionPort != 8080 || conf.IntrospectionAddress != "127.0.0.1" {
+		t.Error("Unexpected introspection listener", conf.IntrospectionAddress, conf.IntrospectionPort)
+	}
+
+	os.Setenv("ECS_INTROSPECTION_PORT", "70000")
+	conf = EnvironmentConfig()
+	if conf.IntrospectionPort != 0 {
+		t.Error("Invalid ports should be ignored", conf.IntrospectionPort)
+	}
+	conf.Merge(DefaultConfig())
+	if conf.IntrospectionPort != AGENT_INTROSPECTION_PORT {
+		t.Error("Expected the default introspection port", conf.IntrospectionPort)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := &Config{
+		Cluster:                "cluster",
+		EngineAuthData:         []byte(`{"index.docker.io":{"auth":"c2VjcmV0"}}`),
+		IntrospectionAuthToken: "token",
+		StateEncryptionKey:     "a2V5",
+	}
+	redacted := cfg.Redacted()
+
+	if redacted.Cluster != "cluster" {
+		t.Error("Expected fields which are not secret to be kept, got", redacted.Cluster)
+	}
+	if redacted.IntrospectionAuthToken != redactedValue {
+		t.Error("Expected the auth token to be redacted, got", redacted.IntrospectionAuthToken)
+	}
+	if string(redacted.EngineAuthData) != `"`+redactedValue+`"` {
+		t.Error("Expected the engine auth data to be redacted, got", string(redacted.EngineAuthData))
+	}
+	if redacted.StateEncryptionKey != redactedValue {
+		t.Error("Expected the state encryption key to be redacted, got", redacted.StateEncryptionKey)
+	}
+	if cfg.IntrospectionAuthToken != "token" {
+		t.Error("Redacting should not modify the original config")
+	}
+	if (&Config{}).Redacted().IntrospectionAuthToken != "" {
+		t.Error("Unset secrets should be left empty")
+	}
+}
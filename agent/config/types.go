@@ -13,7 +13,10 @@
 
 package config
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Config struct {
 	// DEPRECATED
@@ -36,24 +39,160 @@ type Config struct {
 	// AWSRegion is the region to run in (such as "us-east-1"). This value is
 	// used to determine the correct APIEndpoint.
 	AWSRegion string `missing:"warn"`
+	// ExternalInstance indicates that the agent is not running on an EC2
+	// instance. In this mode the EC2 metadata service is never consulted, so
+	// AWSRegion must be configured explicitly, and a generated host ID stored
+	// in DataDir is used to identify the host in place of its instance ID.
+	ExternalInstance bool
 	// ReservedPorts is an array of ports which should be registerd as
 	// unavailable. If not set, they default to [22,2375,2376,51678].
 	ReservedPorts []uint16
+	// InstanceAttributes is a set of arbitrary key/value pairs which will be
+	// attached to this ContainerInstance when it registers. Names and values
+	// are validated before registration; see api.ValidateInstanceAttributes.
+	InstanceAttributes map[string]string
 
 	// DataDir is the directory data is saved to in order to preserve state
 	// across agent restarts. It is only used if "Checkpoint" is true as well.
 	DataDir string
+	// DataDirOnHost is DataDir as seen by the docker daemon, when the agent
+	// runs in a container with DataDir mounted from the host. It is needed to
+	// mount files the agent writes into task containers, and defaults to
+	// DataDir.
+	DataDirOnHost string
 	// Checkpoint configures whether data should be periodically to a checkpoint
 	// file, in DataDir, such that on instance or agent restarts it will resume
 	// as the same ContainerInstance. It defaults to false.
 	Checkpoint bool
+	// StateBackend decides how checkpointed state is saved: json, the
+	// default, rewrites a single file at most every 10 seconds, while kv saves
+	// each change as it happens to a key-value store in DataDir. Switching
+	// to kv moves any state already saved into the store.
+	StateBackend string
+	// StateSnapshots is how many of its previous saves the json state
+	// backend keeps, so that it can recover from a corrupt state file. It
+	// defaults to 3.
+	StateSnapshots int
+	// StateEncryptionKey, StateEncryptionKeyFile and StateKeyProvider each
+	// enable authenticated encryption of the saved state, and at most one
+	// may be set. The key, or the key file's contents, is one or more base64
+	// encoded 32 byte keys separated by commas or whitespace. The first
+	// encrypts the state and the rest decrypt state saved before it was
+	// introduced, so a key is rotated by listing a new one first; state is
+	// encrypted with it on the next save. StateKeyProvider instead names a
+	// registered statemanager.KeyProvider.
+	StateEncryptionKey     string `secret:"true"`
+	StateEncryptionKeyFile string
+	StateKeyProvider       string
 
 	// EngineAuthType configures what type of data is in EngineAuthData.
 	// Supported types, right now, can be found in the dockerauth package: https://godoc.org/github.com/aws/amazon-ecs-agent/agent/engine/dockerauth
 	EngineAuthType string
 	// EngineAuthData contains authentication data. Please see the documentation
 	// for EngineAuthType for more information.
-	EngineAuthData json.RawMessage
+	EngineAuthData json.RawMessage `secret:"true"`
+
+	// TaskStopTimeout bounds how long the agent will spend stopping a task's
+	// containers in dependency order, dependents first. Once it elapses, any
+	// containers still running are stopped regardless of their dependents.
+	// It defaults to 2 minutes.
+	TaskStopTimeout time.Duration
+
+	// Draining, if true, starts the agent draining: it accepts no new tasks,
+	// and reports any it is sent as stopped, so that the instance can be
+	// taken out of service once its running tasks finish. The agent can also
+	// be drained with SIGUSR2 or through the introspection admin socket.
+	Draining bool
+	// DrainTimeout bounds how long running tasks are left to finish once
+	// draining begins, after which they are stopped. If zero, they are left
+	// to finish on their own.
+	DrainTimeout time.Duration
+
+	// StandaloneTaskDir, if set, runs the agent without ACS, TCS or the ECS
+	// API. Instead it runs one task for each task file in this directory,
+	// each a JSON task in the shape ACS sends them in. Adding a file starts
+	// its task, removing it stops the task and changing it replaces the task.
+	StandaloneTaskDir string
+	// StandaloneStatusFile is where a standalone agent writes the state
+	// changes it would otherwise submit to ECS. It defaults to
+	// standalone-status.json in DataDir.
+	StandaloneStatusFile string
+
+	// ShutdownPolicy decides what happens to tasks when the agent receives
+	// SIGTERM or SIGINT: one of leave-running, the default, stop-tasks or
+	// wait. See the ShutdownPolicy constants.
+	ShutdownPolicy string
+	// ShutdownTimeout bounds how long the stop-tasks and wait shutdown
+	// policies wait before saving state and exiting regardless. It defaults
+	// to 30 seconds.
+	ShutdownTimeout time.Duration
+
+	// TaskTransformers lists, in the order they should run, the names of the
+	// task transformer plugins to apply to new tasks. Transformers which are
+	// registered but not listed here are disabled.
+	TaskTransformers []string
+
+	// LifecycleHooks maps a point in a container's lifecycle ("pre-create",
+	// "post-start", "pre-stop" or "post-stop") to executables which should be
+	// run on the host at that point. See HookConfig.
+	LifecycleHooks map[string][]HookConfig
+
+	// TaskMetadataEnabled runs a server which containers can query for the
+	// metadata of their own task, and gives each container the URL to query
+	// in the ECS_CONTAINER_METADATA_URI environment variable. It defaults to
+	// false.
+	TaskMetadataEnabled bool
+	// TaskMetadataAddress is the address of this host as seen from within
+	// containers, used to build their metadata and credentials URLs. The
+	// task metadata server listens only at this address. It defaults to
+	// 172.17.42.1, the address of Docker's default bridge.
+	TaskMetadataAddress string
+	// TaskIAMRoleEnabled serves credentials for a task's IAM role, if it has
+	// one, to its containers from the task metadata server's port. Each
+	// container is given the URL to query in the
+	// ECS_CONTAINER_CREDENTIALS_URI environment variable. The instance's own
+	// role must be allowed to assume task roles. It defaults to false.
+	TaskIAMRoleEnabled bool
+
+	// ContainerMetadataEnabled writes a file describing each container into a
+	// directory under DataDir and mounts it into the container. The file's
+	// path is given in the ECS_CONTAINER_METADATA_FILE environment variable.
+	// It defaults to false.
+	ContainerMetadataEnabled bool
+
+	// IntrospectionAddress is the address of the interface the introspection
+	// API listens on. It defaults to all interfaces.
+	IntrospectionAddress string
+	// IntrospectionPort is the port the introspection API listens on. It is
+	// reserved, and defaults to 51678.
+	IntrospectionPort uint16
+	// IntrospectionSocket is the path of a unix socket for the introspection
+	// API to listen on in place of IntrospectionAddress and IntrospectionPort.
+	// The socket may be used by the agent's user and group.
+	IntrospectionSocket string
+	// IntrospectionTLSCertFile and IntrospectionTLSKeyFile are the PEM encoded
+	// certificate and private key with which the introspection API is served
+	// over TLS. If neither is set, it is served over plain HTTP.
+	IntrospectionTLSCertFile string
+	IntrospectionTLSKeyFile  string
+	// IntrospectionClientCAFile is a PEM file of the certificate authorities
+	// whose client certificates are accepted by the introspection API in
+	// place of IntrospectionAuthToken. It requires TLS.
+	IntrospectionClientCAFile string
+	// IntrospectionAuthToken, if set, must be presented as a bearer token in
+	// the Authorization header of requests to the introspection API which are
+	// not made with an accepted client certificate.
+	IntrospectionAuthToken string `secret:"true"`
+	// IntrospectionAdminSocket is the path of a unix socket on which the
+	// introspection API's endpoints that change the agent's behaviour are
+	// served. Only the agent's user may use it, and the same authentication
+	// applies. These endpoints are never served on the read-only listener,
+	// and are disabled if this is not set.
+	IntrospectionAdminSocket string
+	// ProfilingEnabled serves the net/http/pprof endpoints under /debug/pprof/
+	// on the admin socket. It requires IntrospectionAdminSocket and defaults
+	// to false.
+	ProfilingEnabled bool
 
 	// UpdatesEnabled specifies whether updates should be applied to this agent.
 	// Default true
@@ -63,3 +202,21 @@ type Config struct {
 	// correctly handle them.
 	UpdateDownloadDir string
 }
+
+// HookConfig describes a lifecycle hook executable. The hook is given task and
+// container metadata as JSON on its stdin.
+type HookConfig struct {
+	// Path is the absolute path of the executable to run
+	Path string
+	// Args are additional arguments to pass to the executable
+	Args []string
+	// TimeoutSeconds is how long the hook may run before it, and any
+	// processes it started, are killed and it is considered to have failed.
+	// It defaults to 30 seconds.
+	TimeoutSeconds uint
+	// FailurePolicy is either "ignore", in which case the container carries
+	// on and the failure is only recorded as its reason if it has no other,
+	// or "fail", in which case the container is stopped and the failure is
+	// reported as its reason. It defaults to "ignore".
+	FailurePolicy string
+}
@@ -0,0 +1,194 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package containermetadata writes a file describing each container into a
+// host directory which is bind-mounted, read-only, into that container. This
+// lets processes in the container learn about their task without making any
+// network calls.
+package containermetadata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("containermetadata")
+
+const (
+	// FileName is the name of the metadata file within its directory
+	FileName = "ecs-container-metadata.json"
+	// ContainerDir is where the metadata directory is mounted in containers
+	ContainerDir = "/opt/ecs/metadata"
+	// EnvVar is the environment variable through which containers are given
+	// the path of their metadata file
+	EnvVar = "ECS_CONTAINER_METADATA_FILE"
+
+	metadataDir = "metadata"
+)
+
+// Status tells readers of the metadata file whether it is complete
+type Status string
+
+const (
+	// StatusPending means the container has not started, so details such as
+	// its port bindings are not yet known
+	StatusPending Status = "PENDING"
+	// StatusReady means the file holds everything it ever will
+	StatusReady Status = "READY"
+)
+
+// Metadata is the content of a container's metadata file
+type Metadata struct {
+	Cluster              string
+	ContainerInstanceArn string `json:",omitempty"`
+	TaskArn              string
+	ContainerName        string
+	DockerId             string `json:",omitempty"`
+	DockerName           string `json:",omitempty"`
+	Image                string
+	PortBindings         []api.PortBinding `json:",omitempty"`
+	MetadataFileStatus   Status
+}
+
+// Manager creates, updates and removes metadata files
+type Manager struct {
+	// cfg is read for the cluster as each file is written, as registration
+	// may change it after the Manager is created
+	cfg *config.Config
+	// dataDir is where the agent writes metadata files and dataDirOnHost is
+	// the same directory as docker sees it; they differ when the agent itself
+	// runs in a container
+	dataDir       string
+	dataDirOnHost string
+
+	containerInstanceArn     string
+	containerInstanceArnLock sync.RWMutex
+}
+
+// NewManager creates a Manager which keeps metadata files under cfg.DataDir
+func NewManager(cfg *config.Config) *Manager {
+	dataDirOnHost := cfg.DataDirOnHost
+	if dataDirOnHost == "" {
+		dataDirOnHost = cfg.DataDir
+	}
+	return &Manager{
+		cfg:           cfg,
+		dataDir:       cfg.DataDir,
+		dataDirOnHost: dataDirOnHost,
+	}
+}
+
+// SetContainerInstanceArn sets the ARN written to files from now on. It is
+// not known until the agent has registered.
+func (manager *Manager) SetContainerInstanceArn(arn string) {
+	manager.containerInstanceArnLock.Lock()
+	defer manager.containerInstanceArnLock.Unlock()
+	manager.containerInstanceArn = arn
+}
+
+// Create writes a pending metadata file for a container which is about to be
+// created
+func (manager *Manager) Create(task *api.Task, container *api.Container) error {
+	return manager.write(task, &api.DockerContainer{Container: container}, StatusPending)
+}
+
+// Update rewrites a started container's metadata file with its docker details
+// and resolved port bindings, and marks it ready
+func (manager *Manager) Update(task *api.Task, container *api.DockerContainer) error {
+	return manager.write(task, container, StatusReady)
+}
+
+// Bind returns the docker bind which mounts a container's metadata directory
+func (manager *Manager) Bind(task *api.Task, container *api.Container) string {
+	hostDir := filepath.Join(manager.dataDirOnHost, metadataDir, taskID(task), container.Name)
+	return hostDir + ":" + ContainerDir + ":ro"
+}
+
+// FilePath returns the path of the metadata file as seen from inside the
+// container
+func FilePath() string {
+	return ContainerDir + "/" + FileName
+}
+
+// Clean removes the metadata directories of all of a task's containers
+func (manager *Manager) Clean(task *api.Task) error {
+	return os.RemoveAll(filepath.Join(manager.dataDir, metadataDir, taskID(task)))
+}
+
+func (manager *Manager) write(task *api.Task, container *api.DockerContainer, status Status) error {
+	cluster := manager.cfg.Cluster
+	if cluster == "" {
+		cluster = config.DEFAULT_CLUSTER_NAME
+	}
+	manager.containerInstanceArnLock.RLock()
+	metadata := Metadata{
+		Cluster:              cluster,
+		ContainerInstanceArn: manager.containerInstanceArn,
+		TaskArn:              task.Arn,
+		ContainerName:        container.Container.Name,
+		DockerId:             container.DockerId,
+		DockerName:           container.DockerName,
+		Image:                container.Container.Image,
+		PortBindings:         container.Container.KnownPortBindings,
+		MetadataFileStatus:   status,
+	}
+	manager.containerInstanceArnLock.RUnlock()
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(manager.dataDir, metadataDir, taskID(task), container.Container.Name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// The whole directory is mounted into the container, so a file renamed
+	// into place is seen by readers all at once
+	tmpfile, err := ioutil.TempFile(dir, "tmp_"+FileName)
+	if err != nil {
+		return err
+	}
+	_, err = tmpfile.Write(data)
+	if err == nil {
+		// Processes in the container may not run as root
+		err = tmpfile.Chmod(0644)
+	}
+	tmpfile.Close()
+	if err == nil {
+		err = os.Rename(tmpfile.Name(), filepath.Join(dir, FileName))
+	}
+	if err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	log.Debug("Wrote container metadata", "task", task.Arn, "container", container.Container.Name, "status", status)
+	return nil
+}
+
+// taskID returns the id portion of a task's arn, which is unique and safe to
+// use as a directory name
+func taskID(task *api.Task) string {
+	arn := task.Arn
+	if slash := strings.LastIndex(arn, "/"); slash >= 0 {
+		arn = arn[slash+1:]
+	}
+	return strings.Replace(arn, ":", "_", -1)
+}
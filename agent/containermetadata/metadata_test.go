@@ -0,0 +1,114 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package containermetadata
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+const testTaskArn = "arn:aws:ecs:us-west-2:123456789012:task/a1b2c3"
+
+func readMetadata(t *testing.T, dataDir string, container string) Metadata {
+	data, err := ioutil.ReadFile(filepath.Join(dataDir, metadataDir, "a1b2c3", container, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var metadata Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	return metadata
+}
+
+func TestCreateAndUpdate(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "containermetadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	manager := NewManager(&config.Config{Cluster: "prod", DataDir: dataDir})
+	manager.SetContainerInstanceArn("arn:instance")
+	container := &api.Container{Name: "web", Image: "nginx"}
+	task := &api.Task{Arn: testTaskArn, Containers: []*api.Container{container}}
+
+	if err := manager.Create(task, container); err != nil {
+		t.Fatal(err)
+	}
+	metadata := readMetadata(t, dataDir, "web")
+	if metadata.MetadataFileStatus != StatusPending || metadata.Cluster != "prod" || metadata.ContainerInstanceArn != "arn:instance" || metadata.TaskArn != testTaskArn {
+		t.Error("Unexpected pending metadata", metadata)
+	}
+
+	container.KnownPortBindings = []api.PortBinding{{ContainerPort: 80, HostPort: 32768}}
+	if err := manager.Update(task, &api.DockerContainer{DockerId: "abc", DockerName: "ecs-web", Container: container}); err != nil {
+		t.Fatal(err)
+	}
+	metadata = readMetadata(t, dataDir, "web")
+	if metadata.MetadataFileStatus != StatusReady || metadata.DockerId != "abc" || len(metadata.PortBindings) != 1 {
+		t.Error("Unexpected ready metadata", metadata)
+	}
+
+	if err := manager.Clean(task); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, metadataDir, "a1b2c3")); !os.IsNotExist(err) {
+		t.Error("Expected the task's metadata to be removed", err)
+	}
+}
+
+func TestBindUsesHostDataDir(t *testing.T) {
+	manager := NewManager(&config.Config{DataDir: "/data", DataDirOnHost: "/var/lib/ecs/data"})
+	task := &api.Task{Arn: testTaskArn}
+	bind := manager.Bind(task, &api.Container{Name: "web"})
+	if bind != "/var/lib/ecs/data/metadata/a1b2c3/web:"+ContainerDir+":ro" {
+		t.Error("Unexpected bind", bind)
+	}
+}
+
+func TestClusterReadWhenWritten(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "containermetadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	cfg := &config.Config{DataDir: dataDir}
+	manager := NewManager(cfg)
+	container := &api.Container{Name: "web", Image: "nginx"}
+	task := &api.Task{Arn: testTaskArn, Containers: []*api.Container{container}}
+
+	if err := manager.Create(task, container); err != nil {
+		t.Fatal(err)
+	}
+	if metadata := readMetadata(t, dataDir, "web"); metadata.Cluster != config.DEFAULT_CLUSTER_NAME {
+		t.Error("Expected the default cluster before registration", metadata.Cluster)
+	}
+
+	// Registration sets the cluster the instance ended up in
+	cfg.Cluster = "prod"
+	if err := manager.Update(task, &api.DockerContainer{DockerId: "abc", Container: container}); err != nil {
+		t.Fatal(err)
+	}
+	if metadata := readMetadata(t, dataDir, "web"); metadata.Cluster != "prod" {
+		t.Error("Expected the registered cluster", metadata.Cluster)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package diagnostics writes bundles of information for debugging an agent
+// which has stopped making progress.
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("diagnostics")
+
+// collectTimeout bounds how long each part of a bundle may take to collect.
+// A wedged agent may hold the locks some of them need indefinitely, and the
+// rest of the bundle is still worth having.
+var collectTimeout = 10 * time.Second
+
+// secretFields are the fields of the engine state whose values are left out of
+// bundles. Environment variables often hold credentials, and the tokens
+// identify tasks to the metadata and credentials servers. Bundles are
+// written in plaintext, even when the saved state is encrypted.
+var secretFields = map[string]bool{
+	"environment":   true,
+	"MetadataToken": true,
+	"CredentialsId": true,
+}
+
+const redacted = "REDACTED"
+
+// section is a single file of a bundle
+type section struct {
+	name    string
+	collect func() ([]byte, error)
+}
+
+// WriteBundle writes the goroutine stacks, redacted engine state, pending
+// state changes, redacted config and Docker version of the agent into a new
+// directory in cfg.DataDir, and returns its path
+func WriteBundle(cfg *config.Config, taskEngine engine.TaskEngine) (string, error) {
+	return writeBundle(cfg.DataDir, time.Now(), []section{
+		{"goroutines.txt", goroutineStacks},
+		{"state.json", func() ([]byte, error) {
+			return redactedState(taskEngine)
+		}},
+		{"pending_events.json", func() ([]byte, error) {
+			return json.MarshalIndent(eventhandler.PendingEvents(), "", "  ")
+		}},
+		{"config.json", func() ([]byte, error) {
+			return json.MarshalIndent(cfg.Redacted(), "", "  ")
+		}},
+		{"docker_version.txt", func() ([]byte, error) {
+			version, err := taskEngine.Version()
+			if err != nil {
+				return nil, err
+			}
+			return []byte(version + "\n"), nil
+		}},
+	})
+}
+
+func goroutineStacks() ([]byte, error) {
+	var buf bytes.Buffer
+	err := pprof.Lookup("goroutine").WriteTo(&buf, 2)
+	return buf.Bytes(), err
+}
+
+// redactedState returns the engine state with the values of secretFields
+// replaced
+func redactedState(taskEngine engine.TaskEngine) ([]byte, error) {
+	data, err := taskEngine.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var state interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&state); err != nil {
+		return nil, err
+	}
+	redactSecrets(state)
+	return json.MarshalIndent(state, "", "  ")
+}
+
+// redactSecrets replaces the values of secretFields anywhere in value, which
+// is decoded JSON. The names of environment variables are kept.
+func redactSecrets(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if !secretFields[key] {
+				redactSecrets(field)
+				continue
+			}
+			switch field := field.(type) {
+			case map[string]interface{}:
+				for name := range field {
+					field[name] = redacted
+				}
+			case string:
+				if field != "" {
+					value[key] = redacted
+				}
+			}
+		}
+	case []interface{}:
+		for _, element := range value {
+			redactSecrets(element)
+		}
+	}
+}
+
+// writeBundle collects each section into a file of a new directory in dir.
+// Sections which fail or time out are listed, with why, in errors.txt
+// rather than failing the whole bundle.
+func writeBundle(dir string, now time.Time, sections []section) (string, error) {
+	bundleDir := filepath.Join(dir, "diagnostics-"+now.UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(bundleDir, 0700); err != nil {
+		return "", err
+	}
+
+	var failures bytes.Buffer
+	for _, s := range sections {
+		data, err := collectWithTimeout(s.collect)
+		if err != nil {
+			log.Warn("Unable to collect diagnostics", "file", s.name, "err", err)
+			fmt.Fprintf(&failures, "%s: %v\n", s.name, err)
+			if len(data) == 0 {
+				continue
+			}
+		}
+		if err := ioutil.WriteFile(filepath.Join(bundleDir, s.name), data, 0600); err != nil {
+			return bundleDir, err
+		}
+	}
+	if failures.Len() > 0 {
+		if err := ioutil.WriteFile(filepath.Join(bundleDir, "errors.txt"), failures.Bytes(), 0600); err != nil {
+			return bundleDir, err
+		}
+	}
+	return bundleDir, nil
+}
+
+func collectWithTimeout(collect func() ([]byte, error)) ([]byte, error) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	// Buffered so that a collection which eventually finishes after timing
+	// out doesn't leak its goroutine
+	done := make(chan result, 1)
+	go func() {
+		data, err := collect()
+		done <- result{data, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.data, r.err
+	case <-time.After(collectTimeout):
+		return nil, fmt.Errorf("timed out after %v", collectTimeout)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package diagnostics
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(timeout time.Duration) { collectTimeout = timeout }(collectTimeout)
+	collectTimeout = 10 * time.Millisecond
+	wedged := make(chan struct{})
+	defer close(wedged)
+
+	now := time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC)
+	bundleDir, err := writeBundle(dir, now, []section{
+		{"goroutines.txt", goroutineStacks},
+		{"state.json", func() ([]byte, error) {
+			<-wedged
+			return []byte("{}"), nil
+		}},
+		{"version.txt", func() ([]byte, error) {
+			return nil, errors.New("no docker")
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bundleDir != filepath.Join(dir, "diagnostics-20170304T050607Z") {
+		t.Error("Unexpected bundle directory", bundleDir)
+	}
+
+	stacks, err := ioutil.ReadFile(filepath.Join(bundleDir, "goroutines.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(stacks), "TestWriteBundle") {
+		t.Error("Expected the goroutine stacks to include this test")
+	}
+	for _, name := range []string{"state.json", "version.txt"} {
+		if _, err := os.Stat(filepath.Join(bundleDir, name)); !os.IsNotExist(err) {
+			t.Error("Expected no file for a section which failed", name)
+		}
+	}
+
+	failures, err := ioutil.ReadFile(filepath.Join(bundleDir, "errors.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(failures), "state.json: timed out") || !strings.Contains(string(failures), "version.txt: no docker") {
+		t.Error("Expected the failed sections to be listed, got", string(failures))
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	var state interface{}
+	json.Unmarshal([]byte(`{"Tasks": [{
+		"Arn": "arn:task",
+		"MetadataToken": "token",
+		"CredentialsId": "",
+		"Containers": [{"Name": "web", "environment": {"DB_PASSWORD": "hunter2"}}]
+	}]}`), &state)
+	redactSecrets(state)
+	data, _ := json.Marshal(state)
+
+	for _, secret := range []string{"hunter2", "token\""} {
+		if strings.Contains(string(data), secret) {
+			t.Error("Expected secrets to be redacted", string(data))
+		}
+	}
+	for _, kept := range []string{"arn:task", `"DB_PASSWORD":"REDACTED"`, `"CredentialsId":""`} {
+		if !strings.Contains(string(data), kept) {
+			t.Error("Expected", kept, "to be kept", string(data))
+		}
+	}
+}
@@ -127,3 +127,13 @@ func (this *AmazonEC2ContainerServiceV20141113Client) ListContainerInstances(inp
 	err := this.C.Call("ListContainerInstances", input, &output)
 	return output, err
 }
+func (this *AmazonEC2ContainerServiceV20141113Client) PutAttributes(input PutAttributesRequest) (PutAttributesResponse, error) {
+	var output PutAttributesResponse
+	err := this.C.Call("PutAttributes", input, &output)
+	return output, err
+}
+func (this *AmazonEC2ContainerServiceV20141113Client) DeleteAttributes(input DeleteAttributesRequest) (DeleteAttributesResponse, error) {
+	var output DeleteAttributesResponse
+	err := this.C.Call("DeleteAttributes", input, &output)
+	return output, err
+}
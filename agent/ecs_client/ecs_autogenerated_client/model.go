@@ -39,7 +39,61 @@ type AmazonEC2ContainerServiceV20141113 interface {
 	DescribeTasks(DescribeTasksRequest) (DescribeTasksResponse, error)
 	StopTask(StopTaskRequest) (StopTaskResponse, error)
 	ListContainerInstances(ListContainerInstancesRequest) (ListContainerInstancesResponse, error)
+	PutAttributes(PutAttributesRequest) (PutAttributesResponse, error)
+	DeleteAttributes(DeleteAttributesRequest) (DeleteAttributesResponse, error)
 }
+type Attribute interface {
+	SetName(s *string)
+	Name() *string
+	SetTargetId(s *string)
+	TargetId() *string
+	SetTargetType(s *string)
+	TargetType() *string
+	SetValue(s *string)
+	Value() *string
+}
+type _Attribute struct {
+	Name_       *string `awsjson:"name"`
+	TargetId_   *string `awsjson:"targetId"`
+	TargetType_ *string `awsjson:"targetType"`
+	Value_      *string `awsjson:"value"`
+}
+
+func (this *_Attribute) Name() *string {
+	return this.Name_
+}
+func (this *_Attribute) SetName(s *string) {
+	this.Name_ = s
+}
+func (this *_Attribute) TargetId() *string {
+	return this.TargetId_
+}
+func (this *_Attribute) SetTargetId(s *string) {
+	this.TargetId_ = s
+}
+func (this *_Attribute) TargetType() *string {
+	return this.TargetType_
+}
+func (this *_Attribute) SetTargetType(s *string) {
+	this.TargetType_ = s
+}
+func (this *_Attribute) Value() *string {
+	return this.Value_
+}
+func (this *_Attribute) SetValue(s *string) {
+	this.Value_ = s
+}
+func NewAttribute() Attribute {
+	return &_Attribute{}
+}
+func init() {
+	var val Attribute
+	t := __reflect__.TypeOf(&val)
+	__model__.RegisterShape("Attribute", t, func() interface{} {
+		return NewAttribute()
+	})
+}
+
 type ClientException interface {
 	error
 	SetMessage(s *string)
@@ -451,6 +505,65 @@ func init() {
 	})
 }
 
+type DeleteAttributesRequest interface {
+	SetAttributes(a []Attribute)
+	Attributes() []Attribute
+	SetCluster(s *string)
+	Cluster() *string
+}
+type _DeleteAttributesRequest struct {
+	Attributes_ []Attribute `awsjson:"attributes"`
+	Cluster_    *string     `awsjson:"cluster"`
+}
+
+func (this *_DeleteAttributesRequest) Attributes() []Attribute {
+	return this.Attributes_
+}
+func (this *_DeleteAttributesRequest) SetAttributes(a []Attribute) {
+	this.Attributes_ = a
+}
+func (this *_DeleteAttributesRequest) Cluster() *string {
+	return this.Cluster_
+}
+func (this *_DeleteAttributesRequest) SetCluster(s *string) {
+	this.Cluster_ = s
+}
+func NewDeleteAttributesRequest() DeleteAttributesRequest {
+	return &_DeleteAttributesRequest{}
+}
+func init() {
+	var val DeleteAttributesRequest
+	t := __reflect__.TypeOf(&val)
+	__model__.RegisterShape("DeleteAttributesRequest", t, func() interface{} {
+		return NewDeleteAttributesRequest()
+	})
+}
+
+type DeleteAttributesResponse interface {
+	SetAttributes(a []Attribute)
+	Attributes() []Attribute
+}
+type _DeleteAttributesResponse struct {
+	Attributes_ []Attribute `awsjson:"attributes"`
+}
+
+func (this *_DeleteAttributesResponse) Attributes() []Attribute {
+	return this.Attributes_
+}
+func (this *_DeleteAttributesResponse) SetAttributes(a []Attribute) {
+	this.Attributes_ = a
+}
+func NewDeleteAttributesResponse() DeleteAttributesResponse {
+	return &_DeleteAttributesResponse{}
+}
+func init() {
+	var val DeleteAttributesResponse
+	t := __reflect__.TypeOf(&val)
+	__model__.RegisterShape("DeleteAttributesResponse", t, func() interface{} {
+		return NewDeleteAttributesResponse()
+	})
+}
+
 type DeleteClusterRequest interface {
 	SetCluster(s *string)
 	Cluster() *string
@@ -1385,7 +1498,68 @@ func init() {
 	})
 }
 
+type PutAttributesRequest interface {
+	SetAttributes(a []Attribute)
+	Attributes() []Attribute
+	SetCluster(s *string)
+	Cluster() *string
+}
+type _PutAttributesRequest struct {
+	Attributes_ []Attribute `awsjson:"attributes"`
+	Cluster_    *string     `awsjson:"cluster"`
+}
+
+func (this *_PutAttributesRequest) Attributes() []Attribute {
+	return this.Attributes_
+}
+func (this *_PutAttributesRequest) SetAttributes(a []Attribute) {
+	this.Attributes_ = a
+}
+func (this *_PutAttributesRequest) Cluster() *string {
+	return this.Cluster_
+}
+func (this *_PutAttributesRequest) SetCluster(s *string) {
+	this.Cluster_ = s
+}
+func NewPutAttributesRequest() PutAttributesRequest {
+	return &_PutAttributesRequest{}
+}
+func init() {
+	var val PutAttributesRequest
+	t := __reflect__.TypeOf(&val)
+	__model__.RegisterShape("PutAttributesRequest", t, func() interface{} {
+		return NewPutAttributesRequest()
+	})
+}
+
+type PutAttributesResponse interface {
+	SetAttributes(a []Attribute)
+	Attributes() []Attribute
+}
+type _PutAttributesResponse struct {
+	Attributes_ []Attribute `awsjson:"attributes"`
+}
+
+func (this *_PutAttributesResponse) Attributes() []Attribute {
+	return this.Attributes_
+}
+func (this *_PutAttributesResponse) SetAttributes(a []Attribute) {
+	this.Attributes_ = a
+}
+func NewPutAttributesResponse() PutAttributesResponse {
+	return &_PutAttributesResponse{}
+}
+func init() {
+	var val PutAttributesResponse
+	t := __reflect__.TypeOf(&val)
+	__model__.RegisterShape("PutAttributesResponse", t, func() interface{} {
+		return NewPutAttributesResponse()
+	})
+}
+
 type RegisterContainerInstanceRequest interface {
+	SetAttributes(a []Attribute)
+	Attributes() []Attribute
 	SetCluster(s *string)
 	Cluster() *string
 	SetInstanceIdentityDocument(s *string)
@@ -1396,12 +1570,19 @@ type RegisterContainerInstanceRequest interface {
 	TotalResources() []Resource
 }
 type _RegisterContainerInstanceRequest struct {
-	Cluster_                           *string    `awsjson:"cluster"`
-	InstanceIdentityDocument_          *string    `awsjson:"instanceIdentityDocument"`
-	InstanceIdentityDocumentSignature_ *string    `awsjson:"instanceIdentityDocumentSignature"`
-	TotalResources_                    []Resource `awsjson:"totalResources"`
+	Attributes_                        []Attribute `awsjson:"attributes"`
+	Cluster_                           *string     `awsjson:"cluster"`
+	InstanceIdentityDocument_          *string     `awsjson:"instanceIdentityDocument"`
+	InstanceIdentityDocumentSignature_ *string     `awsjson:"instanceIdentityDocumentSignature"`
+	TotalResources_                    []Resource  `awsjson:"totalResources"`
 }
 
+func (this *_RegisterContainerInstanceRequest) Attributes() []Attribute {
+	return this.Attributes_
+}
+func (this *_RegisterContainerInstanceRequest) SetAttributes(a []Attribute) {
+	this.Attributes_ = a
+}
 func (this *_RegisterContainerInstanceRequest) Cluster() *string {
 	return this.Cluster_
 }
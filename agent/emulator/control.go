@@ -0,0 +1,131 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package emulator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// ControlHandler returns the handler used to script the emulator over plain
+// HTTP. It serves:
+//
+//	GET  /records?since=N     messages received after sequence number N
+//	GET  /connections         the number of agents connected to ACS and TCS
+//	POST /acs/messages        send the ACS message in the request body
+//	POST /tcs/messages        send the TCS message in the request body
+//	POST /acs/close           close the ACS connections
+//	POST /tcs/close           close the TCS connections
+//	POST /failures            queue the Failure in the request body
+//
+// Messages are given in their wire format, for example
+// {"type":"HeartbeatMessage","message":{"healthy":true}}.
+func (e *Emulator) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/records", e.serveRecords)
+	mux.HandleFunc("/connections", e.serveConnections)
+	mux.HandleFunc("/failures", e.serveFailures)
+	for _, service := range []string{ServiceACS, ServiceTCS} {
+		mux.HandleFunc("/"+service+"/messages", e.serveSend(service))
+		mux.HandleFunc("/"+service+"/close", e.serveClose(service))
+	}
+	return mux
+}
+
+func (e *Emulator) serveRecords(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	var since uint64
+	if param := r.URL.Query().Get("since"); param != "" {
+		var err error
+		since, err = strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid since: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	writeJSON(w, e.Records(since))
+}
+
+func (e *Emulator) serveConnections(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "GET") {
+		return
+	}
+	writeJSON(w, map[string]int{
+		ServiceACS: e.Connected(ServiceACS),
+		ServiceTCS: e.Connected(ServiceTCS),
+	})
+}
+
+func (e *Emulator) serveFailures(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, "POST") {
+		return
+	}
+	var failure Failure
+	if err := json.NewDecoder(r.Body).Decode(&failure); err != nil {
+		http.Error(w, "invalid failure: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if failure.Operation == "" || failure.Type == "" {
+		http.Error(w, "a failure needs an operation and a type", http.StatusBadRequest)
+		return
+	}
+	e.Fail(failure)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Emulator) serveSend(service string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sent, err := e.SendJSON(service, data)
+		if err != nil {
+			http.Error(w, "unable to send message: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]int{"sent": sent})
+	}
+}
+
+func (e *Emulator) serveClose(service string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethod(w, r, "POST") {
+			return
+		}
+		writeJSON(w, map[string]int{"closed": e.Close(service)})
+	}
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
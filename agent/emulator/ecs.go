@@ -0,0 +1,122 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ecsTargetPrefix prefixes the operation in the X-Amz-Target header of
+	// every ECS API request
+	ecsTargetPrefix = "AmazonEC2ContainerServiceV20141113."
+
+	// containerInstanceArnFormat is formatted with the cluster name and a
+	// counter to produce a container instance arn
+	containerInstanceArnFormat = "arn:aws:ecs:emulator:000000000000:container-instance/%s/%d"
+	clusterArnFormat           = "arn:aws:ecs:emulator:000000000000:cluster/%s"
+)
+
+// ecsError is the body of a failed ECS API request; the agent decides whether
+// to retry based on the type
+type ecsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+// serveECS handles an ECS API request. Operations the emulator has no
+// particular response for, such as the state change submissions, succeed with
+// an empty response.
+func (e *Emulator) serveECS(w http.ResponseWriter, r *http.Request) {
+	target := r.Header.Get("X-Amz-Target")
+	if r.Method != "POST" || !strings.HasPrefix(target, ecsTargetPrefix) {
+		http.NotFound(w, r)
+		return
+	}
+	operation := strings.TrimPrefix(target, ecsTargetPrefix)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeECSError(w, http.StatusBadRequest, "ClientException", err.Error())
+		return
+	}
+	e.record(ServiceECS, operation, body)
+
+	if failure := e.nextFailure(operation); failure != nil {
+		writeECSError(w, http.StatusBadRequest, failure.Type, failure.Message)
+		return
+	}
+
+	var request struct {
+		Cluster     string `json:"cluster"`
+		ClusterName string `json:"clusterName"`
+	}
+	json.Unmarshal(body, &request)
+
+	var response interface{}
+	switch operation {
+	case "RegisterContainerInstance":
+		response = map[string]interface{}{
+			"containerInstance": map[string]string{
+				"containerInstanceArn": e.newContainerInstanceArn(request.Cluster),
+			},
+		}
+	case "DiscoverPollEndpoint":
+		response = map[string]string{"endpoint": "https://" + r.Host + acsPath}
+	case "CreateCluster":
+		response = map[string]interface{}{"cluster": cluster(request.ClusterName)}
+	case "DescribeClusters":
+		var describe struct {
+			Clusters []string `json:"clusters"`
+		}
+		json.Unmarshal(body, &describe)
+		clusters := []map[string]string{}
+		for _, name := range describe.Clusters {
+			clusters = append(clusters, cluster(name))
+		}
+		response = map[string]interface{}{"clusters": clusters}
+	default:
+		response = map[string]string{}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (e *Emulator) newContainerInstanceArn(cluster string) string {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.instances++
+	return fmt.Sprintf(containerInstanceArnFormat, cluster, e.instances)
+}
+
+// cluster returns an active cluster of the given name. Every cluster exists
+// as far as the emulator is concerned.
+func cluster(name string) map[string]string {
+	return map[string]string{
+		"clusterArn":  fmt.Sprintf(clusterArnFormat, name),
+		"clusterName": name,
+		"status":      "ACTIVE",
+	}
+}
+
+func writeECSError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&ecsError{Type: errType, Message: message})
+}
@@ -0,0 +1,163 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package emulator emulates the ECS backend for end-to-end testing of the
+// agent. It serves the subset of the ECS API the agent calls along with the
+// ACS and TCS websocket protocols, records everything the agent sends, and
+// lets a test script what the backend sends back.
+package emulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+const (
+	// ServiceECS, ServiceACS and ServiceTCS identify the backend a Record
+	// was received by
+	ServiceECS = "ecs"
+	ServiceACS = "acs"
+	ServiceTCS = "tcs"
+
+	// acsPath and tcsPath are the paths the websocket endpoints are served
+	// under. The agent appends "ws" to both.
+	acsPath = "/acs/"
+	tcsPath = "/tcs/"
+)
+
+var log = logger.ForModule("emulator")
+
+// Record is a single request or message received from the agent
+type Record struct {
+	Seq     uint64          `json:"seq"`
+	Time    time.Time       `json:"time"`
+	Service string          `json:"service"`
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+}
+
+// Failure is an error the emulator returns instead of handling a request.
+// For ECS API operations it is returned as the response body; for websocket
+// connections it rejects the handshake.
+type Failure struct {
+	// Operation is the ECS API operation, such as "RegisterContainerInstance",
+	// or ServiceACS or ServiceTCS to reject websocket connections
+	Operation string `json:"operation"`
+	// Type is the modeled exception type, such as "ServerException" or
+	// "ClientException". ClientExceptions are not retried by the agent.
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	// Count is the number of requests to fail; it defaults to one
+	Count int `json:"count,omitempty"`
+}
+
+// Emulator is an in-memory ECS backend. The zero value is not usable; use
+// New.
+type Emulator struct {
+	lock      sync.Mutex
+	records   []Record
+	nextSeq   uint64
+	instances int
+	failures  map[string][]*Failure
+	sessions  map[string]map[*session]struct{}
+}
+
+// New returns an emulator with no recorded messages and no connected agents
+func New() *Emulator {
+	return &Emulator{
+		nextSeq:  1,
+		failures: make(map[string][]*Failure),
+		sessions: map[string]map[*session]struct{}{
+			ServiceACS: make(map[*session]struct{}),
+			ServiceTCS: make(map[*session]struct{}),
+		},
+	}
+}
+
+// Handler returns the handler the agent talks to. It must be served over TLS
+// as the agent only connects to the backend with TLS.
+func (e *Emulator) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", e.serveECS)
+	mux.HandleFunc(acsPath+"ws", e.serveWebsocket(ServiceACS))
+	mux.HandleFunc(tcsPath+"ws", e.serveWebsocket(ServiceTCS))
+	return mux
+}
+
+// Records returns the recorded messages with a sequence number greater than
+// since, oldest first
+func (e *Emulator) Records(since uint64) []Record {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	records := []Record{}
+	for _, record := range e.records {
+		if record.Seq > since {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Fail queues a failure for the next requests of its operation. Failures
+// queued for the same operation are returned in order.
+func (e *Emulator) Fail(failure Failure) {
+	if failure.Count <= 0 {
+		failure.Count = 1
+	}
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failures[failure.Operation] = append(e.failures[failure.Operation], &failure)
+}
+
+// nextFailure returns the failure queued for the operation, if any, and
+// consumes one use of it
+func (e *Emulator) nextFailure(operation string) *Failure {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	queued := e.failures[operation]
+	if len(queued) == 0 {
+		return nil
+	}
+	failure := queued[0]
+	failure.Count--
+	if failure.Count == 0 {
+		e.failures[operation] = queued[1:]
+	}
+	return failure
+}
+
+func (e *Emulator) record(service, messageType string, message []byte) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	record := Record{
+		Seq:     e.nextSeq,
+		Time:    time.Now().UTC(),
+		Service: service,
+		Type:    messageType,
+	}
+	// Anything that is not JSON is recorded by type alone
+	var raw json.RawMessage
+	if json.Unmarshal(message, &raw) == nil {
+		record.Message = raw
+	}
+	e.nextSeq++
+	e.records = append(e.records, record)
+	log.Debug("Received message", "service", service, "type", messageType)
+}
@@ -0,0 +1,219 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package emulator
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	acsclient "github.com/aws/amazon-ecs-agent/agent/acs/client"
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/tcs"
+)
+
+var testCredentials = credentials.NewCredentialProvider("AKIDEXAMPLE", "secret")
+
+func startBackend(t *testing.T, e *Emulator) *httptest.Server {
+	cert, err := SelfSignedCertificate([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(e.Handler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	return server
+}
+
+func waitForRecord(t *testing.T, e *Emulator, service, messageType string) Record {
+	for i := 0; i < 100; i++ {
+		for _, record := range e.Records(0) {
+			if record.Service == service && record.Type == messageType {
+				return record
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("No %s %s recorded", service, messageType)
+	return Record{}
+}
+
+func TestECSAPI(t *testing.T) {
+	e := New()
+	server := startBackend(t, e)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "https://")
+
+	cfg := &config.Config{
+		AWSRegion:        "us-west-2",
+		APIEndpoint:      host,
+		Cluster:          "test",
+		ExternalInstance: true,
+	}
+	client := api.NewECSClient(testCredentials, cfg, true)
+
+	arn, err := client.RegisterContainerInstance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arn != "arn:aws:ecs:emulator:000000000000:container-instance/test/1" {
+		t.Error("Unexpected container instance arn", arn)
+	}
+
+	e.Fail(Failure{Operation: "DiscoverPollEndpoint", Type: "ClientException", Message: "no"})
+	_, err = client.DiscoverPollEndpoint(arn)
+	if err == nil || err.Error() != "no" {
+		t.Fatal("Expected the queued failure, got", err)
+	}
+	if err.(*api.APIError).Retry() {
+		t.Error("Expected a ClientException not to be retried")
+	}
+	endpoint, err := client.DiscoverPollEndpoint(arn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint != server.URL+"/acs/" {
+		t.Error("Unexpected endpoint", endpoint)
+	}
+
+	record := waitForRecord(t, e, ServiceECS, "RegisterContainerInstance")
+	if !strings.Contains(string(record.Message), `"cluster":"test"`) {
+		t.Error("Expected the request to be recorded, got", string(record.Message))
+	}
+	if records := e.Records(record.Seq); len(records) != 2 {
+		t.Error("Expected both DiscoverPollEndpoint requests after the registration, got", records)
+	}
+}
+
+func TestACSSession(t *testing.T) {
+	e := New()
+	server := startBackend(t, e)
+	defer server.Close()
+
+	client := acsclient.New(server.URL+"/acs/ws?clusterArn=test", "us-west-2", testCredentials, true)
+	heartbeats := make(chan *ecsacs.HeartbeatMessage, 1)
+	client.AddRequestHandler(func(message *ecsacs.HeartbeatMessage) {
+		heartbeats <- message
+	})
+	if err := client.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- client.Serve()
+	}()
+	waitForRecord(t, e, ServiceACS, "Connect")
+
+	sent, err := e.SendJSON(ServiceACS, []byte(`{"type":"HeartbeatMessage","message":{"healthy":true}}`))
+	if err != nil || sent != 1 {
+		t.Fatal("Expected the heartbeat to be sent to the agent", sent, err)
+	}
+	select {
+	case heartbeat := <-heartbeats:
+		if heartbeat.Healthy == nil || !*heartbeat.Healthy {
+			t.Error("Expected a healthy heartbeat")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the heartbeat")
+	}
+
+	messageID := "message-1"
+	if err := client.MakeRequest(&ecsacs.AckRequest{MessageId: &messageID}); err != nil {
+		t.Fatal(err)
+	}
+	record := waitForRecord(t, e, ServiceACS, "AckRequest")
+	if string(record.Message) != `{"messageId":"message-1"}` {
+		t.Error("Unexpected ack recorded", string(record.Message))
+	}
+
+	if closed := e.Close(ServiceACS); closed != 1 {
+		t.Error("Expected one connection to be closed, got", closed)
+	}
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the agent to disconnect")
+	}
+	waitForRecord(t, e, ServiceACS, "Disconnect")
+}
+
+func TestWebsocketFailure(t *testing.T) {
+	e := New()
+	server := startBackend(t, e)
+	defer server.Close()
+
+	e.Fail(Failure{Operation: ServiceTCS, Type: "ServerException", Message: "down"})
+	client := tcs.New(server.URL+"/tcs/ws", "us-west-2", testCredentials, true, nil)
+	err := client.Connect()
+	if err == nil || !strings.Contains(err.Error(), "down") {
+		t.Fatal("Expected the handshake to be rejected, got", err)
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatal("Expected only one connection to be rejected, got", err)
+	}
+	waitForRecord(t, e, ServiceTCS, "Connect")
+	if closed := e.Close(ServiceTCS); closed != 1 {
+		t.Error("Expected the second connection to be accepted")
+	}
+}
+
+func TestControlHandler(t *testing.T) {
+	e := New()
+	server := httptest.NewServer(e.ControlHandler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/acs/messages", "application/json",
+		strings.NewReader(`{"type":"NotAMessage","message":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Error("Expected an unknown message type to be rejected, got", resp.StatusCode)
+	}
+
+	resp, err = http.Post(server.URL+"/failures", "application/json",
+		strings.NewReader(`{"operation":"SubmitTaskStateChange","type":"ServerException","count":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatal("Expected the failure to be queued, got", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		if e.nextFailure("SubmitTaskStateChange") == nil {
+			t.Fatal("Expected a failure for request", i)
+		}
+	}
+	if e.nextFailure("SubmitTaskStateChange") != nil {
+		t.Error("Expected the failure to be used up")
+	}
+
+	resp, err = http.Get(server.URL + "/failures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Error("Expected GET to be rejected, got", resp.StatusCode)
+	}
+}
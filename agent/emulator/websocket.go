@@ -0,0 +1,203 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	acsclient "github.com/aws/amazon-ecs-agent/agent/acs/client"
+	"github.com/aws/amazon-ecs-agent/agent/tcs"
+	wsclient "github.com/aws/amazon-ecs-agent/agent/websocket/client"
+	"github.com/gorilla/websocket"
+)
+
+// closeTimeout bounds how long closing a session waits to send the close
+// frame
+const closeTimeout = time.Second
+
+var upgrader = websocket.Upgrader{
+	// The agent sends no Origin header, but tools poking at the emulator
+	// might
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// session is a websocket connection from the agent
+type session struct {
+	conn *websocket.Conn
+	// writeLock serializes writes as the connection supports one writer
+	writeLock sync.Mutex
+}
+
+func (s *session) write(data []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return s.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (s *session) close() {
+	s.writeLock.Lock()
+	s.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(closeTimeout))
+	s.writeLock.Unlock()
+	s.conn.Close()
+}
+
+// clientServer returns the websocket client of the service, which knows how
+// to encode and decode its messages
+func clientServer(service string) (*wsclient.ClientServerImpl, error) {
+	switch service {
+	case ServiceACS:
+		return &wsclient.ClientServerImpl{
+			TypeDecoder:  acsclient.NewDecoder(),
+			TypeMappings: acsclient.NewTypeMappings(),
+		}, nil
+	case ServiceTCS:
+		return &wsclient.ClientServerImpl{
+			TypeDecoder:  tcs.NewDecoder(),
+			TypeMappings: tcs.NewTypeMappings(),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown websocket service %q", service)
+}
+
+// serveWebsocket accepts a websocket connection from the agent and records
+// the messages it sends until it disconnects. Connecting and disconnecting are
+// recorded as "Connect" and "Disconnect" messages, and rejected handshakes as
+// "ConnectRejected".
+func (e *Emulator) serveWebsocket(service string) http.HandlerFunc {
+	cs, _ := clientServer(service)
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := mustMarshal(r.URL.Query())
+		if failure := e.nextFailure(service); failure != nil {
+			e.record(service, "ConnectRejected", query)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write(mustMarshal(&wsclient.ReceivedMessage{
+				Type:    failure.Type,
+				Message: mustMarshal(map[string]string{"message": failure.Message}),
+			}))
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Warn("Unable to upgrade websocket connection", "service", service, "err", err)
+			return
+		}
+		s := &session{conn: conn}
+		e.lock.Lock()
+		e.sessions[service][s] = struct{}{}
+		e.lock.Unlock()
+		e.record(service, "Connect", query)
+		defer func() {
+			e.lock.Lock()
+			delete(e.sessions[service], s)
+			e.lock.Unlock()
+			conn.Close()
+			e.record(service, "Disconnect", nil)
+		}()
+
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			_, messageType, err := wsclient.DecodeData(data, cs.TypeDecoder)
+			if err != nil {
+				log.Warn("Unrecognized message from agent", "service", service, "err", err)
+			}
+			received := &wsclient.ReceivedMessage{}
+			json.Unmarshal(data, received)
+			e.record(service, messageType, received.Message)
+		}
+	}
+}
+
+// Send sends a message to every agent connected to the service and returns
+// the number of agents it was sent to. The message must be a pointer to one
+// of the ecsacs types for ServiceACS, or the ecstcs types for ServiceTCS.
+func (e *Emulator) Send(service string, message interface{}) (int, error) {
+	cs, err := clientServer(service)
+	if err != nil {
+		return 0, err
+	}
+	data, err := cs.CreateRequestMessage(message)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, s := range e.connected(service) {
+		if err := s.write(data); err != nil {
+			log.Warn("Unable to send message", "service", service, "err", err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// SendJSON sends a message given in its wire format, such as
+// {"type":"HeartbeatMessage","message":{"healthy":true}}, to every agent
+// connected to the service
+func (e *Emulator) SendJSON(service string, data []byte) (int, error) {
+	cs, err := clientServer(service)
+	if err != nil {
+		return 0, err
+	}
+	message, _, err := wsclient.DecodeData(data, cs.TypeDecoder)
+	if err != nil {
+		return 0, err
+	}
+	return e.Send(service, message)
+}
+
+// Close closes the connections of every agent connected to the service and
+// returns the number of connections closed. The agent reconnects after a
+// backoff.
+func (e *Emulator) Close(service string) int {
+	sessions := e.connected(service)
+	for _, s := range sessions {
+		s.close()
+	}
+	return len(sessions)
+}
+
+// Connected returns the number of agents connected to the service
+func (e *Emulator) Connected(service string) int {
+	return len(e.connected(service))
+}
+
+func (e *Emulator) connected(service string) []*session {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	sessions := []*session{}
+	for s := range e.sessions[service] {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
@@ -103,6 +103,49 @@ func DependenciesAreResolved(target *api.Container, by []*api.Container) bool {
 		verifyStatusResolveable(target, nameMap, target.RunDependencies, onRunIsResolved)
 }
 
+// DependentsAreStopped validates that the `target` container can be stopped
+// given the current known state of the containers in `by`. Containers stop in
+// the reverse of the order they start: anything which links to `target`, uses
+// its volumes, or waited for it to run must stop before `target` does.
+// Dependents which never got as far as being created, or which are not
+// themselves stopping, do not hold `target` up.
+func DependentsAreStopped(target *api.Container, by []*api.Container) bool {
+	for _, dependent := range by {
+		if dependent == target || !dependsOn(dependent, target.Name) {
+			continue
+		}
+		if !dependent.DesiredTerminal() || dependent.KnownTerminal() {
+			continue
+		}
+		if dependent.KnownStatus < api.ContainerCreated {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// dependsOn returns true if `container` requires the container named `name`
+// through a link, volumes-from, or run dependency
+func dependsOn(container *api.Container, name string) bool {
+	for _, link := range linksToContainerNames(container.Links) {
+		if link == name {
+			return true
+		}
+	}
+	for _, volume := range container.VolumesFrom {
+		if volume.SourceContainer == name {
+			return true
+		}
+	}
+	for _, dependency := range container.RunDependencies {
+		if dependency == name {
+			return true
+		}
+	}
+	return false
+}
+
 // verifyStatusResolveable validates that `target` can be resolved given that
 // target depends on `dependencies` (which are container names) and there are
 // `existingContainers` (map from name to container). The `resolves` function
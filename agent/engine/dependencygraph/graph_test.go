@@ -201,3 +201,69 @@ func TestRunningependsOnDependencies(t *testing.T) {
 		t.Error("Dependencies should be resolved")
 	}
 }
+
+func stoppingContainer(name string, links, volumes []string, known api.ContainerStatus) *api.Container {
+	return &api.Container{
+		Name:          name,
+		Links:         links,
+		VolumesFrom:   volumeStrToVol(volumes),
+		DesiredStatus: api.ContainerStopped,
+		KnownStatus:   known,
+	}
+}
+
+func TestDependentsAreStopped(t *testing.T) {
+	// app links to db, which uses volumes from dbdata
+	app := stoppingContainer("app", []string{"db:database"}, []string{}, api.ContainerRunning)
+	db := stoppingContainer("db", []string{}, []string{"dbdata"}, api.ContainerRunning)
+	dbdata := stoppingContainer("dbdata", []string{}, []string{}, api.ContainerCreated)
+	containers := []*api.Container{app, db, dbdata}
+
+	if !DependentsAreStopped(app, containers) {
+		t.Error("Nothing depends on app; it should be able to stop")
+	}
+	if DependentsAreStopped(db, containers) {
+		t.Error("db should wait for app, which links to it, to stop")
+	}
+	if DependentsAreStopped(dbdata, containers) {
+		t.Error("dbdata should wait for db, which uses its volumes, to stop")
+	}
+
+	app.KnownStatus = api.ContainerStopped
+	if !DependentsAreStopped(db, containers) {
+		t.Error("db should be able to stop once app has stopped")
+	}
+	if DependentsAreStopped(dbdata, containers) {
+		t.Error("dbdata should still wait for db")
+	}
+
+	db.KnownStatus = api.ContainerStopped
+	if !DependentsAreStopped(dbdata, containers) {
+		t.Error("dbdata should be able to stop once db has stopped")
+	}
+}
+
+func TestDependentsAreStoppedIgnoresIdleDependents(t *testing.T) {
+	// A dependent which was never created is not using anything
+	app := stoppingContainer("app", []string{"db"}, []string{}, api.ContainerPulled)
+	db := stoppingContainer("db", []string{}, []string{}, api.ContainerRunning)
+	if !DependentsAreStopped(db, []*api.Container{app, db}) {
+		t.Error("db should not wait for a dependent that was never created")
+	}
+
+	// A dependent which is not stopping should not hold up a failed dependency
+	app = runningContainer("app", []string{"db"}, []string{})
+	app.KnownStatus = api.ContainerCreated
+	if !DependentsAreStopped(db, []*api.Container{app, db}) {
+		t.Error("db should not wait for a dependent which is not stopping")
+	}
+}
+
+func TestDependentsAreStoppedRunDependencies(t *testing.T) {
+	volume := stoppingContainer("emptyvolume", []string{}, []string{}, api.ContainerRunning)
+	app := stoppingContainer("app", []string{}, []string{}, api.ContainerRunning)
+	app.RunDependencies = []string{"emptyvolume"}
+	if DependentsAreStopped(volume, []*api.Container{app, volume}) {
+		t.Error("A run dependency should wait for its dependents to stop")
+	}
+}
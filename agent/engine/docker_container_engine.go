@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
@@ -48,8 +49,10 @@ type DockerClient interface {
 	InspectContainer(string) (*docker.Container, error)
 	DescribeContainer(string) (api.ContainerStatus, error)
 	ListContainers(bool) ([]string, error)
+	ContainerLogs(string, int, bool, io.Writer) error
 
 	Version() (string, error)
+	Info() (*docker.Env, error)
 }
 
 // Implements DockerClient
@@ -84,7 +87,8 @@ func NewDockerGoClient() (*DockerGoClient, error) {
 	return dg, err
 }
 
-func (dg *DockerGoClient) PullImage(image string) error {
+func (dg *DockerGoClient) PullImage(image string) (err error) {
+	defer countDockerCall("pull_image", &err)
 	log.Info("Pulling image", "image", image)
 	client, err := dg.client()
 	if err != nil {
@@ -177,7 +181,8 @@ func (dg *DockerGoClient) createScratchImageIfNotExists() error {
 	return err
 }
 
-func (dg *DockerGoClient) CreateContainer(config *docker.Config, name string) (string, error) {
+func (dg *DockerGoClient) CreateContainer(config *docker.Config, name string) (_ string, err error) {
+	defer countDockerCall("create_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return "", err
@@ -200,7 +205,8 @@ func (dg *DockerGoClient) CreateContainer(config *docker.Config, name string) (s
 	return dockerContainer.ID, nil
 }
 
-func (dg *DockerGoClient) StartContainer(id string, hostConfig *docker.HostConfig) error {
+func (dg *DockerGoClient) StartContainer(id string, hostConfig *docker.HostConfig) (err error) {
+	defer countDockerCall("start_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return err
@@ -221,7 +227,8 @@ func dockerStateToState(state docker.State) api.ContainerStatus {
 	return api.ContainerStopped
 }
 
-func (dg *DockerGoClient) DescribeContainer(dockerId string) (api.ContainerStatus, error) {
+func (dg *DockerGoClient) DescribeContainer(dockerId string) (_ api.ContainerStatus, err error) {
+	defer countDockerCall("inspect_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return api.ContainerStatusUnknown, err
@@ -238,7 +245,8 @@ func (dg *DockerGoClient) DescribeContainer(dockerId string) (api.ContainerStatu
 	return dockerStateToState(dockerContainer.State), nil
 }
 
-func (dg *DockerGoClient) InspectContainer(dockerId string) (*docker.Container, error) {
+func (dg *DockerGoClient) InspectContainer(dockerId string) (_ *docker.Container, err error) {
+	defer countDockerCall("inspect_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return nil, err
@@ -246,8 +254,39 @@ func (dg *DockerGoClient) InspectContainer(dockerId string) (*docker.Container,
 	return client.InspectContainer(dockerId)
 }
 
+// ContainerLogs writes the last tail lines of a container's stdout and stderr,
+// interleaved, to output. A tail of 0 or less writes all of them. If
+// timestamps is true, each line is prefixed by the time docker received it.
+func (dg *DockerGoClient) ContainerLogs(dockerId string, tail int, timestamps bool, output io.Writer) (err error) {
+	defer countDockerCall("logs", &err)
+	client, err := dg.client()
+	if err != nil {
+		return err
+	}
+	// Containers with a tty have a single raw stream rather than multiplexed
+	// stdout and stderr
+	container, err := client.InspectContainer(dockerId)
+	if err != nil {
+		return err
+	}
+	opts := docker.LogsOptions{
+		Container:    dockerId,
+		OutputStream: output,
+		ErrorStream:  output,
+		Stdout:       true,
+		Stderr:       true,
+		Timestamps:   timestamps,
+		RawTerminal:  container.Config != nil && container.Config.Tty,
+	}
+	if tail > 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+	return client.Logs(opts)
+}
+
 // DescribeDockerImages takes no arguments, and returns a JSON-encoded string of all of the images located on the host
-func (dg *DockerGoClient) DescribeDockerImages() (string, error) {
+func (dg *DockerGoClient) DescribeDockerImages() (_ string, err error) {
+	defer countDockerCall("list_images", &err)
 	client, err := dg.client()
 	if err != nil {
 		return "", err
@@ -266,7 +305,8 @@ func (dg *DockerGoClient) DescribeDockerImages() (string, error) {
 	return string(output), nil
 }
 
-func (dg *DockerGoClient) StopContainer(dockerId string) error {
+func (dg *DockerGoClient) StopContainer(dockerId string) (err error) {
+	defer countDockerCall("stop_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return err
@@ -274,7 +314,8 @@ func (dg *DockerGoClient) StopContainer(dockerId string) error {
 	return client.StopContainer(dockerId, DEFAULT_TIMEOUT_SECONDS)
 }
 
-func (dg *DockerGoClient) RemoveContainer(dockerId string) error {
+func (dg *DockerGoClient) RemoveContainer(dockerId string) (err error) {
+	defer countDockerCall("remove_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return err
@@ -282,7 +323,8 @@ func (dg *DockerGoClient) RemoveContainer(dockerId string) error {
 	return client.RemoveContainer(docker.RemoveContainerOptions{ID: dockerId, RemoveVolumes: true, Force: false})
 }
 
-func (dg *DockerGoClient) StopContainerById(id string) error {
+func (dg *DockerGoClient) StopContainerById(id string) (err error) {
+	defer countDockerCall("stop_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return err
@@ -290,7 +332,8 @@ func (dg *DockerGoClient) StopContainerById(id string) error {
 	return client.StopContainer(id, DEFAULT_TIMEOUT_SECONDS)
 }
 
-func (dg *DockerGoClient) GetContainerName(id string) (string, error) {
+func (dg *DockerGoClient) GetContainerName(id string) (_ string, err error) {
+	defer countDockerCall("inspect_container", &err)
 	client, err := dg.client()
 	if err != nil {
 		return "", err
@@ -387,7 +430,8 @@ func (dg *DockerGoClient) UnsubscribeContainerEvents(eventListener chan *docker.
 }
 
 // ListContainers lists returns a slice of container IDs.
-func (dg *DockerGoClient) ListContainers(all bool) ([]string, error) {
+func (dg *DockerGoClient) ListContainers(all bool) (_ []string, err error) {
+	defer countDockerCall("list_containers", &err)
 	client, err := dg.client()
 	if err != nil {
 		return nil, err
@@ -409,7 +453,8 @@ func (dg *DockerGoClient) ListContainers(all bool) ([]string, error) {
 	return containerIDs, nil
 }
 
-func (dg *DockerGoClient) Version() (string, error) {
+func (dg *DockerGoClient) Version() (_ string, err error) {
+	defer countDockerCall("version", &err)
 	client, err := dg.client()
 	if err != nil {
 		return "", err
@@ -420,3 +465,26 @@ func (dg *DockerGoClient) Version() (string, error) {
 	}
 	return "DockerVersion: " + info.Get("Version"), nil
 }
+
+// Info returns system-wide information about the Docker daemon as reported by
+// its /info endpoint. Older daemons do not include their version there, so
+// "ServerVersion" is filled in from /version when it is missing.
+func (dg *DockerGoClient) Info() (_ *docker.Env, err error) {
+	defer countDockerCall("info", &err)
+	client, err := dg.client()
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.Info()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Exists("ServerVersion") {
+		version, err := client.Version()
+		if err != nil {
+			return nil, err
+		}
+		info.Set("ServerVersion", version.Get("Version"))
+	}
+	return info, nil
+}
@@ -16,16 +16,27 @@
 package engine
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/auth"
 	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/containermetadata"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dependencygraph"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerauth"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/engine/hooks"
+	"github.com/aws/amazon-ecs-agent/agent/engine/tasktransformer"
+	"github.com/aws/amazon-ecs-agent/agent/eventstream"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/aws/amazon-ecs-agent/agent/taskcredentials"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
 	"github.com/fsouza/go-dockerclient"
@@ -61,6 +72,53 @@ type DockerTaskEngine struct {
 	// new tasks will not be processed. Anything transitioning a tasks state
 	// should aquire a read-lock.
 	processTasks sync.RWMutex
+
+	// taskStopTimeout bounds how long a task's containers are stopped in
+	// dependency order. stopDeadlines records, by task arn, when that time
+	// runs out for each stopping task.
+	taskStopTimeout   time.Duration
+	stopDeadlines     map[string]time.Time
+	stopDeadlinesLock sync.Mutex
+
+	// transformers are the enabled task transformer plugins which are applied
+	// to new tasks before they are managed
+	transformers *tasktransformer.Chain
+
+	// taskMetadataEndpoint is the base URL of the task metadata server as seen
+	// from containers, or empty if the server is disabled
+	taskMetadataEndpoint string
+
+	// taskCredentials caches the credentials of tasks' IAM roles, which are
+	// served at credentialsEndpoint. It is nil if task roles are disabled.
+	taskCredentials     *taskcredentials.Manager
+	credentialsEndpoint string
+
+	// containerMetadata writes each container's metadata file, or is nil if
+	// metadata files are disabled
+	containerMetadata *containermetadata.Manager
+
+	// hooks runs the configured lifecycle hook executables as containers are
+	// created, started and stopped. hooksInFlight records the containers
+	// whose transitions are waiting on hooks, which run without the engine's
+	// locks held.
+	hooks         *hooks.Runner
+	hooksInFlight map[*api.Container]bool
+	hooksLock     sync.Mutex
+
+	// stateChanges receives a copy of every state change the engine emits,
+	// for streaming to local consumers
+	stateChanges *eventstream.Feed
+
+	// drain records whether new tasks are being refused so that the instance
+	// can be taken out of service. drainTimer stops the remaining tasks at
+	// the drain deadline, if there is one.
+	drain      DrainStatus
+	drainTimer *time.Timer
+	drainLock  sync.Mutex
+
+	// sweepLock keeps the periodic sweep and requested cleanups from
+	// removing the same task's containers at once
+	sweepLock sync.Mutex
 }
 
 // NewDockerTaskEngine returns a created, but uninitialized, DockerTaskEngine.
@@ -75,6 +133,42 @@ func NewDockerTaskEngine(cfg *config.Config) *DockerTaskEngine {
 		state: dockerstate.NewDockerTaskEngineState(),
 
 		container_events: make(chan api.ContainerStateChange),
+
+		taskStopTimeout: cfg.TaskStopTimeout,
+		stopDeadlines:   make(map[string]time.Time),
+
+		hooksInFlight: make(map[*api.Container]bool),
+
+		stateChanges: eventstream.NewFeed(eventstream.DefaultHistory),
+	}
+	transformers, err := tasktransformer.NewChain(cfg.TaskTransformers)
+	if err != nil {
+		log.Error("Unable to enable all task transformers", "err", err)
+	}
+	dockerTaskEngine.transformers = transformers
+	hookRunner, err := hooks.NewRunner(cfg.LifecycleHooks)
+	if err != nil {
+		log.Error("Unable to enable all lifecycle hooks", "err", err)
+	}
+	dockerTaskEngine.hooks = hookRunner
+	if cfg.ContainerMetadataEnabled {
+		dockerTaskEngine.containerMetadata = containermetadata.NewManager(cfg)
+	}
+	agentEndpoint := fmt.Sprintf("http://%s:%d", cfg.TaskMetadataAddress, config.TASK_METADATA_PORT)
+	if cfg.TaskMetadataEnabled {
+		dockerTaskEngine.taskMetadataEndpoint = agentEndpoint
+	}
+	if cfg.TaskIAMRoleEnabled {
+		instanceCredentials := auth.NewBasicAWSCredentialProvider()
+		if cfg.ExternalInstance {
+			instanceCredentials = auth.NewExternalAWSCredentialProvider()
+		}
+		source := taskcredentials.NewDefaultSTSSource(instanceCredentials)
+		dockerTaskEngine.taskCredentials = taskcredentials.NewManager(source)
+		dockerTaskEngine.credentialsEndpoint = agentEndpoint
+	}
+	if dockerTaskEngine.taskStopTimeout <= 0 {
+		dockerTaskEngine.taskStopTimeout = config.DEFAULT_TASK_STOP_TIMEOUT
 	}
 	dockerauth.SetConfig(cfg)
 
@@ -91,6 +185,17 @@ func (engine *DockerTaskEngine) MarshalJSON() ([]byte, error) {
 	return engine.state.MarshalJSON()
 }
 
+// SaveRecords marshals the state's tasks and containers separately, so that
+// a state manager which supports it can save only those that change
+func (engine *DockerTaskEngine) SaveRecords() (map[string]json.RawMessage, error) {
+	return engine.state.SaveRecords()
+}
+
+// LoadRecords restores a task-engine state saved by SaveRecords
+func (engine *DockerTaskEngine) LoadRecords(records map[string]json.RawMessage) error {
+	return engine.state.LoadRecords(records)
+}
+
 // Init initializes a DockerTaskEngine such that it may communicate with docker
 // and operate normally.
 // This function must be called before any other function, except serializing and deserializing, can succeed without error.
@@ -113,9 +218,27 @@ func (engine *DockerTaskEngine) Init() error {
 
 	go engine.sweepTasks()
 
+	engine.registerStatusGauges()
+
+	if engine.taskCredentials != nil {
+		go engine.restoreTaskCredentials()
+		go engine.taskCredentials.Run()
+	}
+
 	return nil
 }
 
+// restoreTaskCredentials fetches credentials for the roles of tasks which were
+// running before the agent restarted. Credentials are never saved to disk.
+func (engine *DockerTaskEngine) restoreTaskCredentials() {
+	for _, task := range engine.state.AllTasks() {
+		if task.CredentialsId == "" || task.KnownStatus.Terminal() {
+			continue
+		}
+		engine.taskCredentials.Ensure(task.CredentialsId, task.Arn, task.RoleArn)
+	}
+}
+
 func (engine *DockerTaskEngine) initDockerClient() error {
 	if engine.client == nil {
 		client, err := NewDockerGoClient()
@@ -150,6 +273,12 @@ func (engine *DockerTaskEngine) SetSaver(saver statemanager.Saver) {
 	engine.saver = saver
 }
 
+func (engine *DockerTaskEngine) SetContainerInstanceArn(arn string) {
+	if engine.containerMetadata != nil {
+		engine.containerMetadata.SetContainerInstanceArn(arn)
+	}
+}
+
 func (engine *DockerTaskEngine) Disable() {
 	engine.processTasks.Lock()
 }
@@ -207,19 +336,38 @@ func (engine *DockerTaskEngine) synchronizeState() {
 // deletes them and removes them from its "state".
 func (engine *DockerTaskEngine) sweepTasks() {
 	for {
-		tasks := engine.state.AllTasks()
+		engine.sweepStoppedTasks(taskStoppedDuration)
+		ttime.Sleep(sweepInterval)
+	}
+}
 
-		for _, task := range tasks {
-			if task.KnownStatus.Terminal() {
-				if ttime.Since(task.KnownTime) > taskStoppedDuration {
-					engine.sweepTask(task)
-					engine.state.RemoveTask(task)
-				}
-			}
-		}
+// CleanupStoppedTasks removes every stopped task and its containers now,
+// rather than once it has been stopped for a few hours, and returns the arns
+// of the tasks removed
+func (engine *DockerTaskEngine) CleanupStoppedTasks() []string {
+	removed := engine.sweepStoppedTasks(0)
+	if len(removed) > 0 {
+		engine.saver.Save()
+	}
+	return removed
+}
 
-		ttime.Sleep(sweepInterval)
+// sweepStoppedTasks removes the tasks which have been stopped for longer than
+// stoppedFor, returning their arns
+func (engine *DockerTaskEngine) sweepStoppedTasks(stoppedFor time.Duration) []string {
+	engine.sweepLock.Lock()
+	defer engine.sweepLock.Unlock()
+
+	removed := []string{}
+	for _, task := range engine.state.AllTasks() {
+		if task.KnownStatus.Terminal() && ttime.Since(task.KnownTime) >= stoppedFor {
+			engine.sweepTask(task)
+			engine.state.RemoveTask(task)
+			engine.clearTaskStopDeadline(task)
+			removed = append(removed, task.Arn)
+		}
 	}
+	return removed
 }
 
 // sweepTask deletes all the containers associated with a task
@@ -230,6 +378,11 @@ func (engine *DockerTaskEngine) sweepTask(task *api.Task) {
 			log.Debug("Unable to remove old container", "err", err, "task", task, "cont", cont)
 		}
 	}
+	if engine.containerMetadata != nil {
+		if err := engine.containerMetadata.Clean(task); err != nil {
+			log.Warn("Unable to remove container metadata", "err", err, "task", task)
+		}
+	}
 }
 
 // emitEvent passes a given event up through the container_event channel.
@@ -264,14 +417,91 @@ func (engine *DockerTaskEngine) emitEvent(task *api.Task, container *api.DockerC
 	if task_change := task.UpdateTaskStatus(); task_change != api.TaskStatusNone {
 		log.Info("Task change event", "state", task_change)
 		event.TaskStatus = task_change
+		// Only time the first transition to running, which sets StartedAt
+		if task_change == api.TaskRunning && !task.ReceivedAt.IsZero() && task.StartedAt.Equal(task.KnownTime) {
+			taskStartSeconds.Observe(task.StartedAt.Sub(task.ReceivedAt).Seconds())
+		}
+		if task_change.Terminal() && engine.taskCredentials != nil && task.CredentialsId != "" {
+			engine.taskCredentials.Remove(task.CredentialsId)
+		}
 	}
 	log.Info("Container change event", "event", event)
 	if cont.IsInternal {
 		return
 	}
+	engine.stateChanges.Publish(event)
 	engine.container_events <- event
 }
 
+// emitStoppedEvent runs the post-stop hooks for a container which has just
+// stopped and then emits its event. A failing hook is reported as the reason
+// the container stopped unless there is already a more useful one.
+func (engine *DockerTaskEngine) emitStoppedEvent(task *api.Task, container *api.DockerContainer) {
+	// Resolve the exit code first so that hooks are told about it
+	engine.updateContainerMetadata(task, container)
+	if err := engine.runHooks(hooks.PostStop, task, container.Container, container); err != nil {
+		container.Container.StatusLock.Lock()
+		if container.Container.ApplyingError == nil {
+			container.Container.ApplyingError = api.NewApplyingError(err)
+		}
+		container.Container.StatusLock.Unlock()
+	}
+	engine.emitEvent(task, container, "")
+}
+
+// runHooks runs the hooks for the given event. The failure of a hook whose
+// failure policy is "ignore" is recorded as the container's reason, unless it
+// already has one, and is not returned. The container's StatusLock must not
+// be held.
+func (engine *DockerTaskEngine) runHooks(event hooks.Event, task *api.Task, container *api.Container, dockerContainer *api.DockerContainer) error {
+	err := engine.hooks.Run(event, task, container, dockerContainer)
+	if hookErr, ok := err.(*hooks.HookError); ok && hookErr.Ignored {
+		container.StatusLock.Lock()
+		if container.ApplyingError == nil {
+			container.ApplyingError = api.NewApplyingError(hookErr)
+		}
+		container.StatusLock.Unlock()
+		return nil
+	}
+	return err
+}
+
+// runHooksUnlocked runs the hooks for the given event from
+// applyContainerState, releasing the engine and container locks which it holds
+// so that a slow hook cannot hold up the rest of the engine. Until the hooks
+// finish, other attempts to transition the container leave it alone.
+func (engine *DockerTaskEngine) runHooksUnlocked(event hooks.Event, task *api.Task, container *api.Container, dockerContainer *api.DockerContainer) error {
+	if !engine.hooks.Has(event) {
+		return nil
+	}
+	engine.setHooksInFlight(container, true)
+	container.StatusLock.Unlock()
+	engine.processTasks.RUnlock()
+
+	err := engine.runHooks(event, task, container, dockerContainer)
+
+	engine.processTasks.RLock()
+	container.StatusLock.Lock()
+	engine.setHooksInFlight(container, false)
+	return err
+}
+
+func (engine *DockerTaskEngine) setHooksInFlight(container *api.Container, inFlight bool) {
+	engine.hooksLock.Lock()
+	defer engine.hooksLock.Unlock()
+	if inFlight {
+		engine.hooksInFlight[container] = true
+	} else {
+		delete(engine.hooksInFlight, container)
+	}
+}
+
+func (engine *DockerTaskEngine) hooksRunning(container *api.Container) bool {
+	engine.hooksLock.Lock()
+	defer engine.hooksLock.Unlock()
+	return engine.hooksInFlight[container]
+}
+
 // openEventstream opens, but does not consume, the docker event stream
 func (engine *DockerTaskEngine) openEventstream() error {
 	events, _, err := engine.client.ContainerEvents()
@@ -297,6 +527,12 @@ func (engine *DockerTaskEngine) handleDockerEvents() {
 		// Update the status to what we now know to be the true status
 		if cont.Container.KnownStatus < event.Status {
 			cont.Container.KnownStatus = event.Status
+			if event.Status.Terminal() && engine.hooks.Has(hooks.PostStop) {
+				// Hooks may take a while; don't hold up other containers'
+				// events waiting on them
+				go engine.emitStoppedEvent(task, cont)
+				continue
+			}
 			engine.emitEvent(task, cont, "")
 		} else if cont.Container.KnownStatus == event.Status {
 			log.Warn("Redundant docker event; unusual but not critical", "event", event, "cont", cont)
@@ -337,6 +573,18 @@ func (engine *DockerTaskEngine) updateContainerMetadata(task *api.Task, containe
 		}
 
 		task.UpdateMountPoints(container.Container, containerInfo.Volumes)
+
+		container.Container.KnownImageId = containerInfo.Image
+		container.Container.CreatedAt = containerInfo.Created
+		if !containerInfo.State.StartedAt.IsZero() {
+			container.Container.StartedAt = containerInfo.State.StartedAt
+		}
+
+		if engine.containerMetadata != nil {
+			if err := engine.containerMetadata.Update(task, container); err != nil {
+				llog.Warn("Unable to update container metadata file", "err", err)
+			}
+		}
 	case api.ContainerStopped:
 		fallthrough
 	case api.ContainerDead:
@@ -349,6 +597,9 @@ func (engine *DockerTaskEngine) updateContainerMetadata(task *api.Task, containe
 		// Exit code
 		log.Debug("Updating exit code", "exit code", containerInfo.State.ExitCode)
 		container.Container.KnownExitCode = &containerInfo.State.ExitCode
+		if !containerInfo.State.FinishedAt.IsZero() {
+			container.Container.FinishedAt = containerInfo.State.FinishedAt
+		}
 	}
 
 	return nil
@@ -378,17 +629,88 @@ func TaskCompleted(task *api.Task) bool {
 func (engine *DockerTaskEngine) AddTask(task *api.Task) error {
 	task.PostUnmarshalTask()
 
+	var rejection error
+	if _, known := engine.state.TaskByArn(task.Arn); !known {
+		rejection = engine.rejectIfDraining()
+		if rejection == nil {
+			rejection = engine.transformers.Apply(task)
+		}
+		if engine.taskMetadataEndpoint != "" {
+			task.MetadataToken = newSecretToken()
+		}
+		if engine.taskCredentials != nil && task.RoleArn != "" {
+			task.CredentialsId = newSecretToken()
+		}
+	}
+
 	engine.processTasks.RLock()
 	task = engine.state.AddOrUpdateTask(task)
 	engine.processTasks.RUnlock()
 
+	if rejection != nil {
+		engine.rejectTask(task, rejection)
+		return nil
+	}
 	engine.applyTaskState(task)
 	return nil
 }
 
+// rejectTask stops a task which was never started, recording the reason on
+// each of its containers so that it is reported upstream
+func (engine *DockerTaskEngine) rejectTask(task *api.Task, reason error) {
+	task.SetDesiredStatus(api.TaskStopped)
+	for _, container := range task.Containers {
+		container.StatusLock.Lock()
+		container.ApplyingError = api.NewApplyingError(reason)
+		container.DesiredStatus = api.ContainerStopped
+		container.KnownStatus = api.ContainerStopped
+		container.StatusLock.Unlock()
+	}
+	for _, container := range task.Containers {
+		go engine.emitEvent(task, &api.DockerContainer{Container: container}, "")
+	}
+	engine.saver.Save()
+}
+
+// TaskMetadataEnvVar is the environment variable through which containers are
+// given the URL of their task metadata
+const TaskMetadataEnvVar = "ECS_CONTAINER_METADATA_URI"
+
+// TaskMetadataPath returns the path, on the task metadata server, of the
+// metadata for the named container in the task with the given token. Container
+// names are limited to letters, numbers, hyphens and underscores, so need no
+// escaping.
+func TaskMetadataPath(token, containerName string) string {
+	return "/v1/metadata/" + token + "/" + containerName
+}
+
+// CredentialsEnvVar is the environment variable through which containers are
+// given the URL of their task role's credentials
+const CredentialsEnvVar = "ECS_CONTAINER_CREDENTIALS_URI"
+
+// CredentialsPath returns the path, on the task metadata server, of the
+// credentials with the given id
+func CredentialsPath(credentialsId string) string {
+	return "/v1/credentials/" + credentialsId
+}
+
+// newSecretToken returns a random, unguessable token for a task to identify
+// itself to the agent with
+func newSecretToken() string {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		// Without a token the task's containers are simply not told about
+		// the endpoint it is for
+		log.Error("Unable to generate task token", "err", err)
+		return ""
+	}
+	return hex.EncodeToString(token)
+}
+
 type transitionApplyFunc (func(*api.Task, *api.Container) error)
 
 func tryApplyTransition(task *api.Task, container *api.Container, to api.ContainerStatus, f transitionApplyFunc) error {
+	defer transitionSeconds.Since(time.Now(), transitionNames[to])
 	err := utils.RetryNWithBackoff(utils.NewSimpleBackoff(5*time.Second, 30*time.Second, 0.25, 2), 3, func() error {
 		return f(task, container)
 	})
@@ -407,6 +729,10 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 	defer container.StatusLock.Unlock()
 
 	clog := log.New("task", task, "container", container)
+	if engine.hooksRunning(container) {
+		clog.Debug("Container waiting on hooks; they will carry on its transition")
+		return
+	}
 	if container.KnownStatus == container.DesiredStatus {
 		clog.Debug("Container at desired status", "desired", container.DesiredStatus)
 		return
@@ -423,6 +749,13 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 		clog.Info("Can't apply state to container yet; dependencies unresolved", "state", container.DesiredStatus)
 		return
 	}
+	if container.DesiredTerminal() && container.AppliedStatus < api.ContainerStopped && !dependencygraph.DependentsAreStopped(container, task.Containers) {
+		if !engine.taskStopDeadlineExceeded(task) {
+			clog.Info("Can't stop container yet; waiting for dependent containers to stop")
+			return
+		}
+		clog.Warn("Task stop timeout exceeded; stopping container before its dependents")
+	}
 	// If we got here, the KnownStatus < DesiredStatus and we haven't applied
 	// DesiredStatus yet; appliy a step towards it now
 
@@ -436,13 +769,22 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 		// show. This is also the only state where an error results in a
 		// state-change submission anyways.
 		if container.AppliedStatus < api.ContainerStopped {
+			dockerContainer := engine.dockerContainer(task, container)
+			if hookErr := engine.runHooksUnlocked(hooks.PreStop, task, container, dockerContainer); hookErr != nil && container.ApplyingError == nil {
+				container.ApplyingError = api.NewApplyingError(hookErr)
+			}
 			err = tryApplyTransition(task, container, api.ContainerStopped, engine.stopContainer)
 			if err != nil {
 				clog.Info("Unable to stop container", "err", err)
 				// If there was an error, assume we won't get an event in the
 				// eventstream and emit it ourselves.
 				container.KnownStatus = api.ContainerStopped
-				engine.emitEvent(task, &api.DockerContainer{Container: container}, "")
+				if hookErr := engine.runHooksUnlocked(hooks.PostStop, task, container, dockerContainer); hookErr != nil && container.ApplyingError == nil {
+					container.ApplyingError = api.NewApplyingError(hookErr)
+				}
+				// Emitting the event applies the task's state again, which
+				// needs this container's lock
+				go engine.emitEvent(task, &api.DockerContainer{Container: container}, "")
 				if _, ok := err.(*docker.NoSuchContainer); ok {
 					engine.state.RemoveTask(task)
 				}
@@ -458,17 +800,29 @@ func (engine *DockerTaskEngine) applyContainerState(task *api.Task, container *a
 			// no corresponding event from the docker eventstream to update
 			// this with.
 			container.KnownStatus = api.ContainerPulled
+			container.PulledAt = ttime.Now()
 		}
 	}
 
 	if !container.DesiredTerminal() {
 		if container.AppliedStatus < api.ContainerCreated {
-			err = tryApplyTransition(task, container, api.ContainerCreated, engine.createContainer)
+			err = engine.runHooksUnlocked(hooks.PreCreate, task, container, nil)
+			if err == nil && container.DesiredTerminal() {
+				// The container was stopped while its hooks ran
+				go engine.applyContainerState(task, container)
+				return
+			}
+			if err == nil {
+				err = tryApplyTransition(task, container, api.ContainerCreated, engine.createContainer)
+			}
 			if err != nil {
 				clog.Warn("Unable to create container", "err", err)
 			}
 		} else if container.AppliedStatus < api.ContainerRunning {
 			err = tryApplyTransition(task, container, api.ContainerRunning, engine.startContainer)
+			if err == nil {
+				err = engine.runHooksUnlocked(hooks.PostStart, task, container, engine.dockerContainer(task, container))
+			}
 			if err != nil {
 				clog.Warn("Unable to start container", "err", err)
 			}
@@ -515,6 +869,32 @@ func (engine *DockerTaskEngine) applyTaskState(task *api.Task) {
 	}
 }
 
+// taskStopDeadlineExceeded returns true once the given task has spent longer
+// than the task stop timeout stopping its containers in order. The first call
+// for a task starts the clock, and ensures the task will be looked at again
+// when it runs out even if no container events arrive in the meantime.
+func (engine *DockerTaskEngine) taskStopDeadlineExceeded(task *api.Task) bool {
+	engine.stopDeadlinesLock.Lock()
+	defer engine.stopDeadlinesLock.Unlock()
+
+	deadline, ok := engine.stopDeadlines[task.Arn]
+	if !ok {
+		deadline = ttime.Now().Add(engine.taskStopTimeout)
+		engine.stopDeadlines[task.Arn] = deadline
+		go func(timeout time.Duration) {
+			ttime.Sleep(timeout)
+			engine.applyTaskState(task)
+		}(engine.taskStopTimeout)
+	}
+	return !ttime.Now().Before(deadline)
+}
+
+func (engine *DockerTaskEngine) clearTaskStopDeadline(task *api.Task) {
+	engine.stopDeadlinesLock.Lock()
+	defer engine.stopDeadlinesLock.Unlock()
+	delete(engine.stopDeadlines, task.Arn)
+}
+
 func (engine *DockerTaskEngine) ListTasks() ([]*api.Task, error) {
 	return engine.state.AllTasks(), nil
 }
@@ -535,6 +915,22 @@ func (engine *DockerTaskEngine) createContainer(task *api.Task, container *api.C
 	if err != nil {
 		return err
 	}
+	if engine.containerMetadata != nil {
+		if err := engine.containerMetadata.Create(task, container); err != nil {
+			return err
+		}
+		config.Env = append(config.Env, containermetadata.EnvVar+"="+containermetadata.FilePath())
+	}
+	if engine.taskCredentials != nil && task.CredentialsId != "" {
+		// Fetch credentials before the container can start asking for them
+		if err := engine.taskCredentials.Ensure(task.CredentialsId, task.Arn, task.RoleArn); err != nil {
+			return err
+		}
+		config.Env = append(config.Env, CredentialsEnvVar+"="+engine.credentialsEndpoint+CredentialsPath(task.CredentialsId))
+	}
+	if engine.taskMetadataEndpoint != "" && task.MetadataToken != "" {
+		config.Env = append(config.Env, TaskMetadataEnvVar+"="+engine.taskMetadataEndpoint+TaskMetadataPath(task.MetadataToken, container.Name))
+	}
 
 	err = func() error {
 		name := ""
@@ -587,6 +983,9 @@ func (engine *DockerTaskEngine) startContainer(task *api.Task, container *api.Co
 	if err != nil {
 		return err
 	}
+	if engine.containerMetadata != nil {
+		hostConfig.Binds = append(hostConfig.Binds, engine.containerMetadata.Bind(task, container))
+	}
 
 	return engine.client.StartContainer(dockerContainer.DockerId, hostConfig)
 }
@@ -606,6 +1005,16 @@ func (engine *DockerTaskEngine) stopContainer(task *api.Task, container *api.Con
 	return engine.client.StopContainer(dockerContainer.DockerId)
 }
 
+// dockerContainer returns the docker container backing the given container,
+// or nil if it has not been created
+func (engine *DockerTaskEngine) dockerContainer(task *api.Task, container *api.Container) *api.DockerContainer {
+	containerMap, ok := engine.state.ContainerMapByArn(task.Arn)
+	if !ok {
+		return nil
+	}
+	return containerMap[container.Name]
+}
+
 func (engine *DockerTaskEngine) removeContainer(task *api.Task, container *api.Container) error {
 	log.Info("Removing container", "task", task, "container", container)
 	containerMap, ok := engine.state.ContainerMapByArn(task.Arn)
@@ -629,6 +1038,30 @@ func (engine *DockerTaskEngine) State() *dockerstate.DockerTaskEngineState {
 	return engine.state
 }
 
+func (engine *DockerTaskEngine) TaskState() dockerstate.TaskEngineState {
+	return engine.state
+}
+
+// StateChanges returns the feed of every state change the engine emits
+func (engine *DockerTaskEngine) StateChanges() *eventstream.Feed {
+	return engine.stateChanges
+}
+
+// TaskCredentials returns the credentials of the task role with the given
+// credentials id, if task roles are enabled and the task is running
+func (engine *DockerTaskEngine) TaskCredentials(credentialsId string) (*taskcredentials.TaskCredentials, bool) {
+	if engine.taskCredentials == nil {
+		return nil, false
+	}
+	return engine.taskCredentials.Get(credentialsId)
+}
+
+// ContainerLogs writes the recent output of the docker container with the
+// given id to w; see DockerClient.ContainerLogs
+func (engine *DockerTaskEngine) ContainerLogs(dockerId string, tail int, timestamps bool, w io.Writer) error {
+	return engine.client.ContainerLogs(dockerId, tail, timestamps, w)
+}
+
 // Version returns the underlying docker version.
 func (engine *DockerTaskEngine) Version() (string, error) {
 	// Must be able to be called before Init()
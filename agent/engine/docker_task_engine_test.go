@@ -0,0 +1,197 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/tasktransformer"
+	"github.com/fsouza/go-dockerclient"
+)
+
+type rejectingTransformer struct{}
+
+func (rejectingTransformer) Name() string {
+	return "engine-test-reject"
+}
+
+func (rejectingTransformer) Transform(task *api.Task) error {
+	return errors.New("forbidden image")
+}
+
+func init() {
+	tasktransformer.Register(rejectingTransformer{})
+}
+
+func TestAddTaskRejectedByTransformer(t *testing.T) {
+	taskEngine := NewDockerTaskEngine(&config.Config{TaskTransformers: []string{"engine-test-reject"}})
+	task := createTestTask("rejected")
+	taskEngine.AddTask(task)
+
+	select {
+	case event := <-taskEngine.TaskEvents():
+		if event.Status != api.ContainerStopped {
+			t.Error("Expected the container to be stopped", event.Status)
+		}
+		if event.TaskStatus != api.TaskStopped {
+			t.Error("Expected the task to be stopped", event.TaskStatus)
+		}
+		if !strings.Contains(event.Reason, "forbidden image") {
+			t.Error("Expected the rejection to be the reason", event.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the rejected task to stop")
+	}
+
+	stored, ok := taskEngine.State().TaskByArn("rejected")
+	if !ok || stored.DesiredStatus != api.TaskStopped {
+		t.Error("Expected the rejected task to be tracked as stopped")
+	}
+}
+
+func TestAddTaskRejectedWhileDraining(t *testing.T) {
+	taskEngine := NewDockerTaskEngine(&config.Config{})
+	taskEngine.Drain(0)
+	taskEngine.AddTask(createTestTask("drained"))
+
+	select {
+	case event := <-taskEngine.TaskEvents():
+		if event.TaskStatus != api.TaskStopped {
+			t.Error("Expected the task to be stopped", event.TaskStatus)
+		}
+		if event.Reason != "draining" {
+			t.Error("Expected draining to be the reason", event.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the rejected task to stop")
+	}
+
+	status := taskEngine.DrainStatus()
+	if !status.Draining || status.RejectedTasks != 1 || !status.Deadline.IsZero() {
+		t.Error("Unexpected drain status", status)
+	}
+
+	taskEngine.Undrain()
+	if taskEngine.DrainStatus().Draining {
+		t.Error("Expected draining to stop")
+	}
+}
+
+func TestIgnoredHookFailureIsStopReason(t *testing.T) {
+	dir, err := ioutil.TempDir("", "engine_hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	hook := filepath.Join(dir, "fail")
+	ioutil.WriteFile(hook, []byte("#!/bin/sh\necho cleanup failed; exit 1\n"), 0755)
+
+	taskEngine := NewDockerTaskEngine(&config.Config{LifecycleHooks: map[string][]config.HookConfig{
+		"post-stop": {{Path: hook}},
+	}})
+	task := createTestTask("stopped")
+	task.Containers[0].KnownStatus = api.ContainerStopped
+	task.Containers[0].DesiredStatus = api.ContainerStopped
+	taskEngine.State().AddOrUpdateTask(task)
+	go taskEngine.emitStoppedEvent(task, &api.DockerContainer{Container: task.Containers[0]})
+
+	select {
+	case event := <-taskEngine.TaskEvents():
+		if !strings.Contains(event.Reason, "post-stop hook "+hook+" failed") {
+			t.Error("Expected the ignored hook failure to be the reason", event.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the stopped event")
+	}
+}
+
+// stoppingClient is a DockerClient which stops containers as soon as asked to,
+// reporting it on events as docker would
+type stoppingClient struct {
+	DockerClient
+	events chan DockerContainerChangeEvent
+}
+
+func (client *stoppingClient) StopContainer(dockerId string) error {
+	go func() {
+		client.events <- DockerContainerChangeEvent{DockerId: dockerId, Status: api.ContainerStopped}
+	}()
+	return nil
+}
+
+func (client *stoppingClient) InspectContainer(dockerId string) (*docker.Container, error) {
+	return &docker.Container{ID: dockerId}, nil
+}
+
+func TestDrainDeadlineStopsRunningTasks(t *testing.T) {
+	taskEngine := NewDockerTaskEngine(&config.Config{})
+	client := &stoppingClient{events: make(chan DockerContainerChangeEvent)}
+	taskEngine.client = client
+	taskEngine.events = client.events
+	go taskEngine.handleDockerEvents()
+
+	task := createTestTask("running")
+	task.KnownStatus = api.TaskRunning
+	task.Containers[0].KnownStatus = api.ContainerRunning
+	task.Containers[0].AppliedStatus = api.ContainerRunning
+	taskEngine.State().AddOrUpdateTask(task)
+	taskEngine.State().AddContainer(&api.DockerContainer{DockerId: "docker1", DockerName: "running", Container: task.Containers[0]}, task)
+
+	taskEngine.Drain(10 * time.Millisecond)
+	if status := taskEngine.DrainStatus(); status.RunningTasks != 1 || status.Deadline.IsZero() {
+		t.Error("Unexpected drain status", status)
+	}
+
+	select {
+	case event := <-taskEngine.TaskEvents():
+		if event.Status != api.ContainerStopped || event.TaskStatus != api.TaskStopped {
+			t.Error("Expected the task to be stopped at the drain deadline", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the task to be stopped at the drain deadline")
+	}
+	if status := taskEngine.DrainStatus(); status.RunningTasks != 0 {
+		t.Error("Expected no running tasks", status)
+	}
+}
+
+func TestCleanupStoppedTasks(t *testing.T) {
+	taskEngine := NewDockerTaskEngine(&config.Config{})
+	stopped := createTestTask("stopped")
+	stopped.KnownStatus = api.TaskStopped
+	stopped.KnownTime = time.Now()
+	running := createTestTask("running")
+	running.KnownStatus = api.TaskRunning
+	taskEngine.State().AddOrUpdateTask(stopped)
+	taskEngine.State().AddOrUpdateTask(running)
+
+	removed := taskEngine.CleanupStoppedTasks()
+	if len(removed) != 1 || removed[0] != "stopped" {
+		t.Error("Expected only the stopped task to be removed", removed)
+	}
+	if _, ok := taskEngine.State().TaskByArn("stopped"); ok {
+		t.Error("Expected the stopped task to be forgotten")
+	}
+	if _, ok := taskEngine.State().TaskByArn("running"); !ok {
+		t.Error("Expected the running task to be kept")
+	}
+}
@@ -22,6 +22,15 @@ import (
 
 var log = logger.ForModule("dockerstate")
 
+// TaskEngineState is the read-only view of a DockerTaskEngineState which is
+// offered to consumers outside the engine, such as the introspection api
+type TaskEngineState interface {
+	AllTasks() []*api.Task
+	TaskByArn(arn string) (*api.Task, bool)
+	TaskById(dockerId string) (*api.Task, bool)
+	ContainerMapByArn(arn string) (map[string]*api.DockerContainer, bool)
+}
+
 // dockerTaskEngineState keeps track of all mappings between tasks we know about
 // and containers docker runs
 // It contains a mutex that can be used to ensure out-of-date state cannot be
@@ -42,6 +51,9 @@ type DockerTaskEngineState struct {
 	idToTask      map[string]string                          // DockerId -> taskarn
 	taskToId      map[string]map[string]*api.DockerContainer // taskarn -> (containername -> api.DockerContainer)
 	idToContainer map[string]*api.DockerContainer            // DockerId -> api.DockerContainer
+
+	savedLock sync.Mutex             // serializes SaveRecords
+	saved     map[string]savedRecord // record key -> the record SaveRecords last returned
 }
 
 func NewDockerTaskEngineState() *DockerTaskEngineState {
@@ -95,8 +107,8 @@ func (state *DockerTaskEngineState) AddOrUpdateTask(task *api.Task) *api.Task {
 	}
 
 	// Update
-	if task.DesiredStatus > current.DesiredStatus {
-		current.DesiredStatus = task.DesiredStatus
+	if desiredStatus := task.GetDesiredStatus(); desiredStatus > current.GetDesiredStatus() {
+		current.SetDesiredStatus(desiredStatus)
 	}
 
 	return current
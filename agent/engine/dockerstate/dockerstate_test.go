@@ -14,7 +14,12 @@
 package dockerstate
 
 import (
+	"bytes"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 )
@@ -121,3 +126,113 @@ func TestTwophaseAddContainer(t *testing.T) {
 		t.Fatal("Incorrect container fetched")
 	}
 }
+
+func TestSaveRecordsMarshalsOnlyChangedRecords(t *testing.T) {
+	state := NewDockerTaskEngineState()
+	var tasks []*api.Task
+	for _, arn := range []string{"changed", "unchanged"} {
+		task := &api.Task{Arn: arn, Containers: []*api.Container{{Name: "c"}}}
+		state.AddOrUpdateTask(task)
+		state.AddContainer(&api.DockerContainer{DockerId: arn + "-id", DockerName: arn, Container: task.Containers[0]}, task)
+		tasks = append(tasks, task)
+	}
+
+	first, err := state.SaveRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks[0].KnownStatus = api.TaskRunning
+	second, err := state.SaveRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(second) != 4 {
+		t.Fatalf("Expected 4 records, got %d", len(second))
+	}
+	if !strings.Contains(string(second["task/changed"]), `"KnownStatus":"RUNNING"`) {
+		t.Error("Expected the changed task to be marshalled again, got ", string(second["task/changed"]))
+	}
+	for _, key := range []string{"task/unchanged", "container/changed-id", "container/unchanged-id"} {
+		if &first[key][0] != &second[key][0] {
+			t.Error("Expected unchanged record to be reused: ", key)
+		}
+	}
+
+	state.RemoveTask(tasks[1])
+	third, err := state.SaveRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(third) != 2 || len(state.saved) != 2 {
+		t.Errorf("Expected the removed task's records to be dropped, got %d records and %d cached", len(third), len(state.saved))
+	}
+}
+
+// Fields which are fixed by the time a task is added to the state, and so are
+// left out of a record's version
+var unversionedFields = map[string]bool{
+	"Task.Arn": true, "Task.Family": true, "Task.Version": true, "Task.Volumes": true,
+	"Task.ReceivedAt": true, "Task.RoleArn": true,
+	"Task.Containers": true, // versioned field by field below
+
+	"Container.Name": true, "Container.Image": true, "Container.Command": true,
+	"Container.Cpu": true, "Container.Memory": true, "Container.Links": true,
+	"Container.VolumesFrom": true, "Container.MountPoints": true, "Container.Ports": true,
+	"Container.Essential": true, "Container.EntryPoint": true, "Container.Environment": true,
+	"Container.Overrides": true, "Container.RunDependencies": true, "Container.IsInternal": true,
+}
+
+// TestRecordVersionCoversSavedFields makes sure that a field added to tasks
+// or containers is either included in records' versions or explicitly left
+// out of them
+func TestRecordVersionCoversSavedFields(t *testing.T) {
+	for _, object := range []interface{}{&api.Task{}, &api.Container{}} {
+		typ := reflect.TypeOf(object).Elem()
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := typ.Name() + "." + field.Name
+			if field.PkgPath != "" || field.Tag.Get("json") == "-" || field.Type == reflect.TypeOf(sync.Mutex{}) || unversionedFields[name] {
+				continue
+			}
+			task := &api.Task{Containers: []*api.Container{{}}}
+			before := taskVersion(task)
+			target := reflect.ValueOf(task).Elem()
+			if typ.Name() == "Container" {
+				target = reflect.ValueOf(task.Containers[0]).Elem()
+			}
+			if !setNonZero(target.Field(i)) {
+				t.Errorf("Don't know how to change %s", name)
+				continue
+			}
+			if bytes.Equal(before, taskVersion(task)) {
+				t.Errorf("Changing %s doesn't change the task's version; version it or list it in unversionedFields", name)
+			}
+		}
+	}
+}
+
+func setNonZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.String:
+		value.SetString("x")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Ptr:
+		value.Set(reflect.New(value.Type().Elem()))
+	case reflect.Slice:
+		value.Set(reflect.MakeSlice(value.Type(), 1, 1))
+	case reflect.Struct:
+		if value.Type() != reflect.TypeOf(time.Time{}) {
+			return false
+		}
+		value.Set(reflect.ValueOf(time.Unix(1, 0)))
+	default:
+		return false
+	}
+	return true
+}
@@ -14,8 +14,10 @@
 package dockerstate
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
 )
@@ -47,6 +49,106 @@ func (state *DockerTaskEngineState) UnmarshalJSON(data []byte) error {
 	if err != nil {
 		return err
 	}
+	return state.load(saved)
+}
+
+// Record keys are a task's arn or a container's docker id under these
+// prefixes
+const (
+	taskRecordPrefix      = "task/"
+	containerRecordPrefix = "container/"
+)
+
+// containerRecord is a container's record, with the arn of its task
+type containerRecord struct {
+	TaskArn   string
+	Container *api.DockerContainer
+}
+
+// SaveRecords marshals each task and container separately, so that they can
+// be saved incrementally. A record is only marshalled again once its version
+// has changed since the last call.
+func (state *DockerTaskEngineState) SaveRecords() (map[string]json.RawMessage, error) {
+	state.lock.RLock()
+	defer state.lock.RUnlock()
+	state.savedLock.Lock()
+	defer state.savedLock.Unlock()
+
+	records := make(map[string]json.RawMessage, len(state.tasks)+len(state.idToContainer))
+	saved := make(map[string]savedRecord, len(records))
+	for arn, task := range state.tasks {
+		key := taskRecordPrefix + arn
+		record, err := state.savedRecord(key, task, taskVersion(task), func() ([]byte, error) {
+			return json.Marshal(task)
+		})
+		if err != nil {
+			return nil, err
+		}
+		records[key] = record.data
+		saved[key] = record
+	}
+	for id, container := range state.idToContainer {
+		key := containerRecordPrefix + id
+		taskArn := state.idToTask[id]
+		record, err := state.savedRecord(key, container, containerRecordVersion(taskArn, container), func() ([]byte, error) {
+			return json.Marshal(containerRecord{TaskArn: taskArn, Container: container})
+		})
+		if err != nil {
+			return nil, err
+		}
+		records[key] = record.data
+		saved[key] = record
+	}
+	// Records no longer in the state are dropped from the cache along with it
+	state.saved = saved
+	return records, nil
+}
+
+// savedRecord returns the record SaveRecords last returned for key if it was
+// for the same object at the same version, or else marshals it again. It must
+// be called with savedLock held.
+func (state *DockerTaskEngineState) savedRecord(key string, object interface{}, version []byte, marshal func() ([]byte, error)) (savedRecord, error) {
+	if record, ok := state.saved[key]; ok && record.object == object && bytes.Equal(record.version, version) {
+		return record, nil
+	}
+	data, err := marshal()
+	if err != nil {
+		return savedRecord{}, err
+	}
+	return savedRecord{object: object, version: version, data: data}, nil
+}
+
+// LoadRecords restores the state from the records SaveRecords returned
+func (state *DockerTaskEngineState) LoadRecords(records map[string]json.RawMessage) error {
+	saved := savedState{
+		IdToContainer: make(map[string]*api.DockerContainer),
+		IdToTask:      make(map[string]string),
+	}
+	for key, data := range records {
+		switch {
+		case strings.HasPrefix(key, taskRecordPrefix):
+			var task api.Task
+			if err := json.Unmarshal(data, &task); err != nil {
+				return err
+			}
+			saved.Tasks = append(saved.Tasks, &task)
+		case strings.HasPrefix(key, containerRecordPrefix):
+			var record containerRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				return err
+			}
+			id := strings.TrimPrefix(key, containerRecordPrefix)
+			saved.IdToContainer[id] = record.Container
+			saved.IdToTask[id] = record.TaskArn
+		default:
+			return errors.New("Could not load state; unknown record " + key)
+		}
+	}
+	return state.load(saved)
+}
+
+// load replaces the state with the saved one
+func (state *DockerTaskEngineState) load(saved savedState) error {
 	// reset it by just creating a new one and swapping shortly.
 	// This also means we don't have to lock for the remainder of this function
 	// because we are the only ones with a reference to clean
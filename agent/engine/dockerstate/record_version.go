@@ -0,0 +1,111 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package dockerstate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+// savedRecord is a record SaveRecords returned, with the task or container it
+// was marshalled from and that object's version at the time
+type savedRecord struct {
+	object  interface{}
+	version []byte
+	data    []byte
+}
+
+// A record's version encodes each field of its task or container which may
+// change once the task is added to the state. Every other field is fixed when
+// the task is received, so a record whose version hasn't changed need not be
+// marshalled again. A field which is changed after that must be written here
+// too, or changes to it will not be saved.
+type recordVersion struct {
+	bytes.Buffer
+}
+
+func (version *recordVersion) writeInt(i int64) {
+	var buf [binary.MaxVarintLen64]byte
+	version.Write(buf[:binary.PutVarint(buf[:], i)])
+}
+
+func (version *recordVersion) writeString(s string) {
+	version.writeInt(int64(len(s)))
+	version.WriteString(s)
+}
+
+func (version *recordVersion) writeTime(t time.Time) {
+	version.writeInt(t.Unix())
+	version.writeInt(int64(t.Nanosecond()))
+}
+
+func (version *recordVersion) writeContainer(container *api.Container) {
+	version.writeInt(int64(container.DesiredStatus))
+	version.writeInt(int64(container.KnownStatus))
+	version.writeInt(int64(container.AppliedStatus))
+	version.writeInt(int64(container.SentStatus))
+	if container.ApplyingError != nil {
+		version.writeInt(1)
+		version.writeString(container.ApplyingError.Err)
+	} else {
+		version.writeInt(0)
+	}
+	if container.KnownExitCode != nil {
+		version.writeInt(1)
+		version.writeInt(int64(*container.KnownExitCode))
+	} else {
+		version.writeInt(0)
+	}
+	version.writeInt(int64(len(container.KnownPortBindings)))
+	for _, binding := range container.KnownPortBindings {
+		version.writeInt(int64(binding.ContainerPort))
+		version.writeInt(int64(binding.HostPort))
+		version.writeString(binding.BindIp)
+	}
+	version.writeString(container.KnownImageId)
+	version.writeTime(container.PulledAt)
+	version.writeTime(container.CreatedAt)
+	version.writeTime(container.StartedAt)
+	version.writeTime(container.FinishedAt)
+}
+
+// taskVersion returns the version of a task's record
+func taskVersion(task *api.Task) []byte {
+	var version recordVersion
+	version.writeInt(int64(task.GetDesiredStatus()))
+	version.writeInt(int64(task.KnownStatus))
+	version.writeInt(int64(task.SentStatus))
+	version.writeTime(task.KnownTime)
+	version.writeTime(task.StartedAt)
+	version.writeTime(task.StoppedAt)
+	version.writeString(task.CredentialsId)
+	version.writeString(task.MetadataToken)
+	for _, container := range task.Containers {
+		version.writeContainer(container)
+	}
+	return version.Bytes()
+}
+
+// containerRecordVersion returns the version of a container's record
+func containerRecordVersion(taskArn string, container *api.DockerContainer) []byte {
+	var version recordVersion
+	version.writeString(taskArn)
+	version.writeString(container.DockerId)
+	version.writeString(container.DockerName)
+	version.writeContainer(container.Container)
+	return version.Bytes()
+}
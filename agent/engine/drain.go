@@ -0,0 +1,131 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+// errDraining is the reason reported for tasks rejected while draining
+var errDraining = errors.New("draining")
+
+// DrainStatus describes the progress of draining the instance
+type DrainStatus struct {
+	Draining bool
+	// Since is when draining began
+	Since time.Time
+	// Deadline is when any tasks still running will be stopped, or zero if
+	// they are left to finish on their own
+	Deadline time.Time
+	// RunningTasks is the number of tasks which have not yet stopped
+	RunningTasks int
+	// RejectedTasks is the number of new tasks refused since draining began
+	RejectedTasks int
+}
+
+// Drain stops the engine accepting new tasks, which are instead reported as
+// stopped. Tasks already running are left to finish, until timeout if it is
+// positive, after which they are stopped in dependency order. Draining an
+// instance which is already draining only changes its deadline.
+func (engine *DockerTaskEngine) Drain(timeout time.Duration) {
+	engine.drainLock.Lock()
+	defer engine.drainLock.Unlock()
+
+	if !engine.drain.Draining {
+		log.Info("Draining instance", "timeout", timeout)
+		engine.drain = DrainStatus{Draining: true, Since: ttime.Now()}
+	}
+	if engine.drainTimer != nil {
+		engine.drainTimer.Stop()
+		engine.drainTimer = nil
+	}
+	engine.drain.Deadline = time.Time{}
+	if timeout > 0 {
+		engine.drain.Deadline = ttime.Now().Add(timeout)
+		engine.drainTimer = time.AfterFunc(timeout, engine.stopDrainingTasks)
+	}
+}
+
+// Undrain resumes accepting new tasks. Tasks stopped by a drain deadline are
+// not restarted.
+func (engine *DockerTaskEngine) Undrain() {
+	engine.drainLock.Lock()
+	defer engine.drainLock.Unlock()
+
+	if engine.drain.Draining {
+		log.Info("No longer draining instance")
+	}
+	if engine.drainTimer != nil {
+		engine.drainTimer.Stop()
+		engine.drainTimer = nil
+	}
+	engine.drain = DrainStatus{}
+}
+
+// DrainStatus returns whether the instance is draining and, if so, how far
+// through it is
+func (engine *DockerTaskEngine) DrainStatus() DrainStatus {
+	engine.drainLock.Lock()
+	status := engine.drain
+	engine.drainLock.Unlock()
+
+	if status.Draining {
+		for _, task := range engine.state.AllTasks() {
+			if task.KnownStatus < api.TaskStopped {
+				status.RunningTasks++
+			}
+		}
+	}
+	return status
+}
+
+// rejectIfDraining returns errDraining, and counts the rejection, if the
+// instance is draining
+func (engine *DockerTaskEngine) rejectIfDraining() error {
+	engine.drainLock.Lock()
+	defer engine.drainLock.Unlock()
+
+	if !engine.drain.Draining {
+		return nil
+	}
+	engine.drain.RejectedTasks++
+	return errDraining
+}
+
+// stopDrainingTasks stops every task which is still running once the drain
+// deadline passes
+func (engine *DockerTaskEngine) stopDrainingTasks() {
+	log.Warn("Drain deadline passed; stopping running tasks")
+	engine.StopAllTasks()
+}
+
+// StopAllTasks stops every task which is not already stopping, in dependency
+// order
+func (engine *DockerTaskEngine) StopAllTasks() {
+	engine.processTasks.RLock()
+	defer engine.processTasks.RUnlock()
+
+	for _, task := range engine.state.AllTasks() {
+		if task.GetDesiredStatus() < api.TaskStopped {
+			log.Info("Stopping task", "task", task)
+			task.SetDesiredStatus(api.TaskStopped)
+			go engine.applyTaskState(task)
+		}
+	}
+	engine.saver.Save()
+}
@@ -0,0 +1,269 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hooks runs configured executables on the host at points in a
+// container's lifecycle, such as after it starts or before it stops. Each hook
+// is given a JSON description of the task and container on its stdin.
+package hooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("hooks")
+
+// Event is a point in a container's lifecycle at which hooks may run
+type Event string
+
+const (
+	PreCreate Event = "pre-create"
+	PostStart Event = "post-start"
+	PreStop   Event = "pre-stop"
+	PostStop  Event = "post-stop"
+)
+
+const (
+	// FailurePolicyIgnore logs a hook's failure and carries on
+	FailurePolicyIgnore = "ignore"
+	// FailurePolicyFail stops the container, reporting the failure as the
+	// reason
+	FailurePolicyFail = "fail"
+
+	defaultTimeout = 30 * time.Second
+
+	// killWait is how long to wait for a hook's output to be closed once its
+	// process group has been killed. A child which left the group may hold
+	// it open for longer.
+	killWait = 5 * time.Second
+
+	// maxOutputLength limits how much of a failing hook's output is kept for
+	// its error; stop reasons are short
+	maxOutputLength = 128
+)
+
+var validEvents = map[Event]bool{
+	PreCreate: true,
+	PostStart: true,
+	PreStop:   true,
+	PostStop:  true,
+}
+
+// Payload is the JSON document written to a hook's stdin
+type Payload struct {
+	Event         Event
+	TaskArn       string
+	Family        string
+	Version       string
+	ContainerName string
+	DockerId      string `json:",omitempty"`
+	DockerName    string `json:",omitempty"`
+	Image         string
+	KnownStatus   string
+	ExitCode      *int              `json:",omitempty"`
+	PortBindings  []api.PortBinding `json:",omitempty"`
+}
+
+// HookError describes a hook which failed. Ignored is set if the hook's
+// failure policy is "ignore".
+type HookError struct {
+	Event   Event
+	Path    string
+	Err     error
+	Output  string
+	Ignored bool
+}
+
+func (e *HookError) Error() string {
+	msg := fmt.Sprintf("%s hook %s failed: %s", e.Event, e.Path, e.Err.Error())
+	if e.Output != "" {
+		msg += ": " + e.Output
+	}
+	return msg
+}
+
+// Runner runs the hooks configured for each event
+type Runner struct {
+	hooks map[Event][]config.HookConfig
+}
+
+// NewRunner creates a Runner from the agent's hook configuration. It returns
+// an error if any event name, path or failure policy is invalid; the
+// returned Runner still runs every hook which is valid.
+func NewRunner(hookConfig map[string][]config.HookConfig) (*Runner, error) {
+	runner := &Runner{hooks: make(map[Event][]config.HookConfig)}
+	var errs []string
+	for name, hooks := range hookConfig {
+		event := Event(name)
+		if !validEvents[event] {
+			errs = append(errs, "unknown hook event "+name)
+			continue
+		}
+		for _, hook := range hooks {
+			if !strings.HasPrefix(hook.Path, "/") {
+				errs = append(errs, fmt.Sprintf("%s hook path %q must be absolute", name, hook.Path))
+				continue
+			}
+			switch hook.FailurePolicy {
+			case "", FailurePolicyIgnore, FailurePolicyFail:
+			default:
+				errs = append(errs, fmt.Sprintf("%s hook %s has unknown failure policy %q", name, hook.Path, hook.FailurePolicy))
+				continue
+			}
+			runner.hooks[event] = append(runner.hooks[event], hook)
+		}
+	}
+	if len(errs) > 0 {
+		return runner, errors.New("Invalid lifecycle hooks: " + strings.Join(errs, "; "))
+	}
+	return runner, nil
+}
+
+// Has returns true if any hooks are configured for the given event
+func (runner *Runner) Has(event Event) bool {
+	return len(runner.hooks[event]) > 0
+}
+
+// Run runs every hook for the given event in order. If a hook with the "fail"
+// policy fails, Run stops and returns a *HookError. Otherwise it runs every
+// hook and returns the first failure of a hook with the "ignore" policy, with
+// Ignored set, or nil. dockerContainer may be nil if the container has not yet
+// been created.
+func (runner *Runner) Run(event Event, task *api.Task, container *api.Container, dockerContainer *api.DockerContainer) error {
+	hooks := runner.hooks[event]
+	if len(hooks) == 0 {
+		return nil
+	}
+	payload := Payload{
+		Event:         event,
+		TaskArn:       task.Arn,
+		Family:        task.Family,
+		Version:       task.Version,
+		ContainerName: container.Name,
+		Image:         container.Image,
+		KnownStatus:   container.KnownStatus.String(),
+		ExitCode:      container.KnownExitCode,
+		PortBindings:  container.KnownPortBindings,
+	}
+	if dockerContainer != nil {
+		payload.DockerId = dockerContainer.DockerId
+		payload.DockerName = dockerContainer.DockerName
+	}
+	input, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	var ignored *HookError
+	for _, hook := range hooks {
+		output, err := runHook(hook, input)
+		if err == nil {
+			log.Debug("Hook succeeded", "event", event, "path", hook.Path, "task", task.Arn, "container", container.Name)
+			continue
+		}
+		hookErr := &HookError{Event: event, Path: hook.Path, Err: err, Output: output}
+		if hook.FailurePolicy == FailurePolicyFail {
+			log.Warn("Hook failed", "err", hookErr, "task", task.Arn, "container", container.Name)
+			return hookErr
+		}
+		log.Info("Hook failed; ignoring", "err", hookErr, "task", task.Arn, "container", container.Name)
+		if ignored == nil {
+			hookErr.Ignored = true
+			ignored = hookErr
+		}
+	}
+	if ignored != nil {
+		return ignored
+	}
+	return nil
+}
+
+// runHook runs a single hook with the given stdin, killing it and anything it
+// started if it outlives its timeout. It returns the hook's trimmed output.
+func runHook(hook config.HookConfig, input []byte) (string, error) {
+	timeout := defaultTimeout
+	if hook.TimeoutSeconds > 0 {
+		timeout = time.Duration(hook.TimeoutSeconds) * time.Second
+	}
+
+	output := &lockedBuffer{}
+	cmd := exec.Command(hook.Path, hook.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Run the hook in its own process group so that any children it starts
+	// in the background are killed with it
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		// Wait closes the hook's output once everything holding it open has
+		// exited; don't wait on a child which escaped the process group
+		select {
+		case <-done:
+		case <-time.After(killWait):
+			log.Warn("Hook output still open after killing it", "path", hook.Path)
+		}
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return trimOutput(output.String()), err
+}
+
+// lockedBuffer is a bytes.Buffer which may be read while a hook which
+// outlived its timeout is still writing to it
+type lockedBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func trimOutput(output string) string {
+	output = strings.TrimSpace(output)
+	if len(output) > maxOutputLength {
+		output = output[:maxOutputLength]
+	}
+	return output
+}
@@ -0,0 +1,163 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hooks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+func writeScript(t *testing.T, dir, name, body string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testTask() (*api.Task, *api.Container) {
+	container := &api.Container{Name: "web", Image: "nginx"}
+	task := &api.Task{Arn: "arn:task", Family: "fam", Version: "1", Containers: []*api.Container{container}}
+	return task, container
+}
+
+func TestNewRunnerInvalidConfig(t *testing.T) {
+	runner, err := NewRunner(map[string][]config.HookConfig{
+		"pre-create": {{Path: "/bin/true"}, {Path: "relative"}},
+		"post-start": {{Path: "/bin/true", FailurePolicy: "explode"}},
+		"mid-flight": {{Path: "/bin/true"}},
+	})
+	if err == nil {
+		t.Fatal("Expected an error for invalid hooks")
+	}
+	for _, expected := range []string{"relative", "explode", "mid-flight"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to mention %s: %v", expected, err)
+		}
+	}
+	if len(runner.hooks[PreCreate]) != 1 || len(runner.hooks[PostStart]) != 0 {
+		t.Error("Expected only valid hooks to be kept", runner.hooks)
+	}
+}
+
+func TestRunPassesPayload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "payload")
+	script := writeScript(t, dir, "capture", "cat > \"$1\"")
+
+	runner, err := NewRunner(map[string][]config.HookConfig{
+		"post-start": {{Path: script, Args: []string{out}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, container := testTask()
+	err = runner.Run(PostStart, task, container, &api.DockerContainer{DockerId: "abc", DockerName: "ecs-web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload Payload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Event != PostStart || payload.TaskArn != "arn:task" || payload.ContainerName != "web" || payload.DockerId != "abc" {
+		t.Error("Unexpected payload", payload)
+	}
+}
+
+func TestRunFailurePolicies(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := writeScript(t, dir, "fail", "echo broken; exit 3")
+	task, container := testTask()
+
+	runner, _ := NewRunner(map[string][]config.HookConfig{
+		"pre-stop": {{Path: script}},
+	})
+	err = runner.Run(PreStop, task, container, nil)
+	if hookErr, ok := err.(*HookError); !ok || !hookErr.Ignored {
+		t.Error("Expected an ignored failure to be returned as ignored", err)
+	}
+
+	runner, _ = NewRunner(map[string][]config.HookConfig{
+		"pre-stop": {{Path: script, FailurePolicy: FailurePolicyFail}},
+	})
+	err = runner.Run(PreStop, task, container, nil)
+	if hookErr, ok := err.(*HookError); !ok || hookErr.Ignored {
+		t.Fatal("Expected failure", err)
+	}
+	if !strings.Contains(err.Error(), "pre-stop hook "+script+" failed") || !strings.Contains(err.Error(), "broken") {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := writeScript(t, dir, "slow", "exec sleep 10")
+	task, container := testTask()
+
+	runner, _ := NewRunner(map[string][]config.HookConfig{
+		"pre-create": {{Path: script, TimeoutSeconds: 1, FailurePolicy: FailurePolicyFail}},
+	})
+	err = runner.Run(PreCreate, task, container, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Error("Expected timeout error", err)
+	}
+}
+
+func TestRunTimeoutKillsBackgroundChildren(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hooks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := writeScript(t, dir, "background", "sleep 30 &\nsleep 30")
+	task, container := testTask()
+
+	runner, _ := NewRunner(map[string][]config.HookConfig{
+		"pre-stop": {{Path: script, TimeoutSeconds: 1}},
+	})
+	start := time.Now()
+	err = runner.Run(PreStop, task, container, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Error("Expected timeout error", err)
+	}
+	if elapsed := time.Since(start); elapsed > killWait {
+		t.Error("Expected the hook's children to be killed with it, but it took", elapsed)
+	}
+}
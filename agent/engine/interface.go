@@ -15,6 +15,7 @@ package engine
 
 import (
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 )
 
@@ -28,12 +29,18 @@ type TaskEngine interface {
 
 	TaskEvents() <-chan api.ContainerStateChange
 	SetSaver(statemanager.Saver)
+	// SetContainerInstanceArn tells the engine which container instance it is
+	// running tasks for, once that is known
+	SetContainerInstanceArn(string)
 
 	// AddTask adds a new task to the task engine and manages its container's
 	// lifecycle. If it returns an error, the task was not added.
 	AddTask(*api.Task) error
 
 	ListTasks() ([]*api.Task, error)
+	// TaskState returns a read-only view of the tasks and containers the
+	// engine manages
+	TaskState() dockerstate.TaskEngineState
 
 	UnmarshalJSON([]byte) error
 	MarshalJSON() ([]byte, error)
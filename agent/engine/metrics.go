@@ -0,0 +1,82 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package engine
+
+import (
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+)
+
+// Transitions can include pulling large images, so their buckets reach from
+// 10ms to roughly 45 minutes
+var transitionBuckets = metrics.ExponentialBuckets(0.01, 4, 10)
+
+var (
+	transitionSeconds = metrics.NewHistogram("ecs_agent_container_transition_seconds",
+		"Time taken to move a container to a new status, including retries.",
+		transitionBuckets, "transition")
+	taskStartSeconds = metrics.NewHistogram("ecs_agent_task_start_seconds",
+		"Time from a task being received from ACS to it running.",
+		transitionBuckets)
+	dockerCalls = metrics.NewCounter("ecs_agent_docker_api_calls_total",
+		"Calls made to the Docker API.", "operation")
+	dockerErrors = metrics.NewCounter("ecs_agent_docker_api_errors_total",
+		"Calls to the Docker API which returned an error.", "operation")
+)
+
+// transitionNames are the labels used for each transition the engine times
+var transitionNames = map[api.ContainerStatus]string{
+	api.ContainerPulled:  "pull",
+	api.ContainerCreated: "create",
+	api.ContainerRunning: "start",
+	api.ContainerStopped: "stop",
+}
+
+// countDockerCall records a call to the Docker API and whether it failed. It
+// is intended to be deferred with a pointer to the caller's named error.
+func countDockerCall(operation string, err *error) {
+	dockerCalls.Inc(operation)
+	if *err != nil {
+		dockerErrors.Inc(operation)
+	}
+}
+
+// registerStatusGauges reports the number of tasks and containers in each
+// known status. It replaces the gauges of any previously initialized engine.
+func (engine *DockerTaskEngine) registerStatusGauges() {
+	metrics.RegisterGaugeFunc("ecs_agent_tasks", "Tasks managed by the agent, by known status.", []string{"status"}, func() []metrics.Sample {
+		counts := make(map[string]int)
+		for _, task := range engine.state.AllTasks() {
+			counts[task.KnownStatus.String()]++
+		}
+		return countSamples(counts)
+	})
+	metrics.RegisterGaugeFunc("ecs_agent_containers", "Containers managed by the agent, by known status.", []string{"status"}, func() []metrics.Sample {
+		counts := make(map[string]int)
+		for _, task := range engine.state.AllTasks() {
+			for _, container := range task.Containers {
+				counts[container.KnownStatus.String()]++
+			}
+		}
+		return countSamples(counts)
+	})
+}
+
+func countSamples(counts map[string]int) []metrics.Sample {
+	samples := make([]metrics.Sample, 0, len(counts))
+	for status, count := range counts {
+		samples = append(samples, metrics.Sample{LabelValues: []string{status}, Value: float64(count)})
+	}
+	return samples
+}
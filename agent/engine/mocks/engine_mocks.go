@@ -17,9 +17,11 @@
 package mock_engine
 
 import (
+	io "io"
 	go_dockerclient "github.com/fsouza/go-dockerclient"
 	gomock "code.google.com/p/gomock/gomock"
 	api "github.com/aws/amazon-ecs-agent/agent/api"
+	dockerstate "github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	statemanager "github.com/aws/amazon-ecs-agent/agent/statemanager"
 	engine "github.com/aws/amazon-ecs-agent/agent/engine"
 )
@@ -103,6 +105,14 @@ func (_mr *_MockTaskEngineRecorder) MustInit() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "MustInit")
 }
 
+func (_m *MockTaskEngine) SetContainerInstanceArn(_param0 string) {
+	_m.ctrl.Call(_m, "SetContainerInstanceArn", _param0)
+}
+
+func (_mr *_MockTaskEngineRecorder) SetContainerInstanceArn(arg0 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "SetContainerInstanceArn", arg0)
+}
+
 func (_m *MockTaskEngine) SetSaver(_param0 statemanager.Saver) {
 	_m.ctrl.Call(_m, "SetSaver", _param0)
 }
@@ -121,6 +131,16 @@ func (_mr *_MockTaskEngineRecorder) TaskEvents() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "TaskEvents")
 }
 
+func (_m *MockTaskEngine) TaskState() dockerstate.TaskEngineState {
+	ret := _m.ctrl.Call(_m, "TaskState")
+	ret0, _ := ret[0].(dockerstate.TaskEngineState)
+	return ret0
+}
+
+func (_mr *_MockTaskEngineRecorder) TaskState() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "TaskState")
+}
+
 func (_m *MockTaskEngine) UnmarshalJSON(_param0 []byte) error {
 	ret := _m.ctrl.Call(_m, "UnmarshalJSON", _param0)
 	ret0, _ := ret[0].(error)
@@ -175,6 +195,16 @@ func (_mr *_MockDockerClientRecorder) ContainerEvents() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerEvents")
 }
 
+func (_m *MockDockerClient) ContainerLogs(_param0 string, _param1 int, _param2 bool, _param3 io.Writer) error {
+	ret := _m.ctrl.Call(_m, "ContainerLogs", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) ContainerLogs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerLogs", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockDockerClient) CreateContainer(_param0 *go_dockerclient.Config, _param1 string) (string, error) {
 	ret := _m.ctrl.Call(_m, "CreateContainer", _param0, _param1)
 	ret0, _ := ret[0].(string)
@@ -208,6 +238,17 @@ func (_mr *_MockDockerClientRecorder) GetContainerName(arg0 interface{}) *gomock
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetContainerName", arg0)
 }
 
+func (_m *MockDockerClient) Info() (*go_dockerclient.Env, error) {
+	ret := _m.ctrl.Call(_m, "Info")
+	ret0, _ := ret[0].(*go_dockerclient.Env)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDockerClientRecorder) Info() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Info")
+}
+
 func (_m *MockDockerClient) InspectContainer(_param0 string) (*go_dockerclient.Container, error) {
 	ret := _m.ctrl.Call(_m, "InspectContainer", _param0)
 	ret0, _ := ret[0].(*go_dockerclient.Container)
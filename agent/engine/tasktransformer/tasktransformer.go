@@ -0,0 +1,115 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package tasktransformer provides a registry of plugins which may modify or
+// reject tasks after they are unmarshalled and before the engine begins to
+// manage them. Transformers might inject sidecar containers, add environment
+// variables, or rewrite images.
+//
+// Transformers register themselves, typically from an init function, and are
+// only run if they are enabled by name in the agent's configuration.
+package tasktransformer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("tasktransformer")
+
+// A Transformer modifies a task in place before it is run.
+type Transformer interface {
+	// Name is the unique name the transformer is enabled by in configuration
+	Name() string
+	// Transform modifies the given task. It may be called more than once for
+	// the same task and must be idempotent; for example, a sidecar should only
+	// be added if the task does not already contain it. Returning an error
+	// rejects the task, which is then stopped with the error as its reason.
+	Transform(task *api.Task) error
+}
+
+var (
+	registry     = make(map[string]Transformer)
+	registryLock sync.RWMutex
+)
+
+// Register makes a transformer available to be enabled by name. It panics if
+// a transformer with the same name is already registered.
+func Register(transformer Transformer) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	name := transformer.Name()
+	if _, exists := registry[name]; exists {
+		panic("tasktransformer: Register called twice for " + name)
+	}
+	registry[name] = transformer
+}
+
+// Registered returns the names of all registered transformers, sorted
+func Registered() []string {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Chain is an ordered list of enabled transformers
+type Chain struct {
+	transformers []Transformer
+}
+
+// NewChain creates a Chain of the named transformers, which run in the order
+// given. If any name is not registered, the returned Chain contains only the
+// known transformers and an error naming the unknown ones is returned.
+func NewChain(names []string) (*Chain, error) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	chain := &Chain{}
+	unknown := []string{}
+	for _, name := range names {
+		transformer, ok := registry[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		chain.transformers = append(chain.transformers, transformer)
+	}
+	if len(unknown) > 0 {
+		return chain, fmt.Errorf("Unknown task transformers: %s", strings.Join(unknown, ", "))
+	}
+	return chain, nil
+}
+
+// Apply runs each transformer in the chain against the task, stopping at the
+// first one which rejects it
+func (chain *Chain) Apply(task *api.Task) error {
+	for _, transformer := range chain.transformers {
+		if err := transformer.Transform(task); err != nil {
+			log.Warn("Task rejected by transformer", "task", task.Arn, "transformer", transformer.Name(), "err", err)
+			return fmt.Errorf("Task rejected by %s: %s", transformer.Name(), err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package tasktransformer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+type envTransformer struct{}
+
+func (envTransformer) Name() string { return "test-env" }
+func (envTransformer) Transform(task *api.Task) error {
+	for _, container := range task.Containers {
+		if container.Environment == nil {
+			container.Environment = make(map[string]string)
+		}
+		container.Environment["INJECTED"] = "true"
+	}
+	return nil
+}
+
+type rejectTransformer struct{}
+
+func (rejectTransformer) Name() string { return "test-reject" }
+func (rejectTransformer) Transform(task *api.Task) error {
+	return errors.New("not allowed")
+}
+
+func init() {
+	Register(envTransformer{})
+	Register(rejectTransformer{})
+}
+
+func TestRegister(t *testing.T) {
+	if !reflect.DeepEqual(Registered(), []string{"test-env", "test-reject"}) {
+		t.Error("Unexpected registered transformers", Registered())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a duplicate name to panic")
+		}
+	}()
+	Register(envTransformer{})
+}
+
+func TestChainApply(t *testing.T) {
+	chain, err := NewChain([]string{"test-env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &api.Task{Containers: []*api.Container{&api.Container{Name: "c1"}}}
+	if err := chain.Apply(task); err != nil {
+		t.Error("Unexpected rejection", err)
+	}
+	if task.Containers[0].Environment["INJECTED"] != "true" {
+		t.Error("Expected the transformer to have run")
+	}
+
+	chain, _ = NewChain([]string{"test-reject", "test-env"})
+	task = &api.Task{Containers: []*api.Container{&api.Container{Name: "c1"}}}
+	if err := chain.Apply(task); err == nil {
+		t.Error("Expected the task to be rejected")
+	}
+	if task.Containers[0].Environment != nil {
+		t.Error("Transformers after a rejection should not run")
+	}
+}
+
+func TestNewChainUnknown(t *testing.T) {
+	chain, err := NewChain([]string{"test-env", "missing"})
+	if err == nil {
+		t.Error("Expected an error for an unknown transformer")
+	}
+	if len(chain.transformers) != 1 {
+		t.Error("Expected the known transformer to still be enabled")
+	}
+}
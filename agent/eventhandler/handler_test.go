@@ -14,6 +14,7 @@
 package eventhandler
 
 import (
+	"container/list"
 	"errors"
 	"strconv"
 	"sync/atomic"
@@ -43,6 +44,12 @@ func (m *MockECSClient) RegisterContainerInstance() (string, error) {
 func (m *MockECSClient) DiscoverPollEndpoint(string) (string, error) {
 	return "", nil
 }
+func (m *MockECSClient) PutContainerInstanceAttributes(string, map[string]string) error {
+	return nil
+}
+func (m *MockECSClient) DeleteContainerInstanceAttributes(string, []string) error {
+	return nil
+}
 func (m *MockECSClient) SubmitTaskStateChange(change api.ContainerStateChange) utils.RetriableError {
 	return m.submitTaskStateChange(change)
 }
@@ -337,3 +344,31 @@ func TestSendsEvents(t *testing.T) {
 	default:
 	}
 }
+
+func TestPendingEvents(t *testing.T) {
+	sentEvent := newSendableEvent(taskEvent("pending"))
+	sentEvent.containerSent = true
+	taskList := &eventList{List: list.New()}
+	taskList.PushBack(sentEvent)
+	taskList.PushBack(newSendableEvent(contEvent("pending")))
+
+	handler.Lock()
+	handler.taskMap["pending"] = taskList
+	handler.Unlock()
+	defer func() {
+		handler.Lock()
+		delete(handler.taskMap, "pending")
+		handler.Unlock()
+	}()
+
+	pending := PendingEvents()["pending"]
+	if len(pending) != 2 {
+		t.Fatal("Expected two pending events, got", len(pending))
+	}
+	if !pending[0].ContainerSent || pending[0].TaskSent || pending[0].TaskStatus != "RUNNING" {
+		t.Error("Expected the first event to be a partially sent task change, got", pending[0])
+	}
+	if pending[1].TaskStatus != "" || pending[1].Status != "RUNNING" {
+		t.Error("Expected the second event to be a container change, got", pending[1])
+	}
+}
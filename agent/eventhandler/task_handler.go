@@ -18,11 +18,19 @@ import (
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 )
 
 var handler taskHandler
 
+var (
+	pendingEvents = metrics.NewGauge("ecs_agent_pending_state_changes",
+		"State changes waiting to be submitted to ECS.")
+	submissionFailures = metrics.NewCounter("ecs_agent_state_change_submission_failures_total",
+		"Failed attempts to submit a state change to ECS.", "type")
+)
+
 func init() {
 	handler = newTaskHandler()
 }
@@ -52,6 +60,7 @@ func AddTaskEvent(change api.ContainerStateChange, client api.ECSClient) {
 
 	// Update taskEvent
 	taskList.PushBack(newSendableEvent(change))
+	pendingEvents.Inc()
 
 	if !taskList.sending {
 		taskList.sending = true
@@ -59,6 +68,27 @@ func AddTaskEvent(change api.ContainerStateChange, client api.ECSClient) {
 	}
 }
 
+// PendingEvents returns the state changes of each task which are waiting to be
+// submitted to ECS, in the order they will be submitted
+func PendingEvents() map[string][]PendingEvent {
+	handler.RLock()
+	lists := make(map[string]*eventList, len(handler.taskMap))
+	for taskArn, taskList := range handler.taskMap {
+		lists[taskArn] = taskList
+	}
+	handler.RUnlock()
+
+	pending := make(map[string][]PendingEvent)
+	for taskArn, taskList := range lists {
+		taskList.Lock()
+		for elem := taskList.Front(); elem != nil; elem = elem.Next() {
+			pending[taskArn] = append(pending[taskArn], elem.Value.(*sendableEvent).pendingEvent())
+		}
+		taskList.Unlock()
+	}
+	return pending
+}
+
 // Continuously retries sending an event until it succeeds, sleeping between each
 // attempt
 func SubmitTaskEvents(events *eventList, client api.ECSClient) {
@@ -103,6 +133,9 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 			if event.containerShouldBeSent() {
 				llog.Info("Sending container change", "change", event.ContainerStateChange)
 				contErr = client.SubmitContainerStateChange(event.ContainerStateChange)
+				if contErr != nil {
+					submissionFailures.Inc("container")
+				}
 				if contErr == nil || !contErr.Retry() {
 					// submitted or can't be retried; ensure we don't retry it
 					event.containerSent = true
@@ -116,6 +149,9 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 			if event.taskShouldBeSent() {
 				llog.Info("Sending task change", "change", event.ContainerStateChange.TaskStatus)
 				taskErr = client.SubmitTaskStateChange(event.ContainerStateChange)
+				if taskErr != nil {
+					submissionFailures.Inc("task")
+				}
 				if taskErr == nil || !taskErr.Retry() {
 					// submitted or can't be retried; ensure we don't retry it
 					event.taskSent = true
@@ -129,12 +165,14 @@ func SubmitTaskEvents(events *eventList, client api.ECSClient) {
 			if contErr == nil && taskErr == nil {
 				llog.Debug("Successfully submitted event")
 				events.Remove(eventToSubmit)
+				pendingEvents.Dec()
 				// We had a success so reset our backoff
 				backoff.Reset()
 			} else if (contErr == nil || !contErr.Retry()) && (taskErr == nil || !taskErr.Retry()) {
 				// Error, but not retriable
 				llog.Debug("Unretriable error for event", "status", event.ContainerStateChange)
 				events.Remove(eventToSubmit)
+				pendingEvents.Dec()
 			} else {
 				retErr = utils.NewMultiError(contErr, taskErr)
 			}
@@ -41,6 +41,33 @@ func newSendableEvent(event api.ContainerStateChange) *sendableEvent {
 	}
 }
 
+// PendingEvent describes a state change which is waiting to be submitted, and
+// which parts of it have already been sent
+type PendingEvent struct {
+	TaskArn       string
+	ContainerName string
+	Status        string
+	TaskStatus    string `json:",omitempty"`
+	Reason        string `json:",omitempty"`
+	ContainerSent bool
+	TaskSent      bool
+}
+
+func (event *sendableEvent) pendingEvent() PendingEvent {
+	pending := PendingEvent{
+		TaskArn:       event.TaskArn,
+		ContainerName: event.ContainerName,
+		Status:        event.Status.String(),
+		Reason:        event.Reason,
+		ContainerSent: event.containerSent,
+		TaskSent:      event.taskSent,
+	}
+	if event.TaskStatus != api.TaskStatusNone {
+		pending.TaskStatus = event.TaskStatus.String()
+	}
+	return pending
+}
+
 func (event *sendableEvent) taskShouldBeSent() bool {
 	if event.TaskStatus == api.TaskStatusNone {
 		return false // container only event
@@ -0,0 +1,218 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package eventstream fans the state changes the task engine emits out to any
+// number of subscribers, such as streaming clients of the introspection API.
+package eventstream
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+const (
+	// DefaultHistory is the number of recent events a feed holds for
+	// subscribers resuming from an earlier sequence number
+	DefaultHistory = 1000
+
+	// subscriberBuffer is the number of events which may be waiting for a
+	// subscriber before it is considered too slow and dropped
+	subscriberBuffer = 256
+)
+
+// ErrHistoryLost is returned when a subscriber asks to resume from a sequence
+// number whose following events are no longer held, for example because the
+// agent restarted. The subscriber should resynchronize, such as by listing
+// tasks, and then subscribe for new events only.
+var ErrHistoryLost = errors.New("eventstream: events following the given sequence number are no longer available")
+
+// Event is a snapshot of a single container state change and, if it caused
+// one, the change in its task's status. Sequence numbers start at 1 and
+// increase by one for each event published to a feed; they restart when the
+// agent does.
+type Event struct {
+	Seq           uint64
+	Time          time.Time
+	TaskArn       string
+	Family        string            `json:",omitempty"`
+	Version       string            `json:",omitempty"`
+	ContainerName string            `json:",omitempty"`
+	Status        string            `json:",omitempty"`
+	ExitCode      *int              `json:",omitempty"`
+	PortBindings  []api.PortBinding `json:",omitempty"`
+	Reason        string            `json:",omitempty"`
+	TaskStatus    string            `json:",omitempty"`
+}
+
+// Filter selects the events a subscriber receives. Empty fields match
+// everything.
+type Filter struct {
+	TaskArn string
+	Family  string
+}
+
+func (filter Filter) matches(event *Event) bool {
+	if filter.TaskArn != "" && filter.TaskArn != event.TaskArn {
+		return false
+	}
+	if filter.Family != "" && filter.Family != event.Family {
+		return false
+	}
+	return true
+}
+
+// Feed numbers published events, holds the most recent of them and passes
+// each on to its subscribers without ever blocking the publisher.
+type Feed struct {
+	lock        sync.Mutex
+	seq         uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewFeed returns a feed which holds up to historySize events for resuming
+// subscribers
+func NewFeed(historySize int) *Feed {
+	return &Feed{
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of a feed which match its filter
+type Subscription struct {
+	feed    *Feed
+	filter  Filter
+	events  chan Event
+	closed  bool
+	dropped bool
+}
+
+// Events returns the channel on which events are delivered. It is closed when
+// the subscription is closed or dropped.
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+// Dropped returns true if the subscription was closed because it did not keep
+// up with the feed
+func (sub *Subscription) Dropped() bool {
+	sub.feed.lock.Lock()
+	defer sub.feed.lock.Unlock()
+	return sub.dropped
+}
+
+// Close unsubscribes from the feed. It may be called more than once.
+func (sub *Subscription) Close() {
+	sub.feed.lock.Lock()
+	defer sub.feed.lock.Unlock()
+	sub.feed.unsubscribe(sub)
+}
+
+// Publish adds a state change to the feed and returns the event it was
+// recorded as
+func (feed *Feed) Publish(change api.ContainerStateChange) Event {
+	event := Event{
+		Time:          time.Now().UTC(),
+		TaskArn:       change.TaskArn,
+		ContainerName: change.ContainerName,
+		Status:        change.Status.String(),
+		PortBindings:  change.PortBindings,
+		Reason:        change.Reason,
+	}
+	if change.ExitCode != nil {
+		exitCode := *change.ExitCode
+		event.ExitCode = &exitCode
+	}
+	if change.Task != nil {
+		event.Family = change.Task.Family
+		event.Version = change.Task.Version
+	}
+	if change.TaskStatus != api.TaskStatusNone {
+		event.TaskStatus = change.TaskStatus.String()
+	}
+
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+	feed.seq++
+	event.Seq = feed.seq
+	if feed.historySize > 0 {
+		if len(feed.history) >= feed.historySize {
+			feed.history = append(feed.history[:0], feed.history[len(feed.history)-feed.historySize+1:]...)
+		}
+		feed.history = append(feed.history, event)
+	}
+	for sub := range feed.subscribers {
+		if !sub.filter.matches(&event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped = true
+			feed.unsubscribe(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe returns a subscription to the events matching filter. If since is
+// not zero, the held events following that sequence number are delivered
+// first; if any of them are no longer held, ErrHistoryLost is returned.
+func (feed *Feed) Subscribe(since uint64, filter Filter) (*Subscription, error) {
+	feed.lock.Lock()
+	defer feed.lock.Unlock()
+
+	var backlog []Event
+	if since != 0 {
+		if since > feed.seq {
+			// From a previous run of the agent
+			return nil, ErrHistoryLost
+		}
+		if since < feed.seq {
+			if len(feed.history) == 0 || feed.history[0].Seq > since+1 {
+				return nil, ErrHistoryLost
+			}
+			for _, event := range feed.history[since+1-feed.history[0].Seq:] {
+				if filter.matches(&event) {
+					backlog = append(backlog, event)
+				}
+			}
+		}
+	}
+
+	sub := &Subscription{
+		feed:   feed,
+		filter: filter,
+		events: make(chan Event, len(backlog)+subscriberBuffer),
+	}
+	for _, event := range backlog {
+		sub.events <- event
+	}
+	feed.subscribers[sub] = struct{}{}
+	return sub, nil
+}
+
+// unsubscribe must be called with the feed's lock held
+func (feed *Feed) unsubscribe(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(feed.subscribers, sub)
+	close(sub.events)
+}
@@ -0,0 +1,161 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package eventstream
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+func change(taskArn, family string) api.ContainerStateChange {
+	return api.ContainerStateChange{
+		TaskArn:       taskArn,
+		ContainerName: "c",
+		Status:        api.ContainerRunning,
+		TaskStatus:    api.TaskRunning,
+		Task:          &api.Task{Arn: taskArn, Family: family},
+	}
+}
+
+// received returns the sequence numbers of the events waiting on sub
+func received(sub *Subscription) []uint64 {
+	var seqs []uint64
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return seqs
+			}
+			seqs = append(seqs, event.Seq)
+		default:
+			return seqs
+		}
+	}
+}
+
+func equal(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPublishSnapshotsChange(t *testing.T) {
+	feed := NewFeed(10)
+	exitCode := 1
+	c := change("t1", "f1")
+	c.ExitCode = &exitCode
+	event := feed.Publish(c)
+	exitCode = 2
+
+	if event.Seq != 1 || event.TaskArn != "t1" || event.Family != "f1" || event.ContainerName != "c" {
+		t.Error("Unexpected event", event)
+	}
+	if event.Status != "RUNNING" || event.TaskStatus != "RUNNING" {
+		t.Error("Unexpected statuses", event.Status, event.TaskStatus)
+	}
+	if event.ExitCode == nil || *event.ExitCode != 1 {
+		t.Error("Expected the exit code to be copied")
+	}
+	c.TaskStatus = api.TaskStatusNone
+	if event = feed.Publish(c); event.Seq != 2 || event.TaskStatus != "" {
+		t.Error("Expected no task status", event)
+	}
+}
+
+func TestSubscribeFilters(t *testing.T) {
+	feed := NewFeed(10)
+	all, _ := feed.Subscribe(0, Filter{})
+	byArn, _ := feed.Subscribe(0, Filter{TaskArn: "t2"})
+	byFamily, _ := feed.Subscribe(0, Filter{Family: "f1"})
+
+	feed.Publish(change("t1", "f1"))
+	feed.Publish(change("t2", "f2"))
+	feed.Publish(change("t3", "f1"))
+
+	if seqs := received(all); !equal(seqs, []uint64{1, 2, 3}) {
+		t.Error("Unexpected events", seqs)
+	}
+	if seqs := received(byArn); !equal(seqs, []uint64{2}) {
+		t.Error("Unexpected events for task", seqs)
+	}
+	if seqs := received(byFamily); !equal(seqs, []uint64{1, 3}) {
+		t.Error("Unexpected events for family", seqs)
+	}
+}
+
+func TestSubscribeResumes(t *testing.T) {
+	feed := NewFeed(3)
+	for i := 0; i < 5; i++ {
+		feed.Publish(change("t1", "f1"))
+	}
+
+	sub, err := feed.Subscribe(2, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed.Publish(change("t1", "f1"))
+	if seqs := received(sub); !equal(seqs, []uint64{3, 4, 5, 6}) {
+		t.Error("Unexpected events", seqs)
+	}
+
+	sub, err = feed.Subscribe(6, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seqs := received(sub); len(seqs) != 0 {
+		t.Error("Expected no backlog when up to date", seqs)
+	}
+
+	if _, err = feed.Subscribe(1, Filter{}); err != ErrHistoryLost {
+		t.Error("Expected events before the history to be lost", err)
+	}
+	if _, err = feed.Subscribe(7, Filter{}); err != ErrHistoryLost {
+		t.Error("Expected a sequence number from the future to be lost", err)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	feed := NewFeed(0)
+	slow, _ := feed.Subscribe(0, Filter{})
+	other, _ := feed.Subscribe(0, Filter{TaskArn: "t2"})
+	for i := 0; i < subscriberBuffer+1; i++ {
+		feed.Publish(change("t1", "f1"))
+	}
+
+	if !slow.Dropped() {
+		t.Error("Expected the slow subscriber to be dropped")
+	}
+	if seqs := received(slow); len(seqs) != subscriberBuffer {
+		t.Error("Expected the buffered events before the channel closed", len(seqs))
+	}
+	if _, ok := <-slow.Events(); ok {
+		t.Error("Expected the events channel to be closed")
+	}
+	if other.Dropped() {
+		t.Error("Expected a subscriber not receiving the events to stay")
+	}
+	slow.Close()
+	other.Close()
+	other.Close()
+	if _, ok := <-other.Events(); ok {
+		t.Error("Expected the events channel to be closed")
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+const (
+	statusUnauthorized     = 401
+	statusMethodNotAllowed = 405
+)
+
+const (
+	// introspectionSocketMode allows the agent's user and group to use the
+	// read-only socket
+	introspectionSocketMode os.FileMode = 0660
+	// adminSocketMode allows only the agent's user to use the admin socket
+	adminSocketMode os.FileMode = 0600
+)
+
+// CheckIntrospectionConfig returns an error if the introspection API's TLS
+// settings are inconsistent or its certificates cannot be loaded, or if
+// admin endpoints are enabled without an admin socket to serve them on
+func CheckIntrospectionConfig(cfg *config.Config) error {
+	if cfg.ProfilingEnabled && cfg.IntrospectionAdminSocket == "" {
+		return errors.New("profiling requires an introspection admin socket")
+	}
+	_, err := introspectionTLSConfig(cfg)
+	return err
+}
+
+// introspectionTLSConfig returns the TLS configuration of the introspection
+// API, or nil if it is served over plain HTTP
+func introspectionTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	if cfg.IntrospectionTLSCertFile == "" && cfg.IntrospectionTLSKeyFile == "" {
+		if cfg.IntrospectionClientCAFile != "" {
+			return nil, errors.New("introspection client certificates require a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	if cfg.IntrospectionTLSCertFile == "" || cfg.IntrospectionTLSKeyFile == "" {
+		return nil, errors.New("introspection TLS requires both a certificate and a key")
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.IntrospectionTLSCertFile, cfg.IntrospectionTLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.IntrospectionClientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(cfg.IntrospectionClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.ClientCAs = x509.NewCertPool()
+		if !tlsConfig.ClientCAs.AppendCertsFromPEM(caPEM) {
+			return nil, errors.New("no certificates found in " + cfg.IntrospectionClientCAFile)
+		}
+		if cfg.IntrospectionAuthToken == "" {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			// Clients without a certificate may present the token instead
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// introspectionListener listens where the read-only introspection API is
+// configured to be served
+func introspectionListener(cfg *config.Config) (net.Listener, error) {
+	if cfg.IntrospectionSocket != "" {
+		return secureListener(cfg, func() (net.Listener, error) {
+			return listenUnix(cfg.IntrospectionSocket, introspectionSocketMode)
+		})
+	}
+	address := net.JoinHostPort(cfg.IntrospectionAddress, strconv.Itoa(int(cfg.IntrospectionPort)))
+	return secureListener(cfg, func() (net.Listener, error) {
+		return net.Listen("tcp", address)
+	})
+}
+
+// adminListener listens on the admin API's socket
+func adminListener(cfg *config.Config) (net.Listener, error) {
+	return secureListener(cfg, func() (net.Listener, error) {
+		return listenUnix(cfg.IntrospectionAdminSocket, adminSocketMode)
+	})
+}
+
+// secureListener wraps the listener returned by listen in TLS if the
+// introspection API is configured to use it
+func secureListener(cfg *config.Config, listen func() (net.Listener, error)) (net.Listener, error) {
+	tlsConfig, err := introspectionTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := listen()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+	return listener, nil
+}
+
+// listenUnix listens on a unix socket at path, replacing any socket left by a
+// previous run, and then restricts who may connect to it. Until then, the
+// umask keeps others from connecting, as that needs write permission.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// authHandler only passes on requests made with an accepted client
+// certificate or bearing the configured token
+type authHandler struct {
+	token       string
+	clientCerts bool
+	h           http.Handler
+}
+
+// newAuthHandler requires requests to h to be authenticated if the
+// introspection API is configured to authenticate them
+func newAuthHandler(cfg *config.Config, h http.Handler) http.Handler {
+	if cfg.IntrospectionAuthToken == "" && cfg.IntrospectionClientCAFile == "" {
+		return h
+	}
+	return authHandler{
+		token:       cfg.IntrospectionAuthToken,
+		clientCerts: cfg.IntrospectionClientCAFile != "",
+		h:           h,
+	}
+}
+
+func (ah authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !ah.authorized(r) {
+		if ah.token != "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, "Unauthorized", statusUnauthorized)
+		return
+	}
+	ah.h.ServeHTTP(w, r)
+}
+
+func (ah authHandler) authorized(r *http.Request) bool {
+	if ah.clientCerts && r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		return true
+	}
+	if ah.token == "" {
+		return false
+	}
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := strings.TrimPrefix(authorization, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(ah.token)) == 1
+}
+
+// readOnlyHandler refuses any request which is not a GET or HEAD, so that
+// endpoints changing the agent's behaviour cannot be reached through the
+// read-only listener even if registered there by mistake
+type readOnlyHandler struct{ h http.Handler }
+
+func (roh readOnlyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", statusMethodNotAllowed)
+		return
+	}
+	roh.h.ServeHTTP(w, r)
+}
@@ -0,0 +1,248 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("ok"))
+})
+
+func serve(h http.Handler, method string, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "http://localhost/v1/tasks", nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestReadOnlyHandler(t *testing.T) {
+	h := readOnlyHandler{okHandler}
+	for _, method := range []string{"GET", "HEAD"} {
+		if w := serve(h, method, nil); w.Code != statusOK {
+			t.Errorf("Expected %s to be allowed, got %d", method, w.Code)
+		}
+	}
+	for _, method := range []string{"POST", "PUT", "DELETE", "PATCH"} {
+		if w := serve(h, method, nil); w.Code != statusMethodNotAllowed {
+			t.Errorf("Expected %s to be refused, got %d", method, w.Code)
+		}
+	}
+}
+
+func TestAuthHandlerToken(t *testing.T) {
+	if _, ok := newAuthHandler(&config.Config{}, okHandler).(authHandler); ok {
+		t.Error("Expected no authentication when none is configured")
+	}
+
+	h := newAuthHandler(&config.Config{IntrospectionAuthToken: "secret"}, okHandler)
+	w := serve(h, "GET", nil)
+	if w.Code != statusUnauthorized || w.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Error("Expected a request without a token to be refused", w.Code, w.Header())
+	}
+	for _, authorization := range []string{"Bearer wrong", "secret", "Basic secret", "Bearer "} {
+		if w := serve(h, "GET", http.Header{"Authorization": {authorization}}); w.Code != statusUnauthorized {
+			t.Errorf("Expected %q to be refused, got %d", authorization, w.Code)
+		}
+	}
+	if w := serve(h, "GET", http.Header{"Authorization": {"Bearer secret"}}); w.Code != statusOK {
+		t.Error("Expected the token to be accepted, got", w.Code)
+	}
+}
+
+func TestIntrospectionTLSConfigErrors(t *testing.T) {
+	cases := []config.Config{
+		{IntrospectionTLSCertFile: "/cert.pem"},
+		{IntrospectionTLSKeyFile: "/key.pem"},
+		{IntrospectionClientCAFile: "/ca.pem"},
+		{IntrospectionTLSCertFile: "/does/not/exist", IntrospectionTLSKeyFile: "/does/not/exist"},
+		{ProfilingEnabled: true},
+	}
+	for _, cfg := range cases {
+		if err := CheckIntrospectionConfig(&cfg); err == nil {
+			t.Error("Expected an error for", cfg)
+		}
+	}
+	if err := CheckIntrospectionConfig(&config.Config{}); err != nil {
+		t.Error("Expected plain HTTP to be valid", err)
+	}
+}
+
+func TestListenUnixReplacesStaleSocket(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "introspection")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "admin.sock")
+
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Leave the socket file behind as a crashed agent would
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenUnix(path, adminSocketMode)
+	if err != nil {
+		t.Fatal("Expected the stale socket to be replaced", err)
+	}
+	defer listener.Close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != adminSocketMode {
+		t.Error("Unexpected socket mode", info.Mode())
+	}
+}
+
+func TestServeAdminHttpProfiling(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "introspection")
+	defer os.RemoveAll(dir)
+	cfg := &config.Config{
+		IntrospectionAdminSocket: filepath.Join(dir, "admin.sock"),
+		ProfilingEnabled:         true,
+	}
+	go ServeAdminHttp(nil, cfg)
+
+	client := http.Client{Transport: &http.Transport{
+		Dial: func(string, string) (net.Conn, error) {
+			return net.Dial("unix", cfg.IntrospectionAdminSocket)
+		},
+	}}
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("http://localhost/debug/pprof/goroutine?debug=1")
+		if err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine profile") {
+		t.Error("Expected a goroutine profile, got", resp.StatusCode, string(body))
+	}
+}
+
+// writeCert creates a certificate, signed by parent or self-signed if parent is
+// nil, and writes it and its key into dir
+func writeCert(t *testing.T, dir, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, tls.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	ioutil.WriteFile(filepath.Join(dir, name+".pem"), certPEM, 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+"-key.pem"), keyPEM, 0600)
+	pair, _ := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, key, pair
+}
+
+func TestIntrospectionListenerClientCertificates(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "introspection")
+	defer os.RemoveAll(dir)
+	ca, caKey, _ := writeCert(t, dir, "ca", true, nil, nil)
+	writeCert(t, dir, "server", false, ca, caKey)
+	_, _, clientPair := writeCert(t, dir, "client", false, ca, caKey)
+
+	cfg := &config.Config{
+		IntrospectionAddress:      "127.0.0.1",
+		IntrospectionTLSCertFile:  filepath.Join(dir, "server.pem"),
+		IntrospectionTLSKeyFile:   filepath.Join(dir, "server-key.pem"),
+		IntrospectionClientCAFile: filepath.Join(dir, "ca.pem"),
+		IntrospectionAuthToken:    "secret",
+	}
+	listener, err := introspectionListener(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Handler: newIntrospectionHandler(cfg, readOnlyHandler{okHandler})}
+	go server.Serve(listener)
+	defer listener.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(certs []tls.Certificate, token string) int {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs},
+		}}
+		req, _ := http.NewRequest("GET", "https://"+listener.Addr().String()+"/v1/tasks", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get([]tls.Certificate{clientPair}, ""); status != statusOK {
+		t.Error("Expected a client certificate to be accepted, got", status)
+	}
+	if status := get(nil, "secret"); status != statusOK {
+		t.Error("Expected the token to be accepted without a certificate, got", status)
+	}
+	if status := get(nil, ""); status != statusUnauthorized {
+		t.Error("Expected a request with neither to be refused, got", status)
+	}
+}
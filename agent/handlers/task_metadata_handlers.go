@@ -0,0 +1,208 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/taskcredentials"
+)
+
+const taskMetadataPathPrefix = "/v1/metadata/"
+const credentialsPathPrefix = "/v1/credentials/"
+
+// credentialsGetter is the part of the DockerTaskEngine which holds task role
+// credentials
+type credentialsGetter interface {
+	TaskCredentials(credentialsId string) (*taskcredentials.TaskCredentials, bool)
+}
+
+// TaskMetadataRequestHandlerMaker creates the handler for the task metadata
+// server. Requests are made to engine.TaskMetadataPath, and identify their
+// task by its secret metadata token so that a container can only see its own
+// task. Unknown tokens are indistinguishable from unknown paths.
+func TaskMetadataRequestHandlerMaker(taskEngine engine.TaskEngine, cfg *config.Config) func(http.ResponseWriter, *http.Request) {
+	dockerTaskEngine, ok := taskEngine.(*engine.DockerTaskEngine)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return taskMetadataHandler(dockerTaskEngine.State(), cfg.Cluster)
+}
+
+func taskMetadataHandler(state *dockerstate.DockerTaskEngineState, cluster string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, taskMetadataPathPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		token, containerName := r.URL.Path[len(taskMetadataPathPrefix):], ""
+		if slash := strings.Index(token, "/"); slash >= 0 {
+			token, containerName = token[:slash], token[slash+1:]
+		}
+
+		task, ok := taskByMetadataToken(state, token)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		containerMap, _ := state.ContainerMapByArn(task.Arn)
+		resp := NewTaskMetadataResponse(task, containerMap, cluster)
+		if containerName != "" {
+			if _, ok := task.ContainerByName(containerName); !ok {
+				http.NotFound(w, r)
+				return
+			}
+			resp.ContainerName = containerName
+		}
+
+		responseJSON, _ := json.Marshal(resp)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
+
+// taskByMetadataToken finds the task with the given metadata token
+func taskByMetadataToken(state *dockerstate.DockerTaskEngineState, token string) (*api.Task, bool) {
+	if token == "" {
+		return nil, false
+	}
+	for _, task := range state.AllTasks() {
+		if task.MetadataToken == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(task.MetadataToken), []byte(token)) == 1 {
+			return task, true
+		}
+	}
+	return nil, false
+}
+
+// NewTaskMetadataResponse describes a task and its containers for the task
+// metadata server
+func NewTaskMetadataResponse(task *api.Task, containerMap map[string]*api.DockerContainer, cluster string) *TaskMetadataResponse {
+	containers := []ContainerMetadataResponse{}
+	for _, container := range task.Containers {
+		if container.IsInternal {
+			continue
+		}
+		resp := ContainerMetadataResponse{
+			Name:              container.Name,
+			Image:             container.Image,
+			DesiredStatus:     container.DesiredStatus.String(),
+			KnownStatus:       container.KnownStatus.String(),
+			KnownExitCode:     container.KnownExitCode,
+			KnownPortBindings: container.KnownPortBindings,
+		}
+		if dockerContainer, ok := containerMap[container.Name]; ok {
+			resp.DockerId = dockerContainer.DockerId
+			resp.DockerName = dockerContainer.DockerName
+		}
+		containers = append(containers, resp)
+	}
+
+	return &TaskMetadataResponse{
+		Cluster:       cluster,
+		TaskArn:       task.Arn,
+		Family:        task.Family,
+		Version:       task.Version,
+		DesiredStatus: task.DesiredStatus.BackendStatus(),
+		KnownStatus:   task.KnownStatus.BackendStatus(),
+		Containers:    containers,
+	}
+}
+
+// CredentialsRequestHandlerMaker creates the handler which serves a task's
+// role credentials to its containers. Requests are made to
+// engine.CredentialsPath, and identify the credentials by the secret id given
+// to the task's containers.
+func CredentialsRequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	dockerTaskEngine, ok := taskEngine.(*engine.DockerTaskEngine)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return credentialsHandler(dockerTaskEngine)
+}
+
+func credentialsHandler(getter credentialsGetter) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, credentialsPathPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		credentialsId := r.URL.Path[len(credentialsPathPrefix):]
+		credentials, ok := getter.TaskCredentials(credentialsId)
+		if credentialsId == "" || !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		responseJSON, _ := json.Marshal(&CredentialsResponse{
+			RoleArn:         credentials.RoleArn,
+			AccessKeyId:     credentials.AccessKeyId,
+			SecretAccessKey: credentials.SecretAccessKey,
+			Token:           credentials.Token,
+			Expiration:      credentials.Expiration.UTC().Format(time.RFC3339),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
+
+// ServeTaskMetadataHttp serves task metadata and task role credentials, each
+// if enabled, to containers on config.TASK_METADATA_PORT at
+// cfg.TaskMetadataAddress
+func ServeTaskMetadataHttp(taskEngine engine.TaskEngine, cfg *config.Config) {
+	server := taskMetadataServer(taskEngine, cfg)
+	serveForever(server, func() (net.Listener, error) {
+		return net.Listen("tcp", server.Addr)
+	})
+}
+
+// taskMetadataServer returns the server ServeTaskMetadataHttp runs. It
+// listens only at the address containers are given, rather than on every
+// interface, as it serves credentials.
+func taskMetadataServer(taskEngine engine.TaskEngine, cfg *config.Config) *http.Server {
+	// Requests are not logged as the introspection api's are; their paths
+	// contain secrets
+	serverMux := http.NewServeMux()
+	if cfg.TaskMetadataEnabled {
+		serverMux.HandleFunc(taskMetadataPathPrefix, TaskMetadataRequestHandlerMaker(taskEngine, cfg))
+	}
+	if cfg.TaskIAMRoleEnabled {
+		serverMux.HandleFunc(credentialsPathPrefix, CredentialsRequestHandlerMaker(taskEngine))
+	}
+
+	return &http.Server{
+		Addr:         net.JoinHostPort(cfg.TaskMetadataAddress, strconv.Itoa(config.TASK_METADATA_PORT)),
+		Handler:      serverMux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/taskcredentials"
+)
+
+func taskMetadataTestState() *dockerstate.DockerTaskEngineState {
+	var tasks []*api.Task
+	var dockerContainers []*api.DockerContainer
+	for _, arn := range []string{"task1", "task2"} {
+		containers := []*api.Container{
+			&api.Container{Name: "web", KnownStatus: api.ContainerRunning, KnownPortBindings: []api.PortBinding{{ContainerPort: 80, HostPort: 32768}}},
+			&api.Container{Name: "proxy"},
+		}
+		tasks = append(tasks, &api.Task{Arn: arn, Family: "fam", Version: "2", Containers: containers, MetadataToken: "token-" + arn})
+		dockerContainers = append(dockerContainers, &api.DockerContainer{DockerId: "docker-" + arn, DockerName: "web", Container: containers[0]})
+	}
+	tasks = append(tasks, &api.Task{Arn: "legacy", Containers: []*api.Container{}})
+	return newTestState(tasks, dockerContainers...)
+}
+
+func getTaskMetadata(path string) *httptest.ResponseRecorder {
+	return testRequest(taskMetadataHandler(taskMetadataTestState(), TestClusterArn), "GET", path)
+}
+
+func TestTaskMetadataHandler(t *testing.T) {
+	w := getTaskMetadata("/v1/metadata/token-task2/web")
+	if w.Code != statusOK {
+		t.Fatal("Unexpected status", w.Code)
+	}
+	var resp TaskMetadataResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.TaskArn != "task2" || resp.Cluster != TestClusterArn || resp.ContainerName != "web" {
+		t.Error("Unexpected task metadata", resp)
+	}
+	if len(resp.Containers) != 2 {
+		t.Fatal("Expected both containers in the task", resp.Containers)
+	}
+	web := resp.Containers[0]
+	if web.DockerId != "docker-task2" || web.KnownStatus != "RUNNING" || len(web.KnownPortBindings) != 1 || web.KnownPortBindings[0].HostPort != 32768 {
+		t.Error("Unexpected container metadata", web)
+	}
+	if resp.Containers[1].Name != "proxy" || resp.Containers[1].DockerId != "" {
+		t.Error("Unexpected sibling metadata", resp.Containers[1])
+	}
+}
+
+func TestTaskMetadataHandlerUnknownCaller(t *testing.T) {
+	for _, path := range []string{
+		"/v1/metadata/",
+		"/v1/metadata//web",
+		"/v1/metadata/token-task3/web",
+		"/v1/metadata/token-task1/db",
+		"/v1/tasks",
+	} {
+		if w := getTaskMetadata(path); w.Code != 404 {
+			t.Error("Expected not found for", path, w.Code)
+		}
+	}
+}
+
+type fakeCredentialsGetter map[string]*taskcredentials.TaskCredentials
+
+func (getter fakeCredentialsGetter) TaskCredentials(id string) (*taskcredentials.TaskCredentials, bool) {
+	credentials, ok := getter[id]
+	return credentials, ok
+}
+
+func TestCredentialsHandler(t *testing.T) {
+	handler := credentialsHandler(fakeCredentialsGetter{
+		"id1": &taskcredentials.TaskCredentials{
+			RoleArn:         "arn:role",
+			AccessKeyId:     "AKID",
+			SecretAccessKey: "secret",
+			Token:           "token",
+			Expiration:      time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC),
+		},
+	})
+
+	w := testRequest(handler, "GET", "/v1/credentials/id1")
+	var resp CredentialsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.AccessKeyId != "AKID" || resp.Token != "token" || resp.Expiration != "2015-03-01T12:00:00Z" {
+		t.Error("Unexpected credentials", resp)
+	}
+
+	for _, path := range []string{"/v1/credentials/", "/v1/credentials/id2"} {
+		if w := testRequest(handler, "GET", path); w.Code != 404 {
+			t.Error("Expected not found for", path, w.Code)
+		}
+	}
+}
+
+func TestTaskMetadataServerAddress(t *testing.T) {
+	server := taskMetadataServer(nil, &config.Config{TaskMetadataAddress: "172.17.42.1"})
+	if server.Addr != "172.17.42.1:51679" {
+		t.Error("Expected the server to listen only at the task metadata address, got", server.Addr)
+	}
+}
@@ -13,10 +13,21 @@
 
 package handlers
 
+import (
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
 type MetadataResponse struct {
 	Cluster              string
 	ContainerInstanceArn *string
 	Version              string
+	Attributes           map[string]string `json:",omitempty"`
+	// StateRecovery is set if the agent found its state file corrupt when
+	// it started, and recovered its state from a snapshot
+	StateRecovery *statemanager.Recovery `json:",omitempty"`
 }
 
 type TaskResponse struct {
@@ -37,3 +48,93 @@ type ContainerResponse struct {
 	DockerName string
 	Name       string
 }
+
+// TaskMetadataResponse is the metadata a container may read about its own
+// task
+type TaskMetadataResponse struct {
+	Cluster       string
+	TaskArn       string
+	Family        string
+	Version       string
+	DesiredStatus string `json:",omitempty"`
+	KnownStatus   string
+	// ContainerName is the name of the container the request was made for
+	ContainerName string `json:",omitempty"`
+	Containers    []ContainerMetadataResponse
+}
+
+type ContainerMetadataResponse struct {
+	Name              string
+	DockerId          string `json:",omitempty"`
+	DockerName        string `json:",omitempty"`
+	Image             string
+	DesiredStatus     string
+	KnownStatus       string
+	KnownExitCode     *int `json:",omitempty"`
+	KnownPortBindings []api.PortBinding
+}
+
+// CredentialsResponse matches the format in which EC2 instance metadata serves
+// role credentials, so that existing clients can read it
+type CredentialsResponse struct {
+	RoleArn         string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      string
+}
+
+type StatsV1Response struct {
+	Tasks []TaskStatsV1Response
+}
+
+type TaskStatsV1Response struct {
+	Arn        string
+	Family     string
+	Version    string
+	Containers []ContainerStatsV1Response
+}
+
+// ContainerStatsV1Response is the utilization of a container. Current is the
+// latest of the Recent samples, which are newest first, while CPU and Memory
+// aggregate every sample the agent holds. Each is null until enough samples
+// have been collected.
+type ContainerStatsV1Response struct {
+	DockerId   string
+	DockerName string
+	Name       string `json:",omitempty"`
+	Current    *UsageV1Response
+	Recent     []UsageV1Response
+	CPU        *StatsSetV1Response
+	Memory     *StatsSetV1Response
+}
+
+type UsageV1Response struct {
+	CPUUsagePerc      float32
+	MemoryUsageInMegs uint32
+	Timestamp         time.Time
+}
+
+type StatsSetV1Response struct {
+	Min         float64
+	Max         float64
+	Sum         float64
+	SampleCount int64
+	Unit        string
+}
+
+// DrainV1Response describes the progress of draining the instance. Since and
+// Deadline are omitted while it is not draining, and Deadline also when
+// running tasks are left to finish on their own.
+type DrainV1Response struct {
+	Draining      bool
+	Since         *time.Time `json:",omitempty"`
+	Deadline      *time.Time `json:",omitempty"`
+	RunningTasks  int
+	RejectedTasks int
+}
+
+// CleanupV1Response lists the arns of the stopped tasks removed by a cleanup
+type CleanupV1Response struct {
+	RemovedTasks []string
+}
@@ -0,0 +1,53 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+// cleaner is the part of the task engine which removes stopped tasks
+type cleaner interface {
+	CleanupStoppedTasks() []string
+}
+
+// CleanupAdminV1RequestHandlerMaker creates the handler for the admin
+// 'v1/cleanup' API. A POST removes every stopped task and its containers
+// without waiting for them to age out, and lists the tasks removed.
+func CleanupAdminV1RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	c, ok := taskEngine.(cleaner)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return cleanupAdminHandler(c)
+}
+
+func cleanupAdminHandler(c cleaner) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", statusMethodNotAllowed)
+			return
+		}
+		responseJSON, _ := json.Marshal(&CleanupV1Response{RemovedTasks: c.CleanupStoppedTasks()})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+type fakeCleaner struct {
+	stopped []string
+}
+
+func (c *fakeCleaner) CleanupStoppedTasks() []string {
+	removed := c.stopped
+	c.stopped = []string{}
+	return removed
+}
+
+func TestCleanupAdminHandler(t *testing.T) {
+	handler := cleanupAdminHandler(&fakeCleaner{stopped: []string{"arn1", "arn2"}})
+
+	w := testRequest(handler, "POST", "/v1/cleanup")
+	var resp CleanupV1Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || len(resp.RemovedTasks) != 2 {
+		t.Error("Expected both stopped tasks to be removed", w.Code, resp)
+	}
+
+	w = testRequest(handler, "GET", "/v1/cleanup")
+	if w.Code != statusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Error("Expected only POST to be allowed, got", w.Code)
+	}
+}
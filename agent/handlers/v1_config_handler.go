@@ -0,0 +1,32 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+// ConfigAdminV1RequestHandlerMaker creates the handler for the admin
+// 'v1/config' API, which shows the agent's configuration with its secrets
+// redacted
+func ConfigAdminV1RequestHandlerMaker(cfg *config.Config) func(http.ResponseWriter, *http.Request) {
+	responseJSON, _ := json.Marshal(cfg.Redacted())
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+func TestConfigAdminHandler(t *testing.T) {
+	handler := ConfigAdminV1RequestHandlerMaker(&config.Config{
+		Cluster:                "default",
+		IntrospectionAuthToken: "hunter2",
+	})
+
+	w := testRequest(handler, "GET", "/v1/config")
+	body := w.Body.String()
+	if !strings.Contains(body, `"Cluster":"default"`) {
+		t.Error("Expected the configuration, got", body)
+	}
+	if strings.Contains(body, "hunter2") {
+		t.Error("Expected secrets to be redacted, got", body)
+	}
+}
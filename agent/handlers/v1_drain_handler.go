@@ -0,0 +1,108 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+const drainTimeoutQueryField = "timeout"
+
+// drainer is the part of the task engine which drains the instance
+type drainer interface {
+	Drain(timeout time.Duration)
+	Undrain()
+	DrainStatus() engine.DrainStatus
+}
+
+// DrainV1RequestHandlerMaker reports whether the instance is draining and how
+// far through it is
+func DrainV1RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	d, ok := taskEngine.(drainer)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeDrainStatus(w, d)
+	}
+}
+
+// DrainAdminV1RequestHandlerMaker additionally starts draining the instance on
+// POST, until the timeout query parameter or cfg.DrainTimeout, and stops
+// draining it on DELETE
+func DrainAdminV1RequestHandlerMaker(taskEngine engine.TaskEngine, cfg *config.Config) func(http.ResponseWriter, *http.Request) {
+	d, ok := taskEngine.(drainer)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return drainAdminHandler(d, cfg.DrainTimeout)
+}
+
+func drainAdminHandler(d drainer, defaultTimeout time.Duration) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD":
+		case "POST":
+			timeout := defaultTimeout
+			if timeoutValue, ok := valueFromRequest(r, drainTimeoutQueryField); ok {
+				var err error
+				timeout, err = time.ParseDuration(timeoutValue)
+				if err != nil || timeout < 0 {
+					log.Info("Invalid drain timeout", "timeout", timeoutValue, "err", err)
+					w.WriteHeader(statusBadRequest)
+					return
+				}
+			}
+			d.Drain(timeout)
+		case "DELETE":
+			d.Undrain()
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST, DELETE")
+			http.Error(w, "Method not allowed", statusMethodNotAllowed)
+			return
+		}
+		writeDrainStatus(w, d)
+	}
+}
+
+func writeDrainStatus(w http.ResponseWriter, d drainer) {
+	responseJSON, _ := json.Marshal(NewDrainV1Response(d.DrainStatus()))
+	w.Write(responseJSON)
+}
+
+func NewDrainV1Response(status engine.DrainStatus) *DrainV1Response {
+	resp := &DrainV1Response{
+		Draining:      status.Draining,
+		RunningTasks:  status.RunningTasks,
+		RejectedTasks: status.RejectedTasks,
+	}
+	if !status.Since.IsZero() {
+		resp.Since = &status.Since
+	}
+	if !status.Deadline.IsZero() {
+		resp.Deadline = &status.Deadline
+	}
+	return resp
+}
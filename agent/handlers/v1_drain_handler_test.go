@@ -0,0 +1,97 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+type fakeDrainer struct {
+	status  engine.DrainStatus
+	timeout time.Duration
+}
+
+func (d *fakeDrainer) Drain(timeout time.Duration) {
+	d.status.Draining = true
+	d.timeout = timeout
+}
+
+func (d *fakeDrainer) Undrain() {
+	d.status = engine.DrainStatus{}
+}
+
+func (d *fakeDrainer) DrainStatus() engine.DrainStatus {
+	return d.status
+}
+
+func drainResponse(w *httptest.ResponseRecorder) DrainV1Response {
+	var resp DrainV1Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp
+}
+
+func TestDrainAdminHandler(t *testing.T) {
+	d := &fakeDrainer{}
+	handler := drainAdminHandler(d, time.Hour)
+
+	w := testRequest(handler, "POST", "/v1/drain")
+	resp := drainResponse(w)
+	if w.Code != http.StatusOK || !resp.Draining {
+		t.Error("Expected draining to start", w.Code, resp)
+	}
+	if d.timeout != time.Hour {
+		t.Error("Expected the configured drain timeout by default, got", d.timeout)
+	}
+
+	testRequest(handler, "POST", "/v1/drain?timeout=5m")
+	if d.timeout != 5*time.Minute {
+		t.Error("Expected the requested drain timeout, got", d.timeout)
+	}
+
+	w = testRequest(handler, "POST", "/v1/drain?timeout=soon")
+	if w.Code != statusBadRequest {
+		t.Error("Expected invalid timeouts to be rejected, got", w.Code)
+	}
+
+	w = testRequest(handler, "DELETE", "/v1/drain")
+	resp = drainResponse(w)
+	if w.Code != http.StatusOK || resp.Draining {
+		t.Error("Expected draining to stop", w.Code, resp)
+	}
+
+	w = testRequest(handler, "PUT", "/v1/drain")
+	if w.Code != statusMethodNotAllowed {
+		t.Error("Expected PUT to be refused, got", w.Code)
+	}
+}
+
+func TestNewDrainV1Response(t *testing.T) {
+	since := time.Date(2017, 3, 4, 5, 6, 7, 0, time.UTC)
+	resp := NewDrainV1Response(engine.DrainStatus{Draining: true, Since: since, RunningTasks: 2, RejectedTasks: 1})
+	if resp.Since == nil || !resp.Since.Equal(since) {
+		t.Error("Expected when draining began", resp.Since)
+	}
+	if resp.Deadline != nil {
+		t.Error("Expected no deadline", resp.Deadline)
+	}
+	if resp.RunningTasks != 2 || resp.RejectedTasks != 1 {
+		t.Error("Unexpected task counts", resp)
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+)
+
+const statusGone = 410
+
+// lastEventIdHeader is sent by reconnecting EventSource clients with the id
+// of the last event they received
+const lastEventIdHeader = "Last-Event-ID"
+
+const (
+	// eventsKeepAliveInterval is how often a comment is sent on an idle
+	// stream, so that clients and proxies do not time it out and so that
+	// disconnected clients are noticed
+	eventsKeepAliveInterval = 15 * time.Second
+	// eventsWriteTimeout bounds each write to a client. Clients which cannot
+	// keep up are disconnected rather than allowed to hold events back.
+	eventsWriteTimeout = 10 * time.Second
+)
+
+// EventsV1RequestHandlerMaker creates the handler for the 'v1/events' API. It
+// streams each state change the task engine emits as a server-sent event
+// whose id is the change's sequence number. 'since' (or the Last-Event-ID
+// header) resumes after the given sequence number, and 'taskarn' and 'family'
+// limit the stream to a single task or task definition family.
+func EventsV1RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	dockerTaskEngine, ok := taskEngine.(*engine.DockerTaskEngine)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return eventsHandler(dockerTaskEngine.StateChanges())
+}
+
+func eventsHandler(feed *eventstream.Feed) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sinceValue, sinceExists := valueFromRequest(r, sinceQueryField)
+		if !sinceExists {
+			sinceValue = r.Header.Get(lastEventIdHeader)
+		}
+		var since uint64
+		if sinceValue != "" {
+			var err error
+			since, err = strconv.ParseUint(sinceValue, 10, 64)
+			if err != nil {
+				http.Error(w, sinceQueryField+" must be a sequence number", statusBadRequest)
+				return
+			}
+		}
+		taskArn, _ := valueFromRequest(r, taskArnQueryField)
+		family, _ := valueFromRequest(r, familyQueryField)
+
+		hijacker, ok := w.(http.Hijacker)
+		if !ok {
+			w.WriteHeader(statusInternalServerError)
+			return
+		}
+
+		sub, err := feed.Subscribe(since, eventstream.Filter{TaskArn: taskArn, Family: family})
+		if err != nil {
+			http.Error(w, "Events since "+sinceValue+" are no longer available", statusGone)
+			return
+		}
+		defer sub.Close()
+
+		// The introspection server's write timeout would end the stream, so
+		// take over the connection and manage deadlines per write instead
+		conn, buf, err := hijacker.Hijack()
+		if err != nil {
+			log.Warn("Unable to stream events", "err", err)
+			return
+		}
+		defer conn.Close()
+
+		write := func(format string, args ...interface{}) error {
+			conn.SetWriteDeadline(time.Now().Add(eventsWriteTimeout))
+			fmt.Fprintf(buf, format, args...)
+			return buf.Flush()
+		}
+		err = write("HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nCache-Control: no-cache\r\nConnection: close\r\n\r\n")
+		if err != nil {
+			return
+		}
+		streamEvents(sub, write)
+	}
+}
+
+// streamEvents writes events from sub until it ends or a write fails
+func streamEvents(sub *eventstream.Subscription, write func(string, ...interface{}) error) {
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				if sub.Dropped() {
+					write(": disconnected for falling behind\n\n")
+				}
+				return
+			}
+			data, err := json.Marshal(&event)
+			if err != nil {
+				log.Warn("Unable to encode event", "err", err)
+				continue
+			}
+			if write("id: %d\ndata: %s\n\n", event.Seq, data) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if write(": keep-alive\n\n") != nil {
+				return
+			}
+		}
+	}
+}
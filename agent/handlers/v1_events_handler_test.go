@@ -0,0 +1,139 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/eventstream"
+)
+
+func stateChange(taskArn, family string) api.ContainerStateChange {
+	return api.ContainerStateChange{
+		TaskArn:       taskArn,
+		ContainerName: "c",
+		Status:        api.ContainerRunning,
+		Task:          &api.Task{Arn: taskArn, Family: family},
+	}
+}
+
+func openEvents(t *testing.T, server *httptest.Server, query string, header http.Header) *http.Response {
+	req, _ := http.NewRequest("GET", server.URL+"/v1/events?"+query, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// nextEvent reads up to the next event in the stream, skipping comments
+func nextEvent(t *testing.T, reader *bufio.Reader) (string, eventstream.Event) {
+	var id string
+	var event eventstream.Event
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal("Stream ended", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+				t.Fatal(err)
+			}
+		case line == "" && id != "":
+			return id, event
+		}
+	}
+}
+
+func TestEventsHandlerStreams(t *testing.T) {
+	feed := eventstream.NewFeed(10)
+	server := httptest.NewServer(http.HandlerFunc(eventsHandler(feed)))
+	defer server.Close()
+
+	resp := openEvents(t, server, "family=f1", nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != statusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal("Unexpected response", resp.StatusCode, resp.Header)
+	}
+
+	feed.Publish(stateChange("t2", "f2"))
+	feed.Publish(stateChange("t1", "f1"))
+	reader := bufio.NewReader(resp.Body)
+	id, event := nextEvent(t, reader)
+	if id != "2" || event.Seq != 2 || event.TaskArn != "t1" || event.Status != "RUNNING" {
+		t.Error("Unexpected event", id, event)
+	}
+}
+
+func TestEventsHandlerResumes(t *testing.T) {
+	feed := eventstream.NewFeed(10)
+	server := httptest.NewServer(http.HandlerFunc(eventsHandler(feed)))
+	defer server.Close()
+	for _, arn := range []string{"t1", "t2", "t1", "t1"} {
+		feed.Publish(stateChange(arn, "f"))
+	}
+
+	resp := openEvents(t, server, "since=1&taskarn=t1", nil)
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if id, _ := nextEvent(t, reader); id != "3" {
+		t.Error("Expected to resume after 1 with the next event for t1, got", id)
+	}
+	if id, _ := nextEvent(t, reader); id != "4" {
+		t.Error("Expected event 4, got", id)
+	}
+
+	header := http.Header{}
+	header.Set(lastEventIdHeader, "3")
+	resp = openEvents(t, server, "", header)
+	defer resp.Body.Close()
+	if id, _ := nextEvent(t, bufio.NewReader(resp.Body)); id != "4" {
+		t.Error("Expected to resume from the Last-Event-ID header, got", id)
+	}
+}
+
+func TestEventsHandlerErrors(t *testing.T) {
+	feed := eventstream.NewFeed(1)
+	server := httptest.NewServer(http.HandlerFunc(eventsHandler(feed)))
+	defer server.Close()
+	feed.Publish(stateChange("t1", "f"))
+	feed.Publish(stateChange("t1", "f"))
+	feed.Publish(stateChange("t1", "f"))
+
+	cases := map[string]int{
+		"since=abc": statusBadRequest,
+		"since=1":   statusGone,
+		"since=10":  statusGone,
+	}
+	for query, status := range cases {
+		resp := openEvents(t, server, query, nil)
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Expected %d for %q, got %d", status, query, resp.StatusCode)
+		}
+	}
+}
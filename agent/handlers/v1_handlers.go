@@ -16,8 +16,9 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
-	"strconv"
+	"net/http/pprof"
 	"sync"
 	"time"
 
@@ -26,6 +27,9 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 	"github.com/aws/amazon-ecs-agent/agent/version"
 )
@@ -37,6 +41,10 @@ const statusNotImplemented = 501
 const statusOK = 200
 const statusInternalServerError = 500
 
+// profilingWriteTimeout bounds how long CPU profiles and traces may be
+// collected for when profiling is enabled on the admin socket
+const profilingWriteTimeout = 2 * time.Minute
+
 const dockerIdQueryField = "dockerid"
 const taskArnQueryField = "taskarn"
 
@@ -49,6 +57,8 @@ func MetadataV1RequestHandlerMaker(containerInstanceArn *string, cfg *config.Con
 		Cluster:              cfg.Cluster,
 		ContainerInstanceArn: containerInstanceArn,
 		Version:              version.String(),
+		Attributes:           cfg.InstanceAttributes,
+		StateRecovery:        statemanager.LastRecovery(),
 	}
 	responseJSON, _ := json.Marshal(resp)
 
@@ -158,12 +168,64 @@ func TasksV1RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.Response
 	}
 }
 
-func ServeHttp(containerInstanceArn *string, taskEngine engine.TaskEngine, cfg *config.Config) {
+// ServeHttp serves the read-only introspection API where it is configured to
+// listen
+func ServeHttp(containerInstanceArn *string, taskEngine engine.TaskEngine, statsEngine stats.Engine, cfg *config.Config) {
 	serverFunctions := map[string]func(w http.ResponseWriter, r *http.Request){
 		"/v1/metadata": MetadataV1RequestHandlerMaker(containerInstanceArn, cfg),
 		"/v1/tasks":    TasksV1RequestHandlerMaker(taskEngine),
+		"/v1/logs":     LogsV1RequestHandlerMaker(taskEngine),
+		"/v1/stats":    StatsV1RequestHandlerMaker(taskEngine, statsEngine),
+		"/v1/events":   EventsV1RequestHandlerMaker(taskEngine),
+		"/v1/drain":    DrainV1RequestHandlerMaker(taskEngine),
+		"/v2/tasks":    TasksV2RequestHandlerMaker(taskEngine),
+		"/metrics":     metrics.Handler,
+	}
+
+	server := http.Server{
+		Handler:      newIntrospectionHandler(cfg, readOnlyHandler{newServeMux(serverFunctions)}),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	serveForever(&server, func() (net.Listener, error) {
+		return introspectionListener(cfg)
+	})
+}
+
+// ServeAdminHttp serves the endpoints of the introspection API which change
+// the agent's behaviour, or expose its internals for debugging, on
+// cfg.IntrospectionAdminSocket
+func ServeAdminHttp(taskEngine engine.TaskEngine, cfg *config.Config) {
+	adminFunctions := map[string]func(w http.ResponseWriter, r *http.Request){
+		"/v1/drain":   DrainAdminV1RequestHandlerMaker(taskEngine, cfg),
+		"/v1/cleanup": CleanupAdminV1RequestHandlerMaker(taskEngine),
+		"/v1/config":  ConfigAdminV1RequestHandlerMaker(cfg),
+	}
+	writeTimeout := 5 * time.Second
+	if cfg.ProfilingEnabled {
+		adminFunctions["/debug/pprof/"] = pprof.Index
+		adminFunctions["/debug/pprof/cmdline"] = pprof.Cmdline
+		adminFunctions["/debug/pprof/profile"] = pprof.Profile
+		adminFunctions["/debug/pprof/symbol"] = pprof.Symbol
+		adminFunctions["/debug/pprof/trace"] = pprof.Trace
+		// CPU profiles and traces are collected for as many seconds as
+		// requested, 30 by default, before being written
+		writeTimeout = profilingWriteTimeout
+	}
+
+	server := http.Server{
+		Handler:      newIntrospectionHandler(cfg, newServeMux(adminFunctions)),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: writeTimeout,
 	}
+	serveForever(&server, func() (net.Listener, error) {
+		return adminListener(cfg)
+	})
+}
 
+// newServeMux routes requests to serverFunctions by path, and lists those
+// paths in response to any other request
+func newServeMux(serverFunctions map[string]func(w http.ResponseWriter, r *http.Request)) *http.ServeMux {
 	paths := make([]string, 0, len(serverFunctions))
 	for path := range serverFunctions {
 		paths = append(paths, path)
@@ -181,24 +243,29 @@ func ServeHttp(containerInstanceArn *string, taskEngine engine.TaskEngine, cfg *
 	for key, fn := range serverFunctions {
 		serverMux.HandleFunc(key, fn)
 	}
+	return serverMux
+}
 
-	// Log all requests and then pass through to serverMux
+// newIntrospectionHandler logs all requests and then authenticates them, if
+// configured to, before passing them through to h
+func newIntrospectionHandler(cfg *config.Config, h http.Handler) http.Handler {
 	loggingServeMux := http.NewServeMux()
-	loggingServeMux.Handle("/", LoggingHandler{serverMux})
-
-	server := http.Server{
-		Addr:         ":" + strconv.Itoa(config.AGENT_INTROSPECTION_PORT),
-		Handler:      loggingServeMux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-	}
+	loggingServeMux.Handle("/", LoggingHandler{newAuthHandler(cfg, h)})
+	return loggingServeMux
+}
 
+// serveForever runs the given server on the listener returned by listen,
+// restarting it with backoff whenever it fails
+func serveForever(server *http.Server, listen func() (net.Listener, error)) {
 	for {
 		once := sync.Once{}
 		utils.RetryWithBackoff(utils.NewSimpleBackoff(time.Second, time.Minute, 0.2, 2), func() error {
 			// TODO, make this cancellable and use the passed in context; for
 			// now, not critical if this gets interrupted
-			err := server.ListenAndServe()
+			listener, err := listen()
+			if err == nil {
+				err = server.Serve(listener)
+			}
 			once.Do(func() {
 				log.Error("Error running http api", "err", err)
 			})
@@ -24,12 +24,39 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 )
 
 const TestContainerInstanceArn = "test_container_instance_arn"
 const TestClusterArn = "test_cluster_arn"
 
+// newTestState returns a task engine state holding tasks, with each of
+// dockerContainers added to the task it is a container of
+func newTestState(tasks []*api.Task, dockerContainers ...*api.DockerContainer) *dockerstate.DockerTaskEngineState {
+	state := dockerstate.NewDockerTaskEngineState()
+	for _, task := range tasks {
+		state.AddOrUpdateTask(task)
+		for _, dockerContainer := range dockerContainers {
+			for _, container := range task.Containers {
+				if dockerContainer.Container == container {
+					state.AddContainer(dockerContainer, task)
+				}
+			}
+		}
+	}
+	return state
+}
+
+// testRequest makes a request with method for path to handler, and returns
+// the response it records
+func testRequest(handler func(http.ResponseWriter, *http.Request), method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, "http://localhost"+path, nil)
+	handler(w, req)
+	return w
+}
+
 func TestMetadataHandler(t *testing.T) {
 	metadataHandler := MetadataV1RequestHandlerMaker(utils.Strptr(TestContainerInstanceArn), &config.Config{Cluster: TestClusterArn})
 
@@ -46,6 +73,25 @@ func TestMetadataHandler(t *testing.T) {
 	if *resp.ContainerInstanceArn != TestContainerInstanceArn {
 		t.Error("Metadata returned the wrong cluster arn")
 	}
+	if resp.Attributes != nil {
+		t.Error("Metadata should not include attributes when none are configured")
+	}
+}
+
+func TestMetadataHandlerAttributes(t *testing.T) {
+	cfg := &config.Config{Cluster: TestClusterArn, InstanceAttributes: map[string]string{"stack": "prod"}}
+	metadataHandler := MetadataV1RequestHandlerMaker(utils.Strptr(TestContainerInstanceArn), cfg)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost:"+strconv.Itoa(config.AGENT_INTROSPECTION_PORT), nil)
+	metadataHandler(w, req)
+
+	var resp MetadataResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if resp.Attributes["stack"] != "prod" {
+		t.Error("Metadata returned the wrong attributes", resp.Attributes)
+	}
 }
 
 func getResponseBodyFromLocalHost(url string, t *testing.T) []byte {
@@ -79,7 +125,7 @@ func TestServeHttp(t *testing.T) {
 	dockerTaskEngine, _ := taskEngine.(*engine.DockerTaskEngine)
 	dockerTaskEngine.State().AddOrUpdateTask(&testTask)
 	dockerTaskEngine.State().AddContainer(&api.DockerContainer{DockerId: "docker1", DockerName: "someName", Container: containers[0]}, &testTask)
-	go ServeHttp(utils.Strptr(TestContainerInstanceArn), taskEngine, &config.Config{Cluster: TestClusterArn})
+	go ServeHttp(utils.Strptr(TestContainerInstanceArn), taskEngine, nil, &config.Config{Cluster: TestClusterArn, IntrospectionPort: config.AGENT_INTROSPECTION_PORT})
 
 	body := getResponseBodyFromLocalHost("/v1/metadata", t)
 	var metadata MetadataResponse
@@ -0,0 +1,269 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+)
+
+const statusNotFound = 404
+
+const containerNameQueryField = "container"
+const tailQueryField = "tail"
+const sinceQueryField = "since"
+
+const (
+	// defaultLogLines is the number of lines returned when no tail is given
+	defaultLogLines = 1000
+	// maxLogLines is the most lines that may be requested at once
+	maxLogLines = 10000
+	// logsWriteTimeout bounds each write of logs to a client, in place of
+	// the introspection server's write timeout for the whole response
+	logsWriteTimeout = 10 * time.Second
+)
+
+// containerLogger is the part of the DockerTaskEngine needed to read logs
+type containerLogger interface {
+	ContainerLogs(dockerId string, tail int, timestamps bool, w io.Writer) error
+}
+
+// LogsV1RequestHandlerMaker creates the handler for the 'v1/logs' API. It
+// writes the most recent stdout and stderr of the container named by the
+// 'container' field in the task named by 'taskarn' as plain text. 'tail'
+// limits the number of lines and 'since' (a unix timestamp or RFC 3339 time)
+// drops lines logged before then.
+func LogsV1RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	dockerTaskEngine, ok := taskEngine.(*engine.DockerTaskEngine)
+	if !ok {
+		return func(w http.ResponseWriter, r *http.Request) {
+			// Could not load docker task engine.
+			w.WriteHeader(statusInternalServerError)
+		}
+	}
+	return logsHandler(dockerTaskEngine.State(), dockerTaskEngine)
+}
+
+func logsHandler(state *dockerstate.DockerTaskEngineState, logger containerLogger) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskArn, _ := valueFromRequest(r, taskArnQueryField)
+		containerName, _ := valueFromRequest(r, containerNameQueryField)
+		if taskArn == "" || containerName == "" {
+			http.Error(w, "Both "+taskArnQueryField+" and "+containerNameQueryField+" are required", statusBadRequest)
+			return
+		}
+
+		tail := defaultLogLines
+		if tailValue, exists := valueFromRequest(r, tailQueryField); exists {
+			var err error
+			tail, err = strconv.Atoi(tailValue)
+			if err != nil || tail <= 0 || tail > maxLogLines {
+				http.Error(w, tailQueryField+" must be between 1 and "+strconv.Itoa(maxLogLines), statusBadRequest)
+				return
+			}
+		}
+
+		var since time.Time
+		if sinceValue, exists := valueFromRequest(r, sinceQueryField); exists {
+			var err error
+			since, err = parseSince(sinceValue)
+			if err != nil {
+				http.Error(w, sinceQueryField+" must be a unix timestamp or RFC 3339 time", statusBadRequest)
+				return
+			}
+		}
+
+		// Only containers the agent has created for a task it manages may be
+		// read
+		var dockerId string
+		if containerMap, ok := state.ContainerMapByArn(taskArn); ok {
+			if container, ok := containerMap[containerName]; ok && !container.Container.IsInternal {
+				dockerId = container.DockerId
+			}
+		}
+		if dockerId == "" {
+			log.Info("Refusing logs for unmanaged container", "task", taskArn, "container", containerName)
+			http.Error(w, "No managed container "+containerName+" in task "+taskArn, statusNotFound)
+			return
+		}
+
+		output, err := newLogsResponse(w)
+		if err != nil {
+			log.Warn("Unable to write container logs", "err", err)
+			return
+		}
+		defer output.Close()
+		if since.IsZero() {
+			err = logger.ContainerLogs(dockerId, tail, false, output)
+		} else {
+			filter := &sinceFilterWriter{w: output, since: since}
+			err = logger.ContainerLogs(dockerId, tail, true, filter)
+			if err == nil {
+				err = filter.Flush()
+			}
+		}
+		if err != nil {
+			log.Warn("Error reading container logs", "err", err, "task", taskArn, "container", containerName)
+			if !output.started {
+				output.Error("Unable to read container logs", statusInternalServerError)
+			}
+		}
+	}
+}
+
+func parseSince(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// logsResponse writes the response to a logs request, recording whether
+// anything has been written, after which it is too late to report an error
+// with a status code. Reading a long tail from docker can take longer than
+// the introspection server's write timeout, so where it can, it takes over
+// the connection and sets a deadline for each write instead.
+type logsResponse struct {
+	w       http.ResponseWriter
+	conn    net.Conn
+	buf     *bufio.ReadWriter
+	started bool
+}
+
+func newLogsResponse(w http.ResponseWriter) (*logsResponse, error) {
+	response := &logsResponse{w: w}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		return response, nil
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	response.conn = conn
+	response.buf = buf
+	return response, nil
+}
+
+func (lr *logsResponse) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// Writing even nothing would commit the response's status
+		return 0, nil
+	}
+	if lr.conn == nil {
+		lr.started = true
+		return lr.w.Write(p)
+	}
+	lr.conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+	if !lr.started {
+		lr.writeHeader(statusOK)
+	}
+	n, err := lr.buf.Write(p)
+	if err != nil {
+		return n, err
+	}
+	return n, lr.buf.Flush()
+}
+
+// Error responds with the given error. It must be called before anything else
+// is written.
+func (lr *logsResponse) Error(message string, status int) {
+	if lr.conn == nil {
+		http.Error(lr.w, message, status)
+		return
+	}
+	lr.conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+	lr.writeHeader(status)
+	lr.buf.WriteString(message + "\n")
+	lr.buf.Flush()
+}
+
+// Close ends the response if it took over the connection
+func (lr *logsResponse) Close() {
+	if lr.conn == nil {
+		return
+	}
+	if !lr.started {
+		// There were no logs
+		lr.conn.SetWriteDeadline(time.Now().Add(logsWriteTimeout))
+		lr.writeHeader(statusOK)
+		lr.buf.Flush()
+	}
+	lr.conn.Close()
+}
+
+// writeHeader starts the response on a connection which has been taken over.
+// The end of the logs is marked by closing the connection.
+func (lr *logsResponse) writeHeader(status int) {
+	lr.started = true
+	fmt.Fprintf(lr.buf, "HTTP/1.1 %d %s\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\n\r\n", status, http.StatusText(status))
+}
+
+// sinceFilterWriter takes timestamped log output and writes the lines logged
+// at or after since, without their timestamps. Docker applies the tail limit
+// before this filter, so fewer lines than the tail may be written.
+type sinceFilterWriter struct {
+	w       io.Writer
+	since   time.Time
+	partial []byte
+}
+
+func (fw *sinceFilterWriter) Write(p []byte) (int, error) {
+	fw.partial = append(fw.partial, p...)
+	for {
+		end := bytes.IndexByte(fw.partial, '\n')
+		if end < 0 {
+			break
+		}
+		line := fw.partial[:end+1]
+		fw.partial = fw.partial[end+1:]
+		if err := fw.writeLine(line); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes any final line which did not end in a newline
+func (fw *sinceFilterWriter) Flush() error {
+	if len(fw.partial) == 0 {
+		return nil
+	}
+	line := fw.partial
+	fw.partial = nil
+	return fw.writeLine(line)
+}
+
+func (fw *sinceFilterWriter) writeLine(line []byte) error {
+	space := bytes.IndexByte(line, ' ')
+	if space < 0 {
+		return nil
+	}
+	logged, err := time.Parse(time.RFC3339Nano, string(line[:space]))
+	if err != nil || logged.Before(fw.since) {
+		return nil
+	}
+	_, err = fw.w.Write(line[space+1:])
+	return err
+}
@@ -0,0 +1,170 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+)
+
+type fakeLogger struct {
+	dockerId   string
+	tail       int
+	timestamps bool
+	output     string
+	err        error
+}
+
+func (fl *fakeLogger) ContainerLogs(dockerId string, tail int, timestamps bool, w io.Writer) error {
+	fl.dockerId = dockerId
+	fl.tail = tail
+	fl.timestamps = timestamps
+	io.WriteString(w, fl.output)
+	return fl.err
+}
+
+func logsTestState() *dockerstate.DockerTaskEngineState {
+	containers := []*api.Container{
+		&api.Container{Name: "app"},
+		&api.Container{Name: "internal", IsInternal: true},
+	}
+	return newTestState([]*api.Task{{Arn: "task1", Containers: containers}},
+		&api.DockerContainer{DockerId: "docker1", DockerName: "app", Container: containers[0]},
+		&api.DockerContainer{DockerId: "docker2", DockerName: "internal", Container: containers[1]},
+	)
+}
+
+func TestLogsHandler(t *testing.T) {
+	logger := &fakeLogger{output: "hello\nworld\n"}
+	handler := logsHandler(logsTestState(), logger)
+
+	w := testRequest(handler, "GET", "/v1/logs?taskarn=task1&container=app&tail=5")
+	if w.Code != statusOK {
+		t.Fatal("Unexpected status", w.Code)
+	}
+	if w.Body.String() != "hello\nworld\n" {
+		t.Error("Unexpected logs", w.Body.String())
+	}
+	if logger.dockerId != "docker1" || logger.tail != 5 || logger.timestamps {
+		t.Error("Unexpected logs request", logger)
+	}
+
+	testRequest(handler, "GET", "/v1/logs?taskarn=task1&container=app")
+	if logger.tail != defaultLogLines {
+		t.Error("Expected the default tail", logger.tail)
+	}
+}
+
+func TestLogsHandlerRefusesRequests(t *testing.T) {
+	logger := &fakeLogger{}
+	handler := logsHandler(logsTestState(), logger)
+
+	for query, status := range map[string]int{
+		"taskarn=task1":                          statusBadRequest,
+		"taskarn=task1&container=app&tail=0":     statusBadRequest,
+		"taskarn=task1&container=app&tail=20000": statusBadRequest,
+		"taskarn=task1&container=app&since=soon": statusBadRequest,
+		"taskarn=task2&container=app":            statusNotFound,
+		"taskarn=task1&container=other":          statusNotFound,
+		"taskarn=task1&container=internal":       statusNotFound,
+	} {
+		if w := testRequest(handler, "GET", "/v1/logs?"+query); w.Code != status {
+			t.Error("Unexpected status for", query, w.Code)
+		}
+	}
+	if logger.dockerId != "" {
+		t.Error("Docker should not have been asked for logs", logger.dockerId)
+	}
+}
+
+func TestLogsHandlerDockerError(t *testing.T) {
+	handler := logsHandler(logsTestState(), &fakeLogger{err: errors.New("no docker")})
+	if w := testRequest(handler, "GET", "/v1/logs?taskarn=task1&container=app"); w.Code != statusInternalServerError {
+		t.Error("Unexpected status", w.Code)
+	}
+}
+
+func TestLogsHandlerSince(t *testing.T) {
+	logger := &fakeLogger{output: "2015-03-01T10:00:00.5Z old\n2015-03-01T10:00:02Z new\n2015-03-01T10:00:03Z last"}
+	handler := logsHandler(logsTestState(), logger)
+
+	w := testRequest(handler, "GET", "/v1/logs?taskarn=task1&container=app&since=2015-03-01T10:00:01Z")
+	if !logger.timestamps {
+		t.Error("Expected timestamps to be requested to filter by")
+	}
+	if w.Body.String() != "new\nlast" {
+		t.Error("Unexpected logs", w.Body.String())
+	}
+}
+
+// slowLogger writes each of its lines after a delay, as docker might for a
+// long tail
+type slowLogger struct {
+	lines []string
+	delay time.Duration
+}
+
+func (sl *slowLogger) ContainerLogs(dockerId string, tail int, timestamps bool, w io.Writer) error {
+	for _, line := range sl.lines {
+		time.Sleep(sl.delay)
+		if _, err := io.WriteString(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestLogsHandlerOutlivesServerWriteTimeout(t *testing.T) {
+	logger := &slowLogger{lines: []string{"one\n", "two\n", "three\n"}, delay: 100 * time.Millisecond}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(logsHandler(logsTestState(), logger)))
+	server.Config.WriteTimeout = 150 * time.Millisecond
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/logs?taskarn=task1&container=app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("Expected the whole response", err)
+	}
+	if resp.StatusCode != statusOK || string(body) != "one\ntwo\nthree\n" {
+		t.Error("Unexpected response", resp.StatusCode, string(body))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Error("Unexpected content type", resp.Header.Get("Content-Type"))
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(logsHandler(logsTestState(), &fakeLogger{err: errors.New("no docker")})))
+	defer failing.Close()
+	resp, err = http.Get(failing.URL + "/v1/logs?taskarn=task1&container=app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != statusInternalServerError {
+		t.Error("Unexpected status", resp.StatusCode)
+	}
+}
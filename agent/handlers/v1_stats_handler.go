@@ -0,0 +1,156 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
+)
+
+const statusServiceUnavailable = 503
+
+const samplesQueryField = "samples"
+
+// defaultStatsSamples is the number of recent samples returned for each
+// container when no 'samples' field is given; about a second's worth
+const defaultStatsSamples = 10
+
+// StatsV1RequestHandlerMaker creates the handler for the 'v1/stats' API. It
+// lists the current and recent CPU and memory utilization of every container
+// the stats engine is watching, or only those of the task named by 'taskarn'.
+// 'samples' sets how many recent samples are listed for each container. If
+// metric collection is disabled, statsEngine is nil.
+func StatsV1RequestHandlerMaker(taskEngine engine.TaskEngine, statsEngine stats.Engine) func(http.ResponseWriter, *http.Request) {
+	if statsEngine == nil {
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Metric collection is disabled", statusServiceUnavailable)
+		}
+	}
+	if dockerStatsEngine, ok := statsEngine.(*stats.DockerStatsEngine); ok && !dockerStatsEngine.Initialized() {
+		// It would never report any containers
+		log.Error("Stats engine is not initialized; stats will be unavailable")
+		return func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Metric collection is not initialized", statusServiceUnavailable)
+		}
+	}
+	return statsHandler(taskEngine.TaskState(), statsEngine)
+}
+
+func statsHandler(state dockerstate.TaskEngineState, statsEngine stats.Engine) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		taskArn, taskArnExists := valueFromRequest(r, taskArnQueryField)
+		if taskArnExists && taskArn == "" {
+			http.Error(w, taskArnQueryField+" must not be empty", statusBadRequest)
+			return
+		}
+
+		samples := defaultStatsSamples
+		if samplesValue, exists := valueFromRequest(r, samplesQueryField); exists {
+			var err error
+			samples, err = strconv.Atoi(samplesValue)
+			if err != nil || samples < 0 || samples > stats.ContainerStatsBufferLength {
+				http.Error(w, samplesQueryField+" must be between 0 and "+strconv.Itoa(stats.ContainerStatsBufferLength), statusBadRequest)
+				return
+			}
+		}
+
+		usage := statsEngine.GetTaskUsage(taskArn, samples)
+		if taskArnExists && len(usage) == 0 {
+			http.Error(w, "No stats for task "+taskArn, statusNotFound)
+			return
+		}
+
+		response := &StatsV1Response{Tasks: make([]TaskStatsV1Response, 0, len(usage))}
+		for _, taskUsage := range usage {
+			response.Tasks = append(response.Tasks, NewTaskStatsV1Response(taskUsage, state))
+		}
+		responseJSON, err := json.Marshal(response)
+		if err != nil {
+			w.WriteHeader(statusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(responseJSON)
+	}
+}
+
+// NewTaskStatsV1Response converts a task's utilization to its response.
+// Containers are also named as in the task definition if the task engine
+// knows of them.
+func NewTaskStatsV1Response(taskUsage stats.TaskUsage, state dockerstate.TaskEngineState) TaskStatsV1Response {
+	names := make(map[string]string)
+	if containerMap, ok := state.ContainerMapByArn(taskUsage.TaskArn); ok {
+		for name, container := range containerMap {
+			names[container.DockerId] = name
+		}
+	}
+
+	resp := TaskStatsV1Response{
+		Arn:        taskUsage.TaskArn,
+		Family:     taskUsage.Family,
+		Version:    taskUsage.Version,
+		Containers: make([]ContainerStatsV1Response, 0, len(taskUsage.Containers)),
+	}
+	for _, containerUsage := range taskUsage.Containers {
+		containerResp := ContainerStatsV1Response{
+			DockerId:   containerUsage.DockerID,
+			DockerName: containerUsage.DockerName,
+			Name:       names[containerUsage.DockerID],
+			Recent:     make([]UsageV1Response, 0, len(containerUsage.Samples)),
+			CPU:        newStatsSetV1Response(containerUsage.CPU),
+			Memory:     newStatsSetV1Response(containerUsage.Memory),
+		}
+		for _, sample := range containerUsage.Samples {
+			containerResp.Recent = append(containerResp.Recent, UsageV1Response{
+				CPUUsagePerc:      sample.CPUUsagePerc,
+				MemoryUsageInMegs: sample.MemoryUsageInMegs,
+				Timestamp:         sample.Timestamp,
+			})
+		}
+		if len(containerResp.Recent) > 0 {
+			containerResp.Current = &containerResp.Recent[0]
+		}
+		resp.Containers = append(resp.Containers, containerResp)
+	}
+	return resp
+}
+
+func newStatsSetV1Response(set *ecstcs.CWStatsSet) *StatsSetV1Response {
+	if set == nil {
+		return nil
+	}
+	resp := &StatsSetV1Response{}
+	if set.Min != nil {
+		resp.Min = *set.Min
+	}
+	if set.Max != nil {
+		resp.Max = *set.Max
+	}
+	if set.Sum != nil {
+		resp.Sum = *set.Sum
+	}
+	if set.SampleCount != nil {
+		resp.SampleCount = *set.SampleCount
+	}
+	if set.Unit != nil {
+		resp.Unit = *set.Unit
+	}
+	return resp
+}
@@ -0,0 +1,132 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
+)
+
+type fakeStatsEngine struct {
+	taskArn    string
+	numSamples int
+	usage      []stats.TaskUsage
+}
+
+func (fs *fakeStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error) {
+	return nil, nil, nil
+}
+
+func (fs *fakeStatsEngine) GetTaskUsage(taskArn string, numSamples int) []stats.TaskUsage {
+	fs.taskArn = taskArn
+	fs.numSamples = numSamples
+	if taskArn != "" && taskArn != "task1" {
+		return nil
+	}
+	return fs.usage
+}
+
+func TestStatsHandler(t *testing.T) {
+	max, min, sum, count, unit := 4.0, 1.0, 5.0, int64(2), "Percent"
+	now := time.Now().UTC()
+	statsEngine := &fakeStatsEngine{usage: []stats.TaskUsage{{
+		TaskArn: "task1",
+		Family:  "family",
+		Version: "1",
+		Containers: []stats.ContainerUsage{
+			{
+				DockerID:   "docker1",
+				DockerName: "ecs-family-1-app",
+				Samples: []stats.UsageStats{
+					{CPUUsagePerc: 4, MemoryUsageInMegs: 20, Timestamp: now},
+					{CPUUsagePerc: 1, MemoryUsageInMegs: 10, Timestamp: now.Add(-time.Second)},
+				},
+				CPU: &ecstcs.CWStatsSet{Max: &max, Min: &min, Sum: &sum, SampleCount: &count, Unit: &unit},
+			},
+			{DockerID: "docker3", DockerName: "ecs-family-1-other"},
+		},
+	}}}
+	handler := statsHandler(logsTestState(), statsEngine)
+
+	w := testRequest(handler, "GET", "/v1/stats?taskarn=task1&samples=2")
+	if w.Code != statusOK {
+		t.Fatal("Unexpected status", w.Code)
+	}
+	if statsEngine.taskArn != "task1" || statsEngine.numSamples != 2 {
+		t.Error("Unexpected stats request", statsEngine.taskArn, statsEngine.numSamples)
+	}
+	var resp StatsV1Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Tasks) != 1 || resp.Tasks[0].Arn != "task1" || len(resp.Tasks[0].Containers) != 2 {
+		t.Fatal("Unexpected response", w.Body.String())
+	}
+	app := resp.Tasks[0].Containers[0]
+	if app.Name != "app" || app.DockerId != "docker1" {
+		t.Error("Expected the container to be named from the task engine state", app.Name)
+	}
+	if app.Current == nil || app.Current.CPUUsagePerc != 4 || len(app.Recent) != 2 {
+		t.Error("Unexpected samples", app.Current, app.Recent)
+	}
+	if app.CPU == nil || app.CPU.Max != 4 || app.CPU.SampleCount != 2 || app.CPU.Unit != "Percent" || app.Memory != nil {
+		t.Error("Unexpected aggregates", app.CPU, app.Memory)
+	}
+	other := resp.Tasks[0].Containers[1]
+	if other.Name != "" || other.Current != nil || len(other.Recent) != 0 || other.CPU != nil {
+		t.Error("Expected no usage for a container without samples", other)
+	}
+
+	testRequest(handler, "GET", "/v1/stats")
+	if statsEngine.taskArn != "" || statsEngine.numSamples != defaultStatsSamples {
+		t.Error("Expected all tasks with the default samples", statsEngine.taskArn, statsEngine.numSamples)
+	}
+}
+
+func TestStatsHandlerErrors(t *testing.T) {
+	handler := statsHandler(logsTestState(), &fakeStatsEngine{})
+	cases := map[string]int{
+		"taskarn=":          statusBadRequest,
+		"samples=-1":        statusBadRequest,
+		"samples=many":      statusBadRequest,
+		"samples=1201":      statusBadRequest,
+		"taskarn=task2":     statusNotFound,
+		"taskarn=task1":     statusNotFound,
+		"samples=0":         statusOK,
+		"taskarn&samples=1": statusBadRequest,
+	}
+	for query, status := range cases {
+		if w := testRequest(handler, "GET", "/v1/stats?"+query); w.Code != status {
+			t.Errorf("Expected %d for %q, got %d", status, query, w.Code)
+		}
+	}
+}
+
+func TestStatsHandlerDisabled(t *testing.T) {
+	handler := StatsV1RequestHandlerMaker(nil, nil)
+	if w := testRequest(handler, "GET", "/v1/stats"); w.Code != statusServiceUnavailable {
+		t.Error("Expected unavailable when metric collection is disabled, got", w.Code)
+	}
+}
+
+func TestStatsHandlerUninitialized(t *testing.T) {
+	handler := StatsV1RequestHandlerMaker(nil, stats.NewDockerStatsEngine())
+	if w := testRequest(handler, "GET", "/v1/stats"); w.Code != statusServiceUnavailable {
+		t.Error("Expected unavailable when the stats engine was never initialized, got", w.Code)
+	}
+}
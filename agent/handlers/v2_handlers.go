@@ -0,0 +1,221 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+)
+
+const dockerNameQueryField = "dockername"
+const statusQueryField = "status"
+const familyQueryField = "family"
+
+var validV2StatusFilters = map[string]bool{
+	"PENDING": true,
+	"RUNNING": true,
+	"STOPPED": true,
+}
+
+// NewTaskV2Response describes a task and its containers in full
+func NewTaskV2Response(task *api.Task, containerMap map[string]*api.DockerContainer) *TaskV2Response {
+	volumes := []VolumeV2Response{}
+	for _, volume := range task.Volumes {
+		resp := VolumeV2Response{Name: volume.Name}
+		if volume.Volume != nil {
+			resp.SourcePath = volume.Volume.SourcePath()
+		}
+		volumes = append(volumes, resp)
+	}
+
+	containers := []ContainerV2Response{}
+	for _, container := range task.Containers {
+		if container.IsInternal {
+			continue
+		}
+		containers = append(containers, newContainerV2Response(container, containerMap[container.Name]))
+	}
+
+	return &TaskV2Response{
+		Arn:           task.Arn,
+		Family:        task.Family,
+		Version:       task.Version,
+		DesiredStatus: task.DesiredStatus.BackendStatus(),
+		KnownStatus:   task.KnownStatus.BackendStatus(),
+		SentStatus:    task.SentStatus.BackendStatus(),
+		KnownTime:     timeOrNil(task.KnownTime),
+		StartedAt:     timeOrNil(task.StartedAt),
+		StoppedAt:     timeOrNil(task.StoppedAt),
+		Volumes:       volumes,
+		Containers:    containers,
+	}
+}
+
+func newContainerV2Response(container *api.Container, dockerContainer *api.DockerContainer) ContainerV2Response {
+	resp := ContainerV2Response{
+		Name:          container.Name,
+		Image:         container.Image,
+		ImageId:       container.KnownImageId,
+		DesiredStatus: container.DesiredStatus.String(),
+		KnownStatus:   container.KnownStatus.String(),
+		SentStatus:    container.SentStatus.String(),
+		ExitCode:      container.KnownExitCode,
+		Ports:         []PortV2Response{},
+		Mounts:        []MountV2Response{},
+		VolumesFrom:   []VolumeFromV2Response{},
+		PulledAt:      timeOrNil(container.PulledAt),
+		CreatedAt:     timeOrNil(container.CreatedAt),
+		StartedAt:     timeOrNil(container.StartedAt),
+		FinishedAt:    timeOrNil(container.FinishedAt),
+	}
+	if dockerContainer != nil {
+		resp.DockerId = dockerContainer.DockerId
+		resp.DockerName = dockerContainer.DockerName
+	}
+	if container.ApplyingError != nil {
+		resp.Reason = container.ApplyingError.Error()
+	}
+	for _, binding := range container.KnownPortBindings {
+		resp.Ports = append(resp.Ports, PortV2Response{ContainerPort: binding.ContainerPort, HostPort: binding.HostPort, BindIp: binding.BindIp})
+	}
+	for _, mount := range container.MountPoints {
+		resp.Mounts = append(resp.Mounts, MountV2Response{SourceVolume: mount.SourceVolume, ContainerPath: mount.ContainerPath, ReadOnly: mount.ReadOnly})
+	}
+	for _, volumeFrom := range container.VolumesFrom {
+		resp.VolumesFrom = append(resp.VolumesFrom, VolumeFromV2Response{SourceContainer: volumeFrom.SourceContainer, ReadOnly: volumeFrom.ReadOnly})
+	}
+	return resp
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// TasksV2RequestHandlerMaker creates the handler for the 'v2/tasks' API. It
+// lists all tasks, optionally filtered by 'status' (PENDING, RUNNING or
+// STOPPED) and 'family'. If one of 'taskarn', 'dockerid' or 'dockername' is
+// given it instead returns the single task they identify.
+func TasksV2RequestHandlerMaker(taskEngine engine.TaskEngine) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tasksV2Handler(taskEngine.TaskState(), w, r)
+	}
+}
+
+func tasksV2Handler(state dockerstate.TaskEngineState, w http.ResponseWriter, r *http.Request) {
+	taskArn, taskArnExists := valueFromRequest(r, taskArnQueryField)
+	dockerId, dockerIdExists := valueFromRequest(r, dockerIdQueryField)
+	dockerName, dockerNameExists := valueFromRequest(r, dockerNameQueryField)
+	lookups := 0
+	for _, exists := range []bool{taskArnExists, dockerIdExists, dockerNameExists} {
+		if exists {
+			lookups++
+		}
+	}
+	if lookups > 1 {
+		writeJSONError(w, statusBadRequest, "Expected at most one of "+taskArnQueryField+", "+dockerIdQueryField+" and "+dockerNameQueryField)
+		return
+	}
+
+	if lookups == 1 {
+		var task *api.Task
+		var found bool
+		switch {
+		case taskArnExists:
+			task, found = state.TaskByArn(taskArn)
+		case dockerIdExists:
+			task, found = state.TaskById(dockerId)
+		case dockerNameExists:
+			task, found = taskByDockerName(state, dockerName)
+		}
+		if !found {
+			writeJSONError(w, statusNotFound, "No such task")
+			return
+		}
+		containerMap, _ := state.ContainerMapByArn(task.Arn)
+		writeJSON(w, NewTaskV2Response(task, containerMap))
+		return
+	}
+
+	status, statusExists := valueFromRequest(r, statusQueryField)
+	if statusExists && !validV2StatusFilters[status] {
+		writeJSONError(w, statusBadRequest, "Invalid "+statusQueryField+"; expected PENDING, RUNNING or STOPPED")
+		return
+	}
+	family, familyExists := valueFromRequest(r, familyQueryField)
+
+	tasks := state.AllTasks()
+	sort.Sort(tasksByArn(tasks))
+	resp := &TasksV2Response{Tasks: []*TaskV2Response{}}
+	for _, task := range tasks {
+		if statusExists && task.KnownStatus.BackendStatus() != status {
+			continue
+		}
+		if familyExists && task.Family != family {
+			continue
+		}
+		containerMap, _ := state.ContainerMapByArn(task.Arn)
+		resp.Tasks = append(resp.Tasks, NewTaskV2Response(task, containerMap))
+	}
+	writeJSON(w, resp)
+}
+
+// taskByDockerName finds the task owning the docker container with the given
+// name
+func taskByDockerName(state dockerstate.TaskEngineState, dockerName string) (*api.Task, bool) {
+	for _, task := range state.AllTasks() {
+		containerMap, _ := state.ContainerMapByArn(task.Arn)
+		for _, container := range containerMap {
+			if container.DockerName == dockerName {
+				return task, true
+			}
+		}
+	}
+	return nil, false
+}
+
+type tasksByArn []*api.Task
+
+func (tasks tasksByArn) Len() int           { return len(tasks) }
+func (tasks tasksByArn) Less(i, j int) bool { return tasks[i].Arn < tasks[j].Arn }
+func (tasks tasksByArn) Swap(i, j int)      { tasks[i], tasks[j] = tasks[j], tasks[i] }
+
+type errorV2Response struct {
+	Error string `json:"Error"`
+}
+
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	responseJSON, _ := json.Marshal(&errorV2Response{Error: message})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(responseJSON)
+}
+
+func writeJSON(w http.ResponseWriter, resp interface{}) {
+	responseJSON, err := json.Marshal(resp)
+	if err != nil {
+		writeJSONError(w, statusInternalServerError, "Unable to encode response")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJSON)
+}
@@ -0,0 +1,137 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+)
+
+func v2TestState() *dockerstate.DockerTaskEngineState {
+	exitCode := 1
+	started := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	web := &api.Container{
+		Name:              "web",
+		Image:             "nginx:latest",
+		KnownImageId:      "sha256:abc",
+		DesiredStatus:     api.ContainerStopped,
+		KnownStatus:       api.ContainerStopped,
+		SentStatus:        api.ContainerRunning,
+		KnownExitCode:     &exitCode,
+		ApplyingError:     &api.ApplyingError{Err: "post-start hook failed"},
+		KnownPortBindings: []api.PortBinding{{ContainerPort: 80, HostPort: 32768}},
+		MountPoints:       []api.MountPoint{{SourceVolume: "logs", ContainerPath: "/var/log/nginx"}},
+		VolumesFrom:       []api.VolumeFrom{{SourceContainer: "data", ReadOnly: true}},
+		PulledAt:          started.Add(-time.Minute),
+		CreatedAt:         started.Add(-time.Second),
+		StartedAt:         started,
+		FinishedAt:        started.Add(time.Hour),
+	}
+	data := &api.Container{Name: "data", Image: "busybox"}
+	task := &api.Task{
+		Arn:           "arn:task1",
+		Family:        "web",
+		Version:       "3",
+		DesiredStatus: api.TaskStopped,
+		KnownStatus:   api.TaskStopped,
+		SentStatus:    api.TaskRunning,
+		KnownTime:     started.Add(time.Hour),
+		StartedAt:     started,
+		StoppedAt:     started.Add(time.Hour),
+		Volumes:       []api.TaskVolume{{Name: "logs", Volume: &api.FSHostVolume{FSSourcePath: "/var/log/web"}}},
+		Containers:    []*api.Container{web, data},
+	}
+	other := &api.Task{Arn: "arn:task2", Family: "worker", Version: "1", DesiredStatus: api.TaskRunning, KnownStatus: api.TaskRunning, Containers: []*api.Container{}}
+
+	return newTestState([]*api.Task{task, other}, &api.DockerContainer{DockerId: "docker1", DockerName: "ecs-web-3-web", Container: web})
+}
+
+// tasksV2TestHandler serves v2 task requests from v2TestState
+func tasksV2TestHandler(w http.ResponseWriter, r *http.Request) {
+	tasksV2Handler(v2TestState(), w, r)
+}
+
+// TestTaskV2ResponseSchema guards the v2 schema; if it fails, the change is
+// incompatible for existing clients unless it only adds fields, in which case
+// add them to the test data too.
+func TestTaskV2ResponseSchema(t *testing.T) {
+	w := testRequest(tasksV2TestHandler, "GET", "/v2/tasks?taskarn=arn:task1")
+	if w.Code != statusOK {
+		t.Fatal("Unexpected status", w.Code)
+	}
+	expected, err := ioutil.ReadFile("testdata/v2_task.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expectedJSON, actualJSON interface{}
+	if err := json.Unmarshal(expected, &expectedJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &actualJSON); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expectedJSON, actualJSON) {
+		t.Errorf("Response does not match testdata/v2_task.json:\n%s", w.Body.String())
+	}
+}
+
+func TestTasksV2Filters(t *testing.T) {
+	for query, expectedArns := range map[string][]string{
+		"":                           {"arn:task1", "arn:task2"},
+		"?status=RUNNING":            {"arn:task2"},
+		"?status=STOPPED&family=web": {"arn:task1"},
+		"?family=none":               {},
+	} {
+		w := testRequest(tasksV2TestHandler, "GET", "/v2/tasks"+query)
+		var resp TasksV2Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		arns := []string{}
+		for _, task := range resp.Tasks {
+			arns = append(arns, task.Arn)
+		}
+		if !reflect.DeepEqual(arns, expectedArns) {
+			t.Error("Unexpected tasks for", query, arns)
+		}
+	}
+}
+
+func TestTasksV2Lookups(t *testing.T) {
+	for _, query := range []string{"dockerid=docker1", "dockername=ecs-web-3-web"} {
+		w := testRequest(tasksV2TestHandler, "GET", "/v2/tasks?"+query)
+		var resp TaskV2Response
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != statusOK || resp.Arn != "arn:task1" {
+			t.Error("Unexpected task for", query, w.Code, resp.Arn)
+		}
+	}
+
+	for query, status := range map[string]int{
+		"dockername=missing":             statusNotFound,
+		"taskarn=arn:task3":              statusNotFound,
+		"taskarn=arn:task1&dockerid=abc": statusBadRequest,
+		"status=running":                 statusBadRequest,
+	} {
+		if w := testRequest(tasksV2TestHandler, "GET", "/v2/tasks?"+query); w.Code != status {
+			t.Error("Unexpected status for", query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package handlers
+
+import "time"
+
+// The v2 introspection api's response schema. Field names are fixed by their
+// json tags so that renaming a Go field cannot change the api; fields may be
+// added, but must not be removed or change meaning. See
+// testdata/v2_task.json.
+
+type TasksV2Response struct {
+	Tasks []*TaskV2Response `json:"Tasks"`
+}
+
+type TaskV2Response struct {
+	Arn           string                `json:"Arn"`
+	Family        string                `json:"Family"`
+	Version       string                `json:"Version"`
+	DesiredStatus string                `json:"DesiredStatus"`
+	KnownStatus   string                `json:"KnownStatus"`
+	SentStatus    string                `json:"SentStatus"`
+	KnownTime     *time.Time            `json:"KnownTime,omitempty"`
+	StartedAt     *time.Time            `json:"StartedAt,omitempty"`
+	StoppedAt     *time.Time            `json:"StoppedAt,omitempty"`
+	Volumes       []VolumeV2Response    `json:"Volumes"`
+	Containers    []ContainerV2Response `json:"Containers"`
+}
+
+type VolumeV2Response struct {
+	Name string `json:"Name"`
+	// SourcePath is empty for volumes the agent creates
+	SourcePath string `json:"SourcePath,omitempty"`
+}
+
+type ContainerV2Response struct {
+	Name          string                 `json:"Name"`
+	DockerId      string                 `json:"DockerId,omitempty"`
+	DockerName    string                 `json:"DockerName,omitempty"`
+	Image         string                 `json:"Image"`
+	ImageId       string                 `json:"ImageId,omitempty"`
+	DesiredStatus string                 `json:"DesiredStatus"`
+	KnownStatus   string                 `json:"KnownStatus"`
+	SentStatus    string                 `json:"SentStatus"`
+	ExitCode      *int                   `json:"ExitCode,omitempty"`
+	Reason        string                 `json:"Reason,omitempty"`
+	Ports         []PortV2Response       `json:"Ports"`
+	Mounts        []MountV2Response      `json:"Mounts"`
+	VolumesFrom   []VolumeFromV2Response `json:"VolumesFrom"`
+	PulledAt      *time.Time             `json:"PulledAt,omitempty"`
+	CreatedAt     *time.Time             `json:"CreatedAt,omitempty"`
+	StartedAt     *time.Time             `json:"StartedAt,omitempty"`
+	FinishedAt    *time.Time             `json:"FinishedAt,omitempty"`
+}
+
+type PortV2Response struct {
+	ContainerPort uint16 `json:"ContainerPort"`
+	HostPort      uint16 `json:"HostPort"`
+	BindIp        string `json:"BindIp,omitempty"`
+}
+
+type MountV2Response struct {
+	SourceVolume  string `json:"SourceVolume"`
+	ContainerPath string `json:"ContainerPath"`
+	ReadOnly      bool   `json:"ReadOnly"`
+}
+
+type VolumeFromV2Response struct {
+	SourceContainer string `json:"SourceContainer"`
+	ReadOnly        bool   `json:"ReadOnly"`
+}
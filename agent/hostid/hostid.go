@@ -0,0 +1,73 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package hostid provides a stable identifier for hosts which are not EC2
+// instances and so have no instance ID of their own.
+package hostid
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+var log = logger.ForModule("hostid")
+
+// Filename in the ECS_DATADIR
+const hostIDFile = "ecs_host_id"
+
+// idPrefix distinguishes generated host IDs from EC2 instance IDs
+const idPrefix = "host-"
+
+// LoadOrCreate returns the host ID stored in dataDir, generating and saving a
+// new one if none exists yet. The ID is stable for as long as the data
+// directory is preserved.
+func LoadOrCreate(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, hostIDFile)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if id == "" {
+			return "", errors.New("Host ID file " + path + " is empty; remove it to generate a new ID")
+		}
+		return id, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	id := idPrefix + utils.RandHex()
+	// Write to a temp file in the same directory and rename it into place so
+	// that a crash cannot leave a partially written ID behind
+	tmpfile, err := ioutil.TempFile(dataDir, "tmp_ecs_host_id")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmpfile.WriteString(id)
+	tmpfile.Close()
+	if err != nil {
+		os.Remove(tmpfile.Name())
+		return "", err
+	}
+	if err = os.Rename(tmpfile.Name(), path); err != nil {
+		os.Remove(tmpfile.Name())
+		return "", err
+	}
+	log.Info("Generated new host ID", "id", id)
+	return id, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package hostid
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "hostid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	id, err := LoadOrCreate(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, idPrefix) {
+		t.Error("Unexpected host ID format", id)
+	}
+
+	again, err := LoadOrCreate(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Error("Host ID should be stable across loads", id, again)
+	}
+}
+
+func TestLoadOrCreateErrors(t *testing.T) {
+	dataDir, err := ioutil.TempDir("", "hostid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dataDir)
+
+	if _, err := LoadOrCreate(filepath.Join(dataDir, "missing")); err == nil {
+		t.Error("Expected an error for a missing data directory")
+	}
+
+	ioutil.WriteFile(filepath.Join(dataDir, hostIDFile), []byte("\n"), 0644)
+	if _, err := LoadOrCreate(dataDir); err == nil {
+		t.Error("Expected an error for an empty host ID file")
+	}
+}
@@ -0,0 +1,423 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package metrics is a small registry of counters, gauges and histograms
+// describing the agent itself. The registry can be rendered in the Prometheus
+// text exposition format.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	counterType   = "counter"
+	gaugeType     = "gauge"
+	histogramType = "histogram"
+
+	// ContentType is the content type of the text exposition format
+	ContentType = "text/plain; version=0.0.4"
+)
+
+// DefBuckets are histogram buckets suitable for operations which are expected
+// to take between a few milliseconds and a few seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count buckets, the first having the upper bound
+// start and each following one factor times larger than the last.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Sample is a single value reported by a gauge function, along with the values
+// of its labels in the order they were registered
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// metric is anything the registry is able to render
+type metric interface {
+	describe() *desc
+	writeSamples(w io.Writer)
+}
+
+// desc holds the name, help and labels common to all metrics
+type desc struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (d *desc) describe() *desc {
+	return d
+}
+
+// Registry holds a set of uniquely named metrics
+type Registry struct {
+	lock    sync.RWMutex
+	metrics map[string]metric
+}
+
+// DefaultRegistry is the registry the agent's packages record to and which
+// is served on the introspection endpoint
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register adds m to the registry. Registering the same name twice is a
+// programming error and so panics.
+func (r *Registry) register(m metric) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	name := m.describe().name
+	if _, ok := r.metrics[name]; ok {
+		panic("metrics: duplicate registration of " + name)
+	}
+	r.metrics[name] = m
+}
+
+// NewCounter registers and returns a counter with the given labels
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{newVec(name, help, counterType, labelNames, nil)}
+	r.register(c)
+	return c
+}
+
+// NewGauge registers and returns a gauge with the given labels
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{newVec(name, help, gaugeType, labelNames, nil)}
+	r.register(g)
+	return g
+}
+
+// NewHistogram registers and returns a histogram with the given upper bucket
+// bounds, which must be sorted, and labels
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{newVec(name, help, histogramType, labelNames, buckets)}
+	r.register(h)
+	return h
+}
+
+// RegisterGaugeFunc registers a gauge whose samples are computed by calling fn
+// each time the registry is rendered. Unlike the other metrics, registering a
+// gauge function replaces any existing one with the same name.
+func (r *Registry) RegisterGaugeFunc(name, help string, labelNames []string, fn func() []Sample) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.metrics[name] = &gaugeFunc{
+		desc: desc{name: name, help: help, kind: gaugeType, labelNames: labelNames},
+		fn:   fn,
+	}
+}
+
+// WriteText renders every metric in the registry, sorted by name, in the
+// Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.lock.RLock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, r.metrics[name])
+	}
+	r.lock.RUnlock()
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		d := m.describe()
+		fmt.Fprintf(&buf, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", d.name, d.kind)
+		m.writeSamples(&buf)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// ServeHTTP serves the registry in the Prometheus text exposition format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.WriteText(w)
+}
+
+// NewCounter registers a counter with the default registry
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labelNames...)
+}
+
+// NewGauge registers a gauge with the default registry
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labelNames...)
+}
+
+// NewHistogram registers a histogram with the default registry
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labelNames...)
+}
+
+// RegisterGaugeFunc registers a gauge function with the default registry
+func RegisterGaugeFunc(name, help string, labelNames []string, fn func() []Sample) {
+	DefaultRegistry.RegisterGaugeFunc(name, help, labelNames, fn)
+}
+
+// Handler serves the default registry
+func Handler(w http.ResponseWriter, r *http.Request) {
+	DefaultRegistry.ServeHTTP(w, r)
+}
+
+// series is the state of one combination of label values
+type series struct {
+	labelValues []string
+	value       float64
+	// Only used by histograms
+	bucketCounts []uint64
+	count        uint64
+}
+
+// vec is a metric partitioned by its label values
+type vec struct {
+	desc
+	buckets []float64
+
+	lock   sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, kind string, labelNames []string, buckets []float64) *vec {
+	v := &vec{
+		desc:    desc{name: name, help: help, kind: kind, labelNames: labelNames},
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	if len(labelNames) == 0 {
+		// An unlabelled metric has exactly one series; report it from the
+		// start rather than after it is first changed
+		v.with(nil)
+	}
+	return v
+}
+
+// with returns the series for the given label values, creating it if needed.
+// The caller must hold the lock or otherwise own v.
+func (v *vec) with(labelValues []string) *series {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if v.kind == histogramType {
+			s.bucketCounts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns a snapshot of every series, ordered by label values
+func (v *vec) sorted() []series {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	snapshot := make([]series, 0, len(keys))
+	for _, key := range keys {
+		s := *v.series[key]
+		s.bucketCounts = append([]uint64(nil), s.bucketCounts...)
+		snapshot = append(snapshot, s)
+	}
+	return snapshot
+}
+
+func (v *vec) add(delta float64, labelValues []string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	v.with(labelValues).value += delta
+}
+
+func (v *vec) writeSamples(w io.Writer) {
+	for _, s := range v.sorted() {
+		writeSample(w, v.name, v.labelNames, s.labelValues, "", "", s.value)
+	}
+}
+
+// Counter is a value which only increases
+type Counter struct {
+	*vec
+}
+
+// Inc adds one to the counter for the given label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.add(1, labelValues)
+}
+
+// Add adds delta, which must not be negative, to the counter for the given
+// label values
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.name + " cannot decrease")
+	}
+	c.add(delta, labelValues)
+}
+
+// Gauge is a value which may go up and down
+type Gauge struct {
+	*vec
+}
+
+// Set sets the gauge for the given label values
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.with(labelValues).value = value
+}
+
+// Add adds delta to the gauge for the given label values
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.add(delta, labelValues)
+}
+
+// Inc adds one to the gauge for the given label values
+func (g *Gauge) Inc(labelValues ...string) {
+	g.add(1, labelValues)
+}
+
+// Dec subtracts one from the gauge for the given label values
+func (g *Gauge) Dec(labelValues ...string) {
+	g.add(-1, labelValues)
+}
+
+// Histogram counts observations into buckets and tracks their sum
+type Histogram struct {
+	*vec
+}
+
+// Observe records value for the given label values
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	s := h.with(labelValues)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			s.bucketCounts[i]++
+		}
+	}
+	s.count++
+	s.value += value
+}
+
+// Since records the number of seconds elapsed since start
+func (h *Histogram) Since(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+func (h *Histogram) writeSamples(w io.Writer) {
+	for _, s := range h.sorted() {
+		for i, bound := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", formatFloat(bound), float64(s.bucketCounts[i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labelNames, s.labelValues, "", "", s.value)
+		writeSample(w, h.name+"_count", h.labelNames, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// gaugeFunc is a gauge computed on demand
+type gaugeFunc struct {
+	desc
+	fn func() []Sample
+}
+
+func (g *gaugeFunc) writeSamples(w io.Writer) {
+	samples := g.fn()
+	sort.Sort(byLabelValues(samples))
+	for _, s := range samples {
+		if len(s.LabelValues) != len(g.labelNames) {
+			continue
+		}
+		writeSample(w, g.name, g.labelNames, s.LabelValues, "", "", s.Value)
+	}
+}
+
+type byLabelValues []Sample
+
+func (s byLabelValues) Len() int      { return len(s) }
+func (s byLabelValues) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byLabelValues) Less(i, j int) bool {
+	return strings.Join(s[i].LabelValues, "\xff") < strings.Join(s[j].LabelValues, "\xff")
+}
+
+// writeSample writes one line of the exposition format. If extraName is set,
+// it is added as a final label; histograms use this for their bucket bounds.
+func writeSample(w io.Writer, name string, labelNames, labelValues []string, extraName, extraValue string, value float64) {
+	io.WriteString(w, name)
+	if len(labelNames) > 0 || extraName != "" {
+		pairs := make([]string, 0, len(labelNames)+1)
+		for i, labelName := range labelNames {
+			pairs = append(pairs, labelName+`="`+escapeLabelValue(labelValues[i])+`"`)
+		}
+		if extraName != "" {
+			pairs = append(pairs, extraName+`="`+extraValue+`"`)
+		}
+		io.WriteString(w, "{"+strings.Join(pairs, ",")+"}")
+	}
+	io.WriteString(w, " "+formatFloat(value)+"\n")
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
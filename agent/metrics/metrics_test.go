@@ -0,0 +1,147 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, r *Registry) string {
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCounterAndGauge(t *testing.T) {
+	r := NewRegistry()
+	calls := r.NewCounter("calls_total", "Calls made", "operation")
+	inflight := r.NewGauge("inflight", "In flight\nrequests")
+
+	calls.Inc("stop")
+	calls.Inc("start")
+	calls.Add(2, "start")
+	inflight.Inc()
+	inflight.Inc()
+	inflight.Dec()
+
+	expected := `# HELP calls_total Calls made
+# TYPE calls_total counter
+calls_total{operation="start"} 3
+calls_total{operation="stop"} 1
+# HELP inflight In flight\nrequests
+# TYPE inflight gauge
+inflight 1
+`
+	if output := render(t, r); output != expected {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+}
+
+func TestUnlabelledCounterStartsAtZero(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("failures_total", "Failures")
+	if !strings.Contains(render(t, r), "\nfailures_total 0\n") {
+		t.Error("Expected an unused counter to be reported as 0")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogram("latency_seconds", "Latency", []float64{1, 5}, "op")
+	h.Observe(0.5, "pull")
+	h.Observe(3, "pull")
+	h.Observe(10, "pull")
+
+	expected := `# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="pull",le="1"} 1
+latency_seconds_bucket{op="pull",le="5"} 2
+latency_seconds_bucket{op="pull",le="+Inf"} 3
+latency_seconds_sum{op="pull"} 13.5
+latency_seconds_count{op="pull"} 3
+`
+	if output := render(t, r); output != expected {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+}
+
+func TestGaugeFuncReplaced(t *testing.T) {
+	r := NewRegistry()
+	r.RegisterGaugeFunc("tasks", "Tasks", []string{"status"}, func() []Sample {
+		return []Sample{{[]string{"RUNNING"}, 1}}
+	})
+	r.RegisterGaugeFunc("tasks", "Tasks", []string{"status"}, func() []Sample {
+		return []Sample{{[]string{"STOPPED"}, 4}, {[]string{"PENDING"}, 2}}
+	})
+
+	expected := `# HELP tasks Tasks
+# TYPE tasks gauge
+tasks{status="PENDING"} 2
+tasks{status="STOPPED"} 4
+`
+	if output := render(t, r); output != expected {
+		t.Errorf("Unexpected output:\n%s", output)
+	}
+}
+
+func TestLabelValuesEscaped(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("c", "c", "name").Inc(`a"b\c`)
+	if !strings.Contains(render(t, r), `c{name="a\"b\\c"} 1`) {
+		t.Error("Expected label value to be escaped")
+	}
+}
+
+func TestDuplicateRegistrationPanics(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("c", "c")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected duplicate registration to panic")
+		}
+	}()
+	r.NewGauge("c", "c")
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("c", "c", "operation")
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected missing label values to panic")
+		}
+	}()
+	c.Inc()
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("up", "Up").Set(1)
+
+	recorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://localhost/metrics", nil)
+	r.ServeHTTP(recorder, req)
+	if recorder.Header().Get("Content-Type") != ContentType {
+		t.Error("Unexpected content type", recorder.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(recorder.Body.String(), "\nup 1\n") {
+		t.Error("Unexpected body", recorder.Body.String())
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sighandlers
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/diagnostics"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+// StartDiagnosticHandler writes a diagnostic bundle into the data directory
+// each time the agent receives SIGUSR1
+func StartDiagnosticHandler(cfg *config.Config, taskEngine engine.TaskEngine) {
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGUSR1)
+
+	for sig := range signalChannel {
+		log.Info("Writing diagnostics", "signal", sig.String())
+		bundleDir, err := diagnostics.WriteBundle(cfg, taskEngine)
+		if err != nil {
+			log.Error("Error writing diagnostics", "dir", bundleDir, "err", err)
+			continue
+		}
+		log.Info("Wrote diagnostics", "dir", bundleDir)
+	}
+}
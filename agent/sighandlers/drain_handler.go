@@ -0,0 +1,38 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sighandlers
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Drainer is a task engine which can stop accepting new tasks
+type Drainer interface {
+	Drain(timeout time.Duration)
+}
+
+// StartDrainHandler starts draining the instance, leaving running tasks until
+// timeout to finish, when the agent receives SIGUSR2
+func StartDrainHandler(drainer Drainer, timeout time.Duration) {
+	signalChannel := make(chan os.Signal, 1)
+	signal.Notify(signalChannel, syscall.SIGUSR2)
+
+	for sig := range signalChannel {
+		log.Info("Draining instance", "signal", sig.String())
+		drainer.Drain(timeout)
+	}
+}
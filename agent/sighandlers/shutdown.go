@@ -0,0 +1,181 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sighandlers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+)
+
+// shutdownPollInterval is how often tasks and state changes are checked on
+// while waiting for them before exiting
+const shutdownPollInterval = 100 * time.Millisecond
+
+// TaskStopper is a task engine which can stop all of its tasks
+type TaskStopper interface {
+	Drainer
+	StopAllTasks()
+}
+
+// ShutdownReport records what was and was not flushed before exiting
+type ShutdownReport struct {
+	Policy string
+	// TasksRunning is the number of tasks which had not stopped
+	TasksRunning int
+	// TransitionsSettled is false if containers were still being
+	// transitioned when the engine was disabled
+	TransitionsSettled bool
+	// UnsubmittedEvents are the state changes which had not been submitted
+	UnsubmittedEvents []eventhandler.PendingEvent
+	// StateSaved is false if state could not be saved
+	StateSaved bool
+}
+
+// Flushed returns true if nothing was left unfinished by the shutdown policy
+func (report *ShutdownReport) Flushed() bool {
+	return report.TransitionsSettled && len(report.UnsubmittedEvents) == 0 && report.StateSaved &&
+		(report.Policy != config.ShutdownPolicyStopTasks || report.TasksRunning == 0)
+}
+
+// Shutdown prepares the agent to exit according to the configured shutdown
+// policy, waiting until cfg.ShutdownTimeout for tasks to stop or state
+// changes to be submitted if the policy calls for it, and then saves state
+func Shutdown(cfg *config.Config, saver statemanager.Saver, taskEngine engine.TaskEngine) (*ShutdownReport, error) {
+	report := &ShutdownReport{Policy: cfg.ShutdownPolicy}
+	deadline := time.Now().Add(cfg.ShutdownTimeout)
+	disableTimeout := engineDisableTimeout
+
+	switch cfg.ShutdownPolicy {
+	case config.ShutdownPolicyStopTasks:
+		if stopper, ok := taskEngine.(TaskStopper); ok {
+			// Refuse new tasks so that there is nothing left to stop
+			stopper.Drain(0)
+			stopper.StopAllTasks()
+			waitUntil(deadline, func() bool { return runningTasks(taskEngine) == 0 })
+		} else {
+			log.Warn("Task engine cannot stop tasks; leaving them running")
+		}
+		fallthrough
+	case config.ShutdownPolicyWait:
+		// Waiting for tasks to stop may have used up the whole timeout;
+		// transitions in progress still get the usual time to finish
+		if remaining := deadline.Sub(time.Now()); remaining > disableTimeout {
+			disableTimeout = remaining
+		}
+	case config.ShutdownPolicyLeaveRunning:
+	default:
+		log.Warn("Unknown shutdown policy; leaving tasks running", "policy", cfg.ShutdownPolicy)
+		report.Policy = config.ShutdownPolicyLeaveRunning
+	}
+
+	disableErr := disableEngine(taskEngine, disableTimeout)
+	report.TransitionsSettled = disableErr == nil
+	if report.Policy != config.ShutdownPolicyLeaveRunning {
+		// Transitions which finished while the engine was being disabled
+		// may have queued more state changes
+		waitUntil(deadline, func() bool { return len(eventhandler.PendingEvents()) == 0 })
+	}
+
+	saveErr := saveState(saver)
+	report.StateSaved = saveErr == nil
+	report.TasksRunning = runningTasks(taskEngine)
+	for _, events := range eventhandler.PendingEvents() {
+		report.UnsubmittedEvents = append(report.UnsubmittedEvents, events...)
+	}
+
+	if disableErr != nil || saveErr != nil {
+		return report, utils.NewMultiError(disableErr, saveErr)
+	}
+	return report, nil
+}
+
+// disableEngine stops the engine processing tasks once transitions already in
+// progress finish, or returns an error if they take longer than timeout
+func disableEngine(taskEngine engine.TaskEngine, timeout time.Duration) error {
+	engineDisabled := make(chan error, 2)
+
+	disableTimer := time.AfterFunc(timeout, func() {
+		engineDisabled <- errors.New("Timed out waiting for TaskEngine to settle")
+	})
+
+	go func() {
+		log.Debug("Shutting down task engine")
+		taskEngine.Disable()
+		disableTimer.Stop()
+		engineDisabled <- nil
+	}()
+
+	return <-engineDisabled
+}
+
+// saveState saves state, or returns an error if it cannot within a short
+// timeout
+func saveState(saver statemanager.Saver) error {
+	stateSaved := make(chan error, 2)
+	saveTimer := time.AfterFunc(finalSaveTimeout, func() {
+		stateSaved <- errors.New("Timed out trying to save to disk")
+	})
+	go func() {
+		log.Debug("Saving state before shutting down")
+		stateSaved <- saver.ForceSave()
+		saveTimer.Stop()
+	}()
+
+	return <-stateSaved
+}
+
+// waitUntil polls done until it returns true or deadline passes
+func waitUntil(deadline time.Time, done func() bool) {
+	for !done() && time.Now().Before(deadline) {
+		time.Sleep(shutdownPollInterval)
+	}
+}
+
+func runningTasks(taskEngine engine.TaskEngine) int {
+	tasks, _ := taskEngine.ListTasks()
+	running := 0
+	for _, task := range tasks {
+		if task.KnownStatus < api.TaskStopped {
+			running++
+		}
+	}
+	return running
+}
+
+// logShutdownReport says exactly what was and was not flushed before exiting
+func logShutdownReport(report *ShutdownReport) {
+	ctx := []interface{}{
+		"policy", report.Policy,
+		"tasksRunning", report.TasksRunning,
+		"transitionsSettled", report.TransitionsSettled,
+		"unsubmittedEvents", len(report.UnsubmittedEvents),
+		"stateSaved", report.StateSaved,
+	}
+	if report.Flushed() {
+		log.Info("Everything flushed before exiting", ctx...)
+		return
+	}
+	log.Warn("Not everything was flushed before exiting", ctx...)
+	for _, event := range report.UnsubmittedEvents {
+		log.Warn("Unsubmitted state change", "task", event.TaskArn, "container", event.ContainerName,
+			"status", event.Status, "taskStatus", event.TaskStatus, "containerSent", event.ContainerSent, "taskSent", event.TaskSent)
+	}
+}
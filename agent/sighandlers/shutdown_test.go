@@ -0,0 +1,123 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package sighandlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+// fakeTaskEngine stops its tasks as soon as it is asked to
+type fakeTaskEngine struct {
+	engine.TaskEngine
+	tasks    []*api.Task
+	draining bool
+	disabled bool
+}
+
+func (e *fakeTaskEngine) ListTasks() ([]*api.Task, error) {
+	return e.tasks, nil
+}
+
+func (e *fakeTaskEngine) Disable() {
+	e.disabled = true
+}
+
+func (e *fakeTaskEngine) Drain(timeout time.Duration) {
+	e.draining = true
+}
+
+func (e *fakeTaskEngine) StopAllTasks() {
+	for _, task := range e.tasks {
+		task.KnownStatus = api.TaskStopped
+	}
+}
+
+func TestShutdownLeavesTasksRunning(t *testing.T) {
+	taskEngine := &fakeTaskEngine{tasks: []*api.Task{{Arn: "running", KnownStatus: api.TaskRunning}}}
+	cfg := &config.Config{ShutdownPolicy: config.ShutdownPolicyLeaveRunning, ShutdownTimeout: time.Second}
+
+	report, err := Shutdown(cfg, statemanager.NewNoopStateManager(), taskEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TasksRunning != 1 || taskEngine.draining {
+		t.Error("Expected the task to be left running", report)
+	}
+	if !taskEngine.disabled || !report.StateSaved || !report.Flushed() {
+		t.Error("Expected the engine to be disabled and state saved", report)
+	}
+}
+
+func TestShutdownStopsTasks(t *testing.T) {
+	taskEngine := &fakeTaskEngine{tasks: []*api.Task{{Arn: "running", KnownStatus: api.TaskRunning}}}
+	cfg := &config.Config{ShutdownPolicy: config.ShutdownPolicyStopTasks, ShutdownTimeout: time.Second}
+
+	report, err := Shutdown(cfg, statemanager.NewNoopStateManager(), taskEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !taskEngine.draining {
+		t.Error("Expected new tasks to be refused while shutting down")
+	}
+	if report.TasksRunning != 0 || !report.Flushed() {
+		t.Error("Expected every task to be stopped", report)
+	}
+}
+
+// stuckTaskEngine never stops its tasks, and takes a moment to be disabled
+type stuckTaskEngine struct {
+	fakeTaskEngine
+}
+
+func (e *stuckTaskEngine) StopAllTasks() {}
+
+func (e *stuckTaskEngine) Disable() {
+	time.Sleep(10 * time.Millisecond)
+	e.disabled = true
+}
+
+func TestShutdownTimeoutShorterThanStopWait(t *testing.T) {
+	taskEngine := &stuckTaskEngine{fakeTaskEngine{tasks: []*api.Task{{Arn: "stuck", KnownStatus: api.TaskRunning}}}}
+	cfg := &config.Config{ShutdownPolicy: config.ShutdownPolicyStopTasks, ShutdownTimeout: 50 * time.Millisecond}
+
+	report, _ := Shutdown(cfg, statemanager.NewNoopStateManager(), taskEngine)
+	if !report.TransitionsSettled {
+		t.Error("Expected the engine to be disabled once waiting for tasks used the whole timeout", report)
+	}
+	if report.TasksRunning != 1 || report.Flushed() {
+		t.Error("Expected the stuck task to be reported", report)
+	}
+}
+
+func TestShutdownReportNotFlushed(t *testing.T) {
+	report := &ShutdownReport{
+		Policy:             config.ShutdownPolicyStopTasks,
+		TasksRunning:       1,
+		TransitionsSettled: true,
+		StateSaved:         true,
+	}
+	if report.Flushed() {
+		t.Error("Expected a task left running to count as unflushed when stopping tasks")
+	}
+	report.Policy = config.ShutdownPolicyWait
+	if !report.Flushed() {
+		t.Error("Expected tasks left running to be fine when waiting")
+	}
+}
@@ -11,18 +11,19 @@
 // express or implied. See the License for the specific language governing
 // permissions and limitations under the License.
 
-// sighandlers handle signals and behave appropriately. Currently, the only
-// supported signal is SIGTERM which causes state to be flushed to disk before
-// exiting.
+// sighandlers handle signals and behave appropriately. SIGTERM and SIGINT
+// cause state to be flushed to disk before exiting, SIGUSR1 causes a
+// diagnostic bundle to be written to the data directory, and SIGUSR2 starts
+// draining the instance.
 package sighandlers
 
 import (
-	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
 	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
@@ -32,14 +33,17 @@ import (
 
 var log = logger.ForModule("TerminationHandler")
 
-func StartTerminationHandler(saver statemanager.Saver, taskEngine engine.TaskEngine) {
+// StartTerminationHandler applies the configured shutdown policy, saves state
+// and exits when the agent receives SIGTERM or SIGINT
+func StartTerminationHandler(cfg *config.Config, saver statemanager.Saver, taskEngine engine.TaskEngine) {
 	signalChannel := make(chan os.Signal, 2)
 	signal.Notify(signalChannel, os.Interrupt, syscall.SIGTERM)
 
 	sig := <-signalChannel
 	log.Debug("Received termination signal", "signal", sig.String())
 
-	err := FinalSave(saver, taskEngine)
+	report, err := Shutdown(cfg, saver, taskEngine)
+	logShutdownReport(report)
 	if err != nil {
 		log.Crit("Error saving state before final shutdown", "err", err)
 		// Terminal because it's a sigterm; the user doesn't want it to restart
@@ -56,32 +60,8 @@ const finalSaveTimeout = 3 * time.Second
 // to settle if necessary. If unable to reach a steady-state and save within
 // this short timeout, it returns an error
 func FinalSave(saver statemanager.Saver, taskEngine engine.TaskEngine) error {
-	engineDisabled := make(chan error)
-
-	disableTimer := time.AfterFunc(engineDisableTimeout, func() {
-		engineDisabled <- errors.New("Timed out waiting for TaskEngine to settle")
-	})
-
-	go func() {
-		log.Debug("Shutting down task engine")
-		taskEngine.Disable()
-		disableTimer.Stop()
-		engineDisabled <- nil
-	}()
-
-	disableErr := <-engineDisabled
-
-	stateSaved := make(chan error)
-	saveTimer := time.AfterFunc(finalSaveTimeout, func() {
-		stateSaved <- errors.New("Timed out trying to save to disk")
-	})
-	go func() {
-		log.Debug("Saving state before shutting down")
-		stateSaved <- saver.ForceSave()
-		saveTimer.Stop()
-	}()
-
-	saveErr := <-stateSaved
+	disableErr := disableEngine(taskEngine, engineDisableTimeout)
+	saveErr := saveState(saver)
 
 	if disableErr != nil || saveErr != nil {
 		return utils.NewMultiError(disableErr, saveErr)
@@ -0,0 +1,72 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/eventhandler"
+	"github.com/aws/amazon-ecs-agent/agent/sighandlers/exitcodes"
+	"github.com/aws/amazon-ecs-agent/agent/standalone"
+	log15 "gopkg.in/inconshreveable/log15.v2"
+)
+
+// standaloneStatusFile is the name of the status file in the data directory
+// if no other is configured
+const standaloneStatusFile = "standalone-status.json"
+
+// runStandalone runs the tasks in cfg.StandaloneTaskDir without registering
+// or talking to ACS, TCS or the ECS API. State changes are written to a local
+// status file and are available through the introspection API.
+func runStandalone(cfg *config.Config, log log15.Logger) {
+	log.Info("Running standalone", "taskDir", cfg.StandaloneTaskDir)
+	statusFile := cfg.StandaloneStatusFile
+	if statusFile == "" {
+		statusFile = filepath.Join(cfg.DataDir, standaloneStatusFile)
+	}
+	client, err := standalone.NewStatusClient(statusFile)
+	if err != nil {
+		log.Crit("Unable to read standalone status file", "path", statusFile, "err", err)
+		os.Exit(exitcodes.ExitTerminal)
+	}
+
+	// Standalone agents never register, so there is no cluster, instance or
+	// registration to restore; only tasks
+	var cluster, containerInstanceArn, ec2InstanceID string
+	var registeredAttributes map[string]string
+	taskEngine := engine.NewTaskEngine(cfg)
+	stateManager, err := initializeStateManager(cfg, taskEngine, &cluster, &containerInstanceArn, &ec2InstanceID, &registeredAttributes)
+	if err != nil {
+		log.Crit("Error creating state manager", "err", err)
+		os.Exit(exitcodes.ExitTerminal)
+	}
+	if cfg.Checkpoint {
+		if err := stateManager.Load(); err != nil {
+			log.Crit("Error loading previously saved state", "err", err)
+			os.Exit(exitcodes.ExitTerminal)
+		}
+	}
+
+	startEngine(cfg, taskEngine, stateManager, containerInstanceArn)
+
+	statsEngine := newStatsEngine(taskEngine, &cluster, &containerInstanceArn, log)
+	serveIntrospection(cfg, &containerInstanceArn, taskEngine, statsEngine)
+
+	go eventhandler.HandleEngineEvents(taskEngine, client, stateManager)
+
+	standalone.NewWatcher(cfg.StandaloneTaskDir, taskEngine).Run()
+}
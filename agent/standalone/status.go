@@ -0,0 +1,170 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/utils"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+// stoppedTaskRetention is how long stopped tasks are kept in the status file,
+// matching how long the engine keeps them
+const stoppedTaskRetention = 3 * time.Hour
+
+// stoppedStatus is the status of a stopped task, as ECS would report it
+const stoppedStatus = "STOPPED"
+
+var errStandalone = errors.New("not available to standalone agents")
+
+// TaskStatus is the last state submitted for a task
+type TaskStatus struct {
+	Arn        string
+	File       string `json:",omitempty"`
+	Status     string `json:",omitempty"`
+	Containers map[string]*ContainerStatus
+	UpdatedAt  time.Time
+}
+
+// ContainerStatus is the last state submitted for a container
+type ContainerStatus struct {
+	Status       string
+	ExitCode     *int              `json:",omitempty"`
+	Reason       string            `json:",omitempty"`
+	PortBindings []api.PortBinding `json:",omitempty"`
+}
+
+// StatusClient stands in for the ECS API, writing the state changes it is
+// sent to a local status file, a JSON object of TaskStatus by task arn,
+// instead of submitting them. It never registers.
+type StatusClient struct {
+	path  string
+	tasks map[string]*TaskStatus
+	lock  sync.Mutex
+}
+
+// NewStatusClient returns a StatusClient which writes to path, keeping the
+// statuses already written there
+func NewStatusClient(path string) (*StatusClient, error) {
+	client := &StatusClient{path: path, tasks: make(map[string]*TaskStatus)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return client, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &client.tasks); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (client *StatusClient) CredentialProvider() credentials.AWSCredentialProvider {
+	return nil
+}
+
+func (client *StatusClient) RegisterContainerInstance() (string, error) {
+	return "", errStandalone
+}
+
+func (client *StatusClient) DiscoverPollEndpoint(containerInstanceArn string) (string, error) {
+	return "", errStandalone
+}
+
+func (client *StatusClient) PutContainerInstanceAttributes(containerInstanceArn string, attributes map[string]string) error {
+	return nil
+}
+
+func (client *StatusClient) DeleteContainerInstanceAttributes(containerInstanceArn string, names []string) error {
+	return nil
+}
+
+func (client *StatusClient) SubmitTaskStateChange(change api.ContainerStateChange) utils.RetriableError {
+	return client.update(change.TaskArn, func(task *TaskStatus) {
+		task.Status = change.TaskStatus.BackendStatus()
+	})
+}
+
+func (client *StatusClient) SubmitContainerStateChange(change api.ContainerStateChange) utils.RetriableError {
+	return client.update(change.TaskArn, func(task *TaskStatus) {
+		task.Containers[change.ContainerName] = &ContainerStatus{
+			Status:       change.Status.String(),
+			ExitCode:     change.ExitCode,
+			Reason:       change.Reason,
+			PortBindings: change.PortBindings,
+		}
+	})
+}
+
+// update applies a change to a task's status and rewrites the status file,
+// retriably failing if it cannot be written
+func (client *StatusClient) update(taskArn string, apply func(*TaskStatus)) utils.RetriableError {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	task, ok := client.tasks[taskArn]
+	if !ok {
+		task = &TaskStatus{Arn: taskArn, Containers: make(map[string]*ContainerStatus)}
+		task.File, _, _ = parseArn(taskArn)
+		client.tasks[taskArn] = task
+	}
+	apply(task)
+	task.UpdatedAt = ttime.Now()
+
+	for arn, task := range client.tasks {
+		if task.Status == stoppedStatus && ttime.Since(task.UpdatedAt) > stoppedTaskRetention {
+			delete(client.tasks, arn)
+		}
+	}
+	if err := client.write(); err != nil {
+		log.Error("Unable to write status file", "path", client.path, "err", err)
+		return utils.NewRetriableError(utils.NewRetriable(true), err)
+	}
+	return nil
+}
+
+// write replaces the status file, such that readers never see it partially
+// written
+func (client *StatusClient) write() error {
+	data, err := json.MarshalIndent(client.tasks, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(client.path), filepath.Base(client.path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), client.path)
+}
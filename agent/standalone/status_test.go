@@ -0,0 +1,81 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+)
+
+func TestStatusClient(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "standalone")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.json")
+	arn := arnPrefix + "web/0123456789abcdef/1"
+
+	client, err := NewStatusClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exitCode := 1
+	change := api.ContainerStateChange{
+		TaskArn:       arn,
+		ContainerName: "nginx",
+		Status:        api.ContainerStopped,
+		ExitCode:      &exitCode,
+		TaskStatus:    api.TaskStopped,
+	}
+	if err := client.SubmitContainerStateChange(change); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.SubmitTaskStateChange(change); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tasks map[string]*TaskStatus
+	if err := json.Unmarshal(data, &tasks); err != nil {
+		t.Fatal(err)
+	}
+	task, ok := tasks[arn]
+	if !ok {
+		t.Fatal("Expected the task in the status file", string(data))
+	}
+	if task.File != "web" || task.Status != "STOPPED" {
+		t.Error("Unexpected task status", task)
+	}
+	if container := task.Containers["nginx"]; container == nil || container.Status != "STOPPED" || *container.ExitCode != 1 {
+		t.Error("Unexpected container status", container)
+	}
+
+	// Statuses already written are kept across restarts
+	reopened, err := NewStatusClient(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.tasks[arn]; !ok {
+		t.Error("Expected the existing statuses to be read")
+	}
+	if _, err := reopened.RegisterContainerInstance(); err == nil {
+		t.Error("Expected standalone agents not to register")
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package standalone runs tasks from task definition files in a local
+// directory, without ACS, TCS or the ECS API. Adding a file starts its task,
+// removing it stops the task and changing it replaces the task. The state
+// changes which would be submitted to ECS are written to a local status file
+// instead.
+package standalone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecsacs"
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+var log = logger.ForModule("standalone")
+
+const (
+	// taskFileExt is the extension of task files; other files are ignored
+	taskFileExt = ".json"
+	// arnPrefix begins the arn of every task started from a task file. The
+	// rest is the file's name, a hash of its contents and when the task was
+	// started, so that a task can be matched back to its file after a
+	// restart and a replacement never reuses the arn of a stopped task.
+	arnPrefix = "arn:aws:ecs:local:standalone:task/"
+	// hashLength is how many hex characters of a file's hash are kept
+	hashLength = 16
+)
+
+// pollInterval is how often the task directory is checked for changes
+var pollInterval = 2 * time.Second
+
+// Watcher keeps the tasks of a task engine in line with the task files in a
+// directory
+type Watcher struct {
+	dir        string
+	taskEngine engine.TaskEngine
+
+	// tasks maps the name of each task file to the task started from it
+	tasks map[string]fileTask
+	// invalid records the hash of each task file which could not be parsed,
+	// so that it is only reported once
+	invalid map[string]string
+}
+
+type fileTask struct {
+	arn  string
+	hash string
+}
+
+// NewWatcher returns a Watcher of the task files in dir. Tasks restored into
+// taskEngine from saved state are matched back to their files.
+func NewWatcher(dir string, taskEngine engine.TaskEngine) *Watcher {
+	watcher := &Watcher{
+		dir:        dir,
+		taskEngine: taskEngine,
+		tasks:      make(map[string]fileTask),
+		invalid:    make(map[string]string),
+	}
+	tasks, _ := taskEngine.ListTasks()
+	for _, task := range tasks {
+		name, hash, ok := parseArn(task.Arn)
+		if !ok || task.DesiredStatus >= api.TaskStopped {
+			continue
+		}
+		watcher.tasks[name] = fileTask{arn: task.Arn, hash: hash}
+	}
+	return watcher
+}
+
+// Run syncs the task engine with the task files forever
+func (watcher *Watcher) Run() {
+	log.Info("Watching for task files", "dir", watcher.dir)
+	for {
+		if err := watcher.Sync(); err != nil {
+			log.Error("Unable to read task files", "dir", watcher.dir, "err", err)
+		}
+		ttime.Sleep(pollInterval)
+	}
+}
+
+// Sync starts, stops and replaces tasks to match the current task files
+func (watcher *Watcher) Sync() error {
+	files, err := readTaskFiles(watcher.dir)
+	if err != nil {
+		return err
+	}
+
+	for name, current := range watcher.tasks {
+		if _, ok := files[name]; !ok {
+			log.Info("Task file removed; stopping task", "file", name, "task", current.arn)
+			watcher.stopTask(current.arn)
+			delete(watcher.tasks, name)
+		}
+	}
+	for name, data := range files {
+		hash := hashOf(data)
+		current, running := watcher.tasks[name]
+		if running && current.hash == hash || watcher.invalid[name] == hash {
+			continue
+		}
+		task, err := parseTask(name, hash, data)
+		if err != nil {
+			// A file which is still being written is likely to be invalid
+			// for a moment; leave any task started from it running until it
+			// is replaced by a valid one
+			log.Warn("Invalid task file", "file", name, "err", err)
+			watcher.invalid[name] = hash
+			continue
+		}
+		delete(watcher.invalid, name)
+		if running {
+			log.Info("Task file changed; replacing task", "file", name, "task", current.arn)
+			watcher.stopTask(current.arn)
+		}
+		log.Info("Starting task", "file", name, "task", task.Arn)
+		watcher.taskEngine.AddTask(task)
+		watcher.tasks[name] = fileTask{arn: task.Arn, hash: hash}
+	}
+	for name := range watcher.invalid {
+		if _, ok := files[name]; !ok {
+			delete(watcher.invalid, name)
+		}
+	}
+	return nil
+}
+
+func (watcher *Watcher) stopTask(arn string) {
+	tasks, _ := watcher.taskEngine.ListTasks()
+	for _, task := range tasks {
+		if task.Arn == arn {
+			// The engine only ever raises a known task's desired status,
+			// which is all this needs to say
+			watcher.taskEngine.AddTask(&api.Task{Arn: arn, DesiredStatus: api.TaskStopped})
+			return
+		}
+	}
+	// It stopped, and was swept, long ago
+}
+
+// readTaskFiles returns the contents of each task file in dir by name
+func readTaskFiles(dir string) (map[string][]byte, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != taskFileExt || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			// It may have been removed since the directory was read
+			log.Warn("Unable to read task file", "file", entry.Name(), "err", err)
+			continue
+		}
+		files[strings.TrimSuffix(entry.Name(), taskFileExt)] = data
+	}
+	return files, nil
+}
+
+// parseTask converts a task file, in the shape ACS sends tasks in, into a
+// task to start
+func parseTask(name, hash string, data []byte) (*api.Task, error) {
+	var acsTask ecsacs.Task
+	if err := json.Unmarshal(data, &acsTask); err != nil {
+		return nil, err
+	}
+	task, err := api.TaskFromACS(&acsTask)
+	if err != nil {
+		return nil, err
+	}
+	if len(task.Containers) == 0 {
+		return nil, errors.New("task has no containers")
+	}
+	task.Arn = fmt.Sprintf("%s%s/%s/%d", arnPrefix, name, hash, ttime.Now().UnixNano())
+	if task.Family == "" {
+		task.Family = name
+	}
+	task.DesiredStatus = api.TaskRunning
+	task.ReceivedAt = ttime.Now()
+	return task, nil
+}
+
+// parseArn returns the name and hash of the task file a task was started
+// from, or false if it was not started from one
+func parseArn(arn string) (string, string, bool) {
+	if !strings.HasPrefix(arn, arnPrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(arn, arnPrefix), "/")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	if _, err := strconv.ParseInt(parts[2], 10, 64); err != nil {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func hashOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:hashLength]
+}
@@ -0,0 +1,141 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package standalone
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+)
+
+const testTaskFile = `{
+	"family": "web",
+	"version": "1",
+	"containers": [{"name": "nginx", "image": "nginx:latest", "essential": true}]
+}`
+
+// fakeTaskEngine keeps the tasks it is given, raising the desired status of
+// known tasks as the real engine does
+type fakeTaskEngine struct {
+	engine.TaskEngine
+	tasks []*api.Task
+}
+
+func (e *fakeTaskEngine) AddTask(task *api.Task) error {
+	for _, known := range e.tasks {
+		if known.Arn == task.Arn {
+			if task.DesiredStatus > known.DesiredStatus {
+				known.DesiredStatus = task.DesiredStatus
+			}
+			return nil
+		}
+	}
+	e.tasks = append(e.tasks, task)
+	return nil
+}
+
+func (e *fakeTaskEngine) ListTasks() ([]*api.Task, error) {
+	return e.tasks, nil
+}
+
+func (e *fakeTaskEngine) running() []*api.Task {
+	var running []*api.Task
+	for _, task := range e.tasks {
+		if task.DesiredStatus < api.TaskStopped {
+			running = append(running, task)
+		}
+	}
+	return running
+}
+
+func TestWatcherSync(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "standalone")
+	defer os.RemoveAll(dir)
+	taskEngine := &fakeTaskEngine{}
+	watcher := NewWatcher(dir, taskEngine)
+
+	ioutil.WriteFile(filepath.Join(dir, "web.json"), []byte(testTaskFile), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a task"), 0644)
+	if err := watcher.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	running := taskEngine.running()
+	if len(running) != 1 {
+		t.Fatal("Expected one task to be started, got", len(running))
+	}
+	started := running[0]
+	if name, _, ok := parseArn(started.Arn); !ok || name != "web" {
+		t.Error("Expected the task's arn to name its file", started.Arn)
+	}
+	if started.Family != "web" || len(started.Containers) != 1 || started.Containers[0].Image != "nginx:latest" {
+		t.Error("Unexpected task", started)
+	}
+
+	// Nothing changed
+	watcher.Sync()
+	if len(taskEngine.tasks) != 1 {
+		t.Error("Expected an unchanged file to leave its task alone")
+	}
+
+	// A partially written file leaves the task running
+	ioutil.WriteFile(filepath.Join(dir, "web.json"), []byte(`{"family": "web", "containers": [`), 0644)
+	watcher.Sync()
+	if len(taskEngine.tasks) != 1 || started.DesiredStatus != api.TaskRunning {
+		t.Error("Expected an invalid file to leave its task running")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "web.json"), []byte(testTaskFile+"\n"), 0644)
+	watcher.Sync()
+	running = taskEngine.running()
+	if started.DesiredStatus != api.TaskStopped || len(running) != 1 || running[0].Arn == started.Arn {
+		t.Error("Expected a changed file to replace its task")
+	}
+
+	os.Remove(filepath.Join(dir, "web.json"))
+	watcher.Sync()
+	if len(taskEngine.running()) != 0 {
+		t.Error("Expected a removed file to stop its task")
+	}
+}
+
+func TestWatcherAdoptsRestoredTasks(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "standalone")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "web.json"), []byte(testTaskFile), 0644)
+
+	restored, err := parseTask("web", hashOf([]byte(testTaskFile)), []byte(testTaskFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskEngine := &fakeTaskEngine{tasks: []*api.Task{restored}}
+	NewWatcher(dir, taskEngine).Sync()
+
+	if len(taskEngine.tasks) != 1 || restored.DesiredStatus != api.TaskRunning {
+		t.Error("Expected the restored task to be kept rather than replaced")
+	}
+}
+
+func TestParseArn(t *testing.T) {
+	if _, _, ok := parseArn("arn:aws:ecs:us-west-2:123456789012:task/abc"); ok {
+		t.Error("Expected tasks from ECS not to be matched to task files")
+	}
+	name, hash, ok := parseArn(arnPrefix + "web/0123456789abcdef/1488603967000000000")
+	if !ok || name != "web" || hash != "0123456789abcdef" {
+		t.Error("Unexpected file for arn", name, hash, ok)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/diagnostics"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+const testSecret = "hunter2-do-not-leak"
+
+var (
+	testKey1 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, statemanager.KeySize))
+	testKey2 = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, statemanager.KeySize))
+)
+
+// loadSecretState loads the state in dataDir with the given backend and keys
+func loadSecretState(t *testing.T, backend, dataDir, keys string) (statemanager.StateManager, engine.TaskEngine, error) {
+	cfg := &config.Config{DataDir: dataDir, StateSnapshots: 3, StateEncryptionKey: keys}
+	newStateManager := statemanager.NewStateManager
+	if backend == config.StateBackendKV {
+		newStateManager = statemanager.NewKVStateManager
+	}
+	taskEngine := engine.NewTaskEngine(&config.Config{})
+	var cluster string
+	manager, err := newStateManager(cfg,
+		statemanager.AddSaveable("TaskEngine", taskEngine),
+		statemanager.AddSaveable("Cluster", &cluster),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager, taskEngine, manager.Load()
+}
+
+func mustLoadSecretState(t *testing.T, backend, dataDir, keys string) (statemanager.StateManager, engine.TaskEngine) {
+	manager, taskEngine, err := loadSecretState(t, backend, dataDir, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks, _ := taskEngine.ListTasks()
+	if len(tasks) != 2 {
+		t.Fatal("Expected the fixture's tasks to be loaded, got", tasks)
+	}
+	return manager, taskEngine
+}
+
+// filesContaining returns the files under dir which contain text
+func filesContaining(t *testing.T, dir, text string) []string {
+	found := []string{}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(text)) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found
+}
+
+func hasSecret(taskEngine engine.TaskEngine) bool {
+	task, ok := taskEngine.TaskState().TaskByArn(webTaskArn)
+	return ok && task.Containers[0].Environment["DB_PASSWORD"] == testSecret
+}
+
+func TestStateEncryptionAtRest(t *testing.T) {
+	fixture, err := ioutil.ReadFile("testdata/ecs_agent_data_v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The web task's first container is given a secret, as it would have
+	// been when received
+	fixture = bytes.Replace(fixture, []byte(`"environment": null`), []byte(`"environment": {"DB_PASSWORD": "`+testSecret+`"}`), 1)
+	for _, backend := range []string{config.StateBackendJSON, config.StateBackendKV} {
+		dataDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+		defer os.RemoveAll(dataDir)
+		ioutil.WriteFile(statemanager.DataFile(dataDir), fixture, 0600)
+
+		// Save a secret without encryption, as before it was enabled
+		manager, taskEngine := mustLoadSecretState(t, backend, dataDir, "")
+		if !hasSecret(taskEngine) {
+			t.Fatal(backend, "Expected the fixture's secret to be loaded")
+		}
+		manager.ForceSave()
+		manager.ForceSave()
+		if len(filesContaining(t, dataDir, testSecret)) == 0 {
+			t.Fatal(backend, "Expected the secret to be saved in plaintext without encryption")
+		}
+
+		// Enabling encryption should leave no plaintext behind once saved,
+		// and diagnostics should not write any either
+		manager, taskEngine = mustLoadSecretState(t, backend, dataDir, testKey1)
+		manager.ForceSave()
+		manager.ForceSave()
+		if _, err := diagnostics.WriteBundle(&config.Config{DataDir: dataDir}, taskEngine); err != nil {
+			t.Fatal(err)
+		}
+		if found := filesContaining(t, dataDir, testSecret); len(found) != 0 {
+			t.Error(backend, "Expected no plaintext secrets on disk, found it in", found)
+		}
+		if found := filesContaining(t, dataDir, testKey1); len(found) != 0 {
+			t.Error(backend, "Expected the key not to be saved, found it in", found)
+		}
+		if _, _, err := loadSecretState(t, backend, dataDir, ""); err == nil {
+			t.Error(backend, "Expected encrypted state not to load without its key")
+		}
+
+		// Rotating the key should re-encrypt the state on the next save,
+		// after which the old key is no longer needed
+		manager, taskEngine = mustLoadSecretState(t, backend, dataDir, testKey2+","+testKey1)
+		if !hasSecret(taskEngine) {
+			t.Error(backend, "Expected the secret to be decrypted")
+		}
+		manager.ForceSave()
+		_, taskEngine = mustLoadSecretState(t, backend, dataDir, testKey2)
+		if !hasSecret(taskEngine) {
+			t.Error(backend, "Expected the state to be re-encrypted with the new key")
+		}
+		if found := filesContaining(t, dataDir, testSecret); len(found) != 0 {
+			t.Error(backend, "Expected no plaintext secrets on disk, found it in", found)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the size of the keys state is encrypted with, for AES-256
+const KeySize = 32
+
+// Sealed data is sealedFormat, the ID of the key it was sealed with, a nonce
+// and then the AES-GCM ciphertext. No JSON begins with sealedFormat, so sealed
+// and plaintext values can be told apart.
+const (
+	sealedFormat byte = 1
+	keyIDSize         = 8
+)
+
+// ErrNoStateKey is returned when reading encrypted state without a key
+var ErrNoStateKey = errors.New("the state is encrypted, but no key to decrypt it with is configured")
+
+// errUnknownStateKey is returned when reading state encrypted with a key
+// which is not configured
+var errUnknownStateKey = errors.New("the state is encrypted with a key which is not configured")
+
+// errStateAuthentication is returned when encrypted state fails to
+// authenticate, and so has been corrupted or tampered with
+var errStateAuthentication = errors.New("encrypted state failed to authenticate")
+
+// A Cipher encrypts state with the first of its keys, and decrypts state
+// encrypted with any of them
+type Cipher struct {
+	keys []cipherKey
+}
+
+type cipherKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+// NewCipher returns a Cipher which encrypts with the first of keys. The rest
+// are only used to decrypt state saved before the first was introduced.
+func NewCipher(keys [][]byte) (*Cipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no state encryption keys")
+	}
+	c := &Cipher{}
+	for i, key := range keys {
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("state encryption key %d is %d bytes; expected %d", i+1, len(key), KeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.keys = append(c.keys, cipherKey{id: keyID(key), aead: aead})
+	}
+	return c, nil
+}
+
+// keyID identifies a key without revealing it
+func keyID(key []byte) []byte {
+	sum := sha256.Sum256(key)
+	return sum[:keyIDSize]
+}
+
+// isSealed reports whether data was sealed by a Cipher
+func isSealed(data []byte) bool {
+	return len(data) > 0 && data[0] == sealedFormat
+}
+
+// seal encrypts and authenticates plaintext, and authenticates aad, with the
+// current key
+func (c *Cipher) seal(plaintext, aad []byte) ([]byte, error) {
+	key := c.keys[0]
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := make([]byte, 0, 1+keyIDSize+len(nonce)+len(plaintext)+key.aead.Overhead())
+	sealed = append(sealed, sealedFormat)
+	sealed = append(sealed, key.id...)
+	sealed = append(sealed, nonce...)
+	return key.aead.Seal(sealed, nonce, plaintext, aad), nil
+}
+
+// open decrypts data sealed with any of the keys. It reports whether it was
+// sealed with the current key, as otherwise it should be sealed again.
+func (c *Cipher) open(sealed, aad []byte) ([]byte, bool, error) {
+	if !isSealed(sealed) || len(sealed) < 1+keyIDSize {
+		return nil, false, errStateAuthentication
+	}
+	id := sealed[1 : 1+keyIDSize]
+	for i, key := range c.keys {
+		if !bytes.Equal(key.id, id) {
+			continue
+		}
+		rest := sealed[1+keyIDSize:]
+		if len(rest) < key.aead.NonceSize() {
+			return nil, false, errStateAuthentication
+		}
+		nonce, ciphertext := rest[:key.aead.NonceSize()], rest[key.aead.NonceSize():]
+		plaintext, err := key.aead.Open(nil, nonce, ciphertext, aad)
+		if err != nil {
+			return nil, false, errStateAuthentication
+		}
+		return plaintext, i == 0, nil
+	}
+	return nil, false, errUnknownStateKey
+}
@@ -0,0 +1,207 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, KeySize)
+}
+
+func TestCipher(t *testing.T) {
+	old, _ := NewCipher([][]byte{testKey(1)})
+	rotated, _ := NewCipher([][]byte{testKey(2), testKey(1)})
+
+	sealed, err := old.seal([]byte("plaintext"), []byte("aad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("plaintext")) {
+		t.Error("Expected the plaintext to be encrypted")
+	}
+	plaintext, current, err := rotated.open(sealed, []byte("aad"))
+	if err != nil || string(plaintext) != "plaintext" {
+		t.Fatal("Expected an older key to decrypt", err)
+	}
+	if current {
+		t.Error("Expected data sealed with an older key not to be current")
+	}
+
+	if _, _, err := rotated.open(sealed, []byte("other")); err != errStateAuthentication {
+		t.Error("Expected different associated data to fail to authenticate, got", err)
+	}
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	if _, _, err := old.open(tampered, []byte("aad")); err != errStateAuthentication {
+		t.Error("Expected tampered data to fail to authenticate, got", err)
+	}
+	other, _ := NewCipher([][]byte{testKey(3)})
+	if _, _, err := other.open(sealed, []byte("aad")); err != errUnknownStateKey {
+		t.Error("Expected an unknown key error, got", err)
+	}
+
+	if _, err := NewCipher([][]byte{[]byte("short")}); err == nil {
+		t.Error("Expected keys of the wrong size to be rejected")
+	}
+}
+
+type testKeyProvider struct{}
+
+func (testKeyProvider) Name() string            { return "test" }
+func (testKeyProvider) Keys() ([][]byte, error) { return [][]byte{testKey(4)}, nil }
+
+func TestCipherFromConfig(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	oldKey := base64.StdEncoding.EncodeToString(testKey(2))
+
+	if c, err := CipherFromConfig(&config.Config{}); c != nil || err != nil {
+		t.Error("Expected no cipher without keys", c, err)
+	}
+	c, err := CipherFromConfig(&config.Config{StateEncryptionKey: key + "," + oldKey})
+	if err != nil || len(c.keys) != 2 {
+		t.Error("Expected two keys from the environment", err)
+	}
+
+	keyFile, _ := ioutil.TempFile("", "ecs_state_keys")
+	defer os.Remove(keyFile.Name())
+	keyFile.WriteString("# current\n" + key + "\n# previous\n" + oldKey + "\n")
+	keyFile.Close()
+	c, err = CipherFromConfig(&config.Config{StateEncryptionKeyFile: keyFile.Name()})
+	if err != nil || len(c.keys) != 2 {
+		t.Error("Expected two keys from the key file", err)
+	}
+
+	RegisterKeyProvider(testKeyProvider{})
+	defer func() { delete(keyProviders, "test") }()
+	c, err = CipherFromConfig(&config.Config{StateKeyProvider: "test"})
+	if err != nil || !bytes.Equal(c.keys[0].id, keyID(testKey(4))) {
+		t.Error("Expected the key from the provider", err)
+	}
+
+	for _, cfg := range []*config.Config{
+		{StateEncryptionKey: key, StateKeyProvider: "test"},
+		{StateEncryptionKey: "not base64!"},
+		{StateEncryptionKey: base64.StdEncoding.EncodeToString([]byte("short"))},
+		{StateKeyProvider: "unknown"},
+	} {
+		if _, err := CipherFromConfig(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestEncryptedStateFile(t *testing.T) {
+	value := "secret-value"
+	dataDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dataDir)
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	manager, err := NewStateManager(&config.Config{DataDir: dataDir, StateEncryptionKey: key}, AddSaveable("Value", &value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager.ForceSave()
+
+	path := DataFile(dataDir)
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), "secret-value") {
+		t.Error("Expected the state file to be encrypted")
+	}
+	if _, err := ReadRawState(path, nil); err != ErrNoStateKey {
+		t.Error("Expected reading without a key to fail, got", err)
+	}
+
+	// Tampering with the ciphertext is corruption, which snapshots recover
+	// from, but a missing key is not
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	file.Encrypted[len(file.Encrypted)-1] ^= 1
+	data, _ = json.Marshal(file)
+	ioutil.WriteFile(path, data, 0600)
+	cipher, _ := NewCipher([][]byte{testKey(1)})
+	if _, err := ReadRawState(path, cipher); err == nil {
+		t.Error("Expected tampered state to fail to load")
+	} else if _, ok := err.(*CorruptStateError); !ok {
+		t.Error("Expected tampered state to be corrupt, got", err)
+	}
+}
+
+func TestPlaintextCopiesEncrypted(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	cipher, _ := NewCipher([][]byte{testKey(1)})
+	plaintext := func(version int) []byte {
+		return []byte(`{"Data":{"Value":"secret-value"},"Version":` + strconv.Itoa(version) + `}`)
+	}
+	load := func(dataDir string) StateManager {
+		var value string
+		manager, _ := NewStateManager(&config.Config{DataDir: dataDir, StateEncryptionKey: key}, AddSaveable("Value", &value))
+		if err := manager.Load(); err != nil || value != "secret-value" {
+			t.Fatal("Expected the state to load", err, value)
+		}
+		return manager
+	}
+	assertEncrypted := func(path string) {
+		if data, err := ioutil.ReadFile(path); err != nil || bytes.Contains(data, []byte("secret-value")) {
+			t.Error("Expected an encrypted copy of the state file at", path, err)
+		}
+	}
+
+	// A backup taken before migrating is encrypted, and still loads with
+	// the key
+	dataDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dataDir)
+	path := DataFile(dataDir)
+	ioutil.WriteFile(path, plaintext(0), 0600)
+	load(dataDir)
+	assertEncrypted(path + ".v0.bak")
+	if raw, err := ReadRawState(path+".v0.bak", cipher); err != nil || raw.Version != 0 {
+		t.Error("Expected the encrypted backup to load", err)
+	}
+
+	// As is a corrupt state file moved aside
+	dataDir, _ = ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dataDir)
+	path = DataFile(dataDir)
+	ioutil.WriteFile(snapshotFile(path, 1), plaintext(EcsDataVersion), 0600)
+	ioutil.WriteFile(path, plaintext(EcsDataVersion)[:30], 0600)
+	load(dataDir)
+	assertEncrypted(path + corruptSuffix)
+
+	// Copies saved before encryption was enabled are encrypted on the
+	// first save
+	dataDir, _ = ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dataDir)
+	path = DataFile(dataDir)
+	ioutil.WriteFile(path, plaintext(EcsDataVersion), 0600)
+	copies := []string{path + ".v0.bak", path + corruptSuffix, path + migratedSuffix}
+	for _, file := range copies {
+		ioutil.WriteFile(file, plaintext(0), 0600)
+	}
+	load(dataDir).ForceSave()
+	for _, file := range copies {
+		assertEncrypted(file)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+// A KeyProvider supplies the keys the agent's state is encrypted with, such as
+// from a key management service. Providers are registered by name with
+// RegisterKeyProvider and chosen with ECS_STATE_KEY_PROVIDER.
+type KeyProvider interface {
+	Name() string
+	// Keys returns the KeySize byte key to encrypt state with, followed by
+	// any older keys which state saved earlier may still be encrypted with
+	Keys() ([][]byte, error)
+}
+
+var (
+	keyProviders     = make(map[string]KeyProvider)
+	keyProvidersLock sync.RWMutex
+)
+
+// RegisterKeyProvider makes a KeyProvider available by its name. It panics if
+// a provider is registered twice under the same name.
+func RegisterKeyProvider(provider KeyProvider) {
+	keyProvidersLock.Lock()
+	defer keyProvidersLock.Unlock()
+
+	name := provider.Name()
+	if _, exists := keyProviders[name]; exists {
+		panic("statemanager: RegisterKeyProvider called twice for " + name)
+	}
+	keyProviders[name] = provider
+}
+
+// RegisteredKeyProviders returns the names of the registered key providers
+func RegisteredKeyProviders() []string {
+	keyProvidersLock.RLock()
+	defer keyProvidersLock.RUnlock()
+
+	names := make([]string, 0, len(keyProviders))
+	for name := range keyProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CipherFromConfig returns a Cipher with the state encryption keys configured
+// in cfg, or nil if state encryption is not configured
+func CipherFromConfig(cfg *config.Config) (*Cipher, error) {
+	configured := 0
+	for _, setting := range []string{cfg.StateEncryptionKey, cfg.StateEncryptionKeyFile, cfg.StateKeyProvider} {
+		if setting != "" {
+			configured++
+		}
+	}
+	if configured == 0 {
+		return nil, nil
+	}
+	if configured > 1 {
+		return nil, errors.New("Only one of a state encryption key, key file or key provider may be configured")
+	}
+
+	var keys [][]byte
+	var err error
+	switch {
+	case cfg.StateEncryptionKey != "":
+		keys, err = parseKeys(cfg.StateEncryptionKey)
+	case cfg.StateEncryptionKeyFile != "":
+		var data []byte
+		data, err = ioutil.ReadFile(cfg.StateEncryptionKeyFile)
+		if err == nil {
+			keys, err = parseKeys(string(data))
+		}
+	default:
+		keyProvidersLock.RLock()
+		provider, ok := keyProviders[cfg.StateKeyProvider]
+		keyProvidersLock.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("Unknown state key provider %q; registered providers are %v", cfg.StateKeyProvider, RegisteredKeyProviders())
+		}
+		keys, err = provider.Keys()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read state encryption keys: %v", err)
+	}
+	return NewCipher(keys)
+}
+
+// parseKeys parses base64 encoded keys separated by commas or whitespace,
+// ignoring lines starting with #
+func parseKeys(text string) ([][]byte, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			key, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("key %d is not base64: %v", len(keys)+1, err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
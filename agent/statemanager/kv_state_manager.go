@@ -0,0 +1,367 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager/kvstore"
+)
+
+// Filename of the key-value store in the ECS_DATADIR
+const ecsDataKVFile = "ecs_agent_data.db"
+
+// migratedSuffix is appended to the name of a state file once its contents
+// have been moved into the key-value store
+const migratedSuffix = ".migrated"
+
+// Keys in the key-value store. Each Saveable is stored under its name, or, if
+// it is a RecordSaveable, each of its records under its name and the
+// record's key.
+const (
+	kvVersionKey     = "version"
+	kvSaveablePrefix = "saveable/"
+)
+
+// kvStateManager saves state to a key-value store. Rather than rewriting all
+// of the state on each save, it writes only the records which have changed,
+// and so it saves on every call to Save.
+type kvStateManager struct {
+	dataDir   string
+	saveables saveableState
+	cipher    *Cipher // encrypts each record, if configured
+
+	lock  sync.Mutex        // serializes saves
+	db    *kvstore.DB       // opened by the first Load or save
+	saved map[string][]byte // the value last saved under each key, or nil if it must be saved again
+
+	// compact is set when the store holds records in plaintext or
+	// encrypted with an old key, which once saved again should be compacted
+	// away
+	compact bool
+
+	removedPlaintext bool // whether copies of the state file saved before encryption was enabled have been encrypted or removed
+}
+
+// NewKVStateManager constructs a new StateManager which saves data to a
+// key-value store in the DataDir specified in cfg. On its first Load it moves
+// any state saved by the StateManager NewStateManager returns into the store.
+func NewKVStateManager(cfg *config.Config, options ...Option) (StateManager, error) {
+	if err := checkDataDir(cfg.DataDir); err != nil {
+		return nil, err
+	}
+	cipher, err := CipherFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	manager := &kvStateManager{
+		dataDir:   cfg.DataDir,
+		saveables: make(saveableState),
+		cipher:    cipher,
+		saved:     make(map[string][]byte),
+	}
+	for _, option := range options {
+		option(manager)
+	}
+	return manager, nil
+}
+
+// KVDataFile returns the path of the key-value store in dataDir
+func KVDataFile(dataDir string) string {
+	return filepath.Join(dataDir, ecsDataKVFile)
+}
+
+func (manager *kvStateManager) addSaveable(name string, saveable Saveable) {
+	manager.saveables[name] = &saveable
+}
+
+// Save saves any changes to the state immediately
+func (manager *kvStateManager) Save() error {
+	return manager.ForceSave()
+}
+
+// ForceSave saves any changes to the state in a single transaction. As with
+// the file-based StateManager, callers need not handle the error it returns
+// beyond logging it.
+func (manager *kvStateManager) ForceSave() error {
+	start := time.Now()
+	err := manager.writeState()
+	saveSeconds.Since(start)
+	if err != nil {
+		saveFailures.Inc()
+	}
+	return err
+}
+
+// writeState performs the work of ForceSave
+func (manager *kvStateManager) writeState() error {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if err := manager.open(); err != nil {
+		return err
+	}
+
+	records := map[string][]byte{kvVersionKey: []byte(strconv.Itoa(EcsDataVersion))}
+	for name, saveable := range manager.saveables {
+		if recordSaveable, ok := (*saveable).(RecordSaveable); ok {
+			saveableRecords, err := recordSaveable.SaveRecords()
+			if err != nil {
+				log.Error("Error saving state; could not marshal records", "saveable", name, "err", err)
+				return err
+			}
+			for key, data := range saveableRecords {
+				records[kvSaveablePrefix+name+"/"+key] = data
+			}
+			continue
+		}
+		data, err := json.Marshal(saveable)
+		if err != nil {
+			log.Error("Error saving state; could not marshal data", "saveable", name, "err", err)
+			return err
+		}
+		records[kvSaveablePrefix+name] = data
+	}
+
+	changed := 0
+	err := manager.db.Update(func(tx *kvstore.Tx) error {
+		for key, data := range records {
+			if saved, ok := manager.saved[key]; !ok || !bytes.Equal(saved, data) {
+				stored, err := manager.encode(key, data)
+				if err != nil {
+					return err
+				}
+				tx.Put(key, stored)
+				changed++
+			}
+		}
+		for key := range manager.saved {
+			if _, ok := records[key]; !ok {
+				tx.Delete(key)
+				changed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Error("Error saving state", "err", err)
+		manager.closeIfLost(err)
+		return err
+	}
+	if changed > 0 {
+		log.Debug("Saved state", "records", changed)
+	}
+	manager.saved = records
+	if manager.cipher != nil && !manager.removedPlaintext {
+		// The state file, once migrated, may have left copies behind
+		// which were saved before encryption was enabled
+		removePlaintextCopies(DataFile(manager.dataDir), manager.cipher)
+		manager.removedPlaintext = true
+	}
+	if manager.compact {
+		if err := manager.db.Compact(); err != nil {
+			if manager.closeIfLost(err) {
+				log.Error("Error saving state; the compacted state store could not be opened", "err", err)
+				return err
+			}
+			log.Warn("Unable to compact the state store", "err", err)
+		} else {
+			manager.compact = false
+		}
+	}
+	return nil
+}
+
+// closeIfLost forgets the store if err means it was closed after compaction,
+// so that the next save opens it again, and reports whether it did. It must
+// be called with the lock held.
+func (manager *kvStateManager) closeIfLost(err error) bool {
+	if _, ok := err.(*kvstore.ReopenError); !ok {
+		return false
+	}
+	manager.db = nil
+	return true
+}
+
+// encode returns the value to store data under key as, encrypting it if
+// encryption is configured. The version is never encrypted.
+func (manager *kvStateManager) encode(key string, data []byte) ([]byte, error) {
+	if manager.cipher == nil || key == kvVersionKey {
+		return data, nil
+	}
+	return manager.cipher.seal(data, []byte(key))
+}
+
+// decode returns the data stored under key, decrypting it if it is
+// encrypted. It reports whether the value is stored as it would be saved now,
+// rather than in plaintext or encrypted with an old key.
+func (manager *kvStateManager) decode(key string, value []byte) ([]byte, bool, error) {
+	if !isSealed(value) {
+		return value, manager.cipher == nil || key == kvVersionKey, nil
+	}
+	if manager.cipher == nil {
+		return nil, false, ErrNoStateKey
+	}
+	// The record's key is authenticated along with it, so that records
+	// cannot be swapped for one another
+	data, current, err := manager.cipher.open(value, []byte(key))
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to decrypt state record %s: %v", key, err)
+	}
+	return data, current, nil
+}
+
+// open opens the store, if it is not already open, and notes what is saved
+// in it so that the first save writes only what has changed. It must be
+// called with the lock held.
+func (manager *kvStateManager) open() error {
+	if manager.db != nil {
+		return nil
+	}
+	db, err := kvstore.Open(KVDataFile(manager.dataDir))
+	if err != nil {
+		log.Error("Error opening the state store", "err", err)
+		return err
+	}
+	saved := make(map[string][]byte)
+	err = db.ForEach("", func(key string, value []byte) error {
+		data, current, err := manager.decode(key, value)
+		if err != nil {
+			return err
+		}
+		if !current {
+			data = nil
+			manager.compact = true
+		}
+		saved[key] = data
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		log.Error("Error reading the state store", "err", err)
+		return err
+	}
+	manager.db = db
+	manager.saved = saved
+	return nil
+}
+
+// Load loads the state saved in the key-value store. If the store has not
+// been saved to yet, it loads the state file instead, saves it to the store
+// and renames the state file so that it is not loaded again.
+func (manager *kvStateManager) Load() error {
+	log.Info("Loading state!")
+	manager.lock.Lock()
+	err := manager.open()
+	manager.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	version, ok := manager.db.Get(kvVersionKey)
+	if !ok {
+		return manager.migrateStateFile()
+	}
+	if string(version) != strconv.Itoa(EcsDataVersion) {
+		// Records are migrated between versions as whole saveables; a
+		// change to the data version must also teach this how to migrate
+		// the store
+		return fmt.Errorf("unable to load state store saved at data version %s; this agent uses version %d", version, EcsDataVersion)
+	}
+
+	data := make(map[string]json.RawMessage)
+	records := make(map[string]map[string]json.RawMessage)
+	err = manager.db.ForEach(kvSaveablePrefix, func(key string, value []byte) error {
+		value, _, err := manager.decode(key, value)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimPrefix(key, kvSaveablePrefix)
+		if slash := strings.Index(name, "/"); slash >= 0 {
+			if records[name[:slash]] == nil {
+				records[name[:slash]] = make(map[string]json.RawMessage)
+			}
+			records[name[:slash]][name[slash+1:]] = value
+			return nil
+		}
+		data[name] = value
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := loadSaveables(manager.saveables, data); err != nil {
+		return err
+	}
+	for name, saveableRecords := range records {
+		saveable, ok := manager.saveables[name]
+		if !ok {
+			log.Error("Loading state: unknown saveable " + name)
+			continue
+		}
+		recordSaveable, ok := (*saveable).(RecordSaveable)
+		if !ok {
+			return fmt.Errorf("unable to load records into %s", name)
+		}
+		if err := recordSaveable.LoadRecords(saveableRecords); err != nil {
+			return err
+		}
+	}
+	log.Debug("Loaded state!")
+	return nil
+}
+
+// migrateStateFile moves the state saved in the state file, if there is one,
+// into the store
+func (manager *kvStateManager) migrateStateFile() error {
+	path := DataFile(manager.dataDir)
+	raw, err := readStateFile(path, manager.cipher)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Happens every first run; not a real error
+			return nil
+		}
+		return err
+	}
+	if err := loadSaveables(manager.saveables, raw.Data); err != nil {
+		return err
+	}
+	if err := manager.writeState(); err != nil {
+		return err
+	}
+	if manager.cipher == nil {
+		if err := os.Rename(path, path+migratedSuffix); err != nil {
+			return err
+		}
+	} else {
+		// The state file may not have been encrypted, so rather than
+		// keeping it as it is, keep an encrypted copy
+		if err := WriteRawState(path+migratedSuffix, raw, manager.cipher); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	log.Info("Moved state from the state file into the state store", "file", path+migratedSuffix)
+	return nil
+}
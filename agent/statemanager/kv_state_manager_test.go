@@ -0,0 +1,167 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/api"
+	"github.com/aws/amazon-ecs-agent/agent/config"
+	"github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
+	"github.com/aws/amazon-ecs-agent/agent/statemanager"
+)
+
+const (
+	webTaskArn   = "arn:aws:ecs:us-west-2:123456789012:task/web-1"
+	batchTaskArn = "arn:aws:ecs:us-west-2:123456789012:task/batch-1"
+)
+
+type kvTestState struct {
+	taskEngine engine.TaskEngine
+	cluster    string
+	attributes map[string]string
+	manager    statemanager.StateManager
+}
+
+func newKVTestState(t *testing.T, dataDir string) *kvTestState {
+	s := &kvTestState{taskEngine: engine.NewTaskEngine(&config.Config{})}
+	var err error
+	s.manager, err = statemanager.NewKVStateManager(&config.Config{DataDir: dataDir},
+		statemanager.AddSaveable("TaskEngine", s.taskEngine),
+		statemanager.AddSaveable("Cluster", &s.cluster),
+		statemanager.AddSaveable("InstanceAttributes", &s.attributes),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.manager.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestKVStateManagerMigratesStateFile(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/ecs_agent_data_v1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(tmpDir)
+	ioutil.WriteFile(statemanager.DataFile(tmpDir), data, 0600)
+
+	s := newKVTestState(t, tmpDir)
+	tasks, _ := s.taskEngine.ListTasks()
+	if len(tasks) != 2 || s.cluster != "default" || s.attributes["stack"] != "prod" {
+		t.Error("Unexpected state migrated", tasks, s.cluster, s.attributes)
+	}
+	if _, err := os.Stat(statemanager.DataFile(tmpDir)); !os.IsNotExist(err) {
+		t.Error("Expected the state file to be renamed once migrated")
+	}
+
+	// The migrated state should now be loaded from the store
+	s = newKVTestState(t, tmpDir)
+	tasks, _ = s.taskEngine.ListTasks()
+	if len(tasks) != 2 || s.cluster != "default" {
+		t.Error("Unexpected state loaded", tasks, s.cluster)
+	}
+	for _, task := range tasks {
+		containers, ok := s.taskEngine.TaskState().ContainerMapByArn(task.Arn)
+		if !ok || len(containers) != len(task.Containers) {
+			t.Error("Expected the containers of", task.Arn, "to be restored, got", containers)
+		}
+		for name, container := range containers {
+			if taskContainer, _ := task.ContainerByName(name); container.Container != taskContainer {
+				t.Error("Expected restored containers to point to their task's containers")
+			}
+		}
+	}
+}
+
+func TestKVStateManagerSavesChanges(t *testing.T) {
+	data, _ := ioutil.ReadFile("testdata/ecs_agent_data_v1.json")
+	tmpDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(tmpDir)
+	ioutil.WriteFile(statemanager.DataFile(tmpDir), data, 0600)
+
+	s := newKVTestState(t, tmpDir)
+	size := func() int64 {
+		info, err := os.Stat(statemanager.KVDataFile(tmpDir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info.Size()
+	}
+	migrated := size()
+
+	if err := s.manager.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if size() != migrated {
+		t.Error("Expected a save without changes to write nothing")
+	}
+
+	s.cluster = "other"
+	if err := s.manager.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if grown := size() - migrated; grown <= 0 || grown > 64 {
+		t.Error("Expected only the cluster to be saved, but the store grew by", grown)
+	}
+
+	state := s.taskEngine.TaskState().(*dockerstate.DockerTaskEngineState)
+	web, _ := state.TaskByArn(webTaskArn)
+	web.DesiredStatus = api.TaskStopped
+	batch, _ := state.TaskByArn(batchTaskArn)
+	state.RemoveTask(batch)
+	if err := s.manager.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s = newKVTestState(t, tmpDir)
+	tasks, _ := s.taskEngine.ListTasks()
+	if len(tasks) != 1 || tasks[0].Arn != webTaskArn || tasks[0].DesiredStatus != api.TaskStopped || s.cluster != "other" {
+		t.Error("Unexpected state loaded", tasks, s.cluster)
+	}
+	if _, ok := s.taskEngine.TaskState().ContainerMapByArn(batchTaskArn); ok {
+		t.Error("Expected the removed task's containers to be removed")
+	}
+}
+
+func TestKVStateManagerSaveWithoutLoad(t *testing.T) {
+	data, _ := ioutil.ReadFile("testdata/ecs_agent_data_v1.json")
+	tmpDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(tmpDir)
+	ioutil.WriteFile(statemanager.DataFile(tmpDir), data, 0600)
+	newKVTestState(t, tmpDir)
+
+	// As the agent does once it has checked the saved state, save a new
+	// state over it without loading it first
+	cluster := "other"
+	manager, _ := statemanager.NewKVStateManager(&config.Config{DataDir: tmpDir},
+		statemanager.AddSaveable("TaskEngine", engine.NewTaskEngine(&config.Config{})),
+		statemanager.AddSaveable("Cluster", &cluster),
+	)
+	if err := manager.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newKVTestState(t, tmpDir)
+	tasks, _ := s.taskEngine.ListTasks()
+	if len(tasks) != 0 || s.cluster != "other" {
+		t.Error("Expected the saved state to replace the old, got", tasks, s.cluster)
+	}
+}
@@ -0,0 +1,476 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package kvstore is a small embedded, transactional key-value store.
+//
+// Every transaction is appended to a log file as a single checksummed frame
+// and synced before it commits, so a transaction is either wholly on disk or
+// not at all. The store is held in memory and the log is replayed when it is
+// opened. Once most of the log is made up of superseded records it is
+// rewritten. This suits stores of up to a few thousand small records, such as
+// the agent's state.
+package kvstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+)
+
+var log = logger.ForModule("kvstore")
+
+// magic begins every store file, and names the format version
+var magic = []byte("ECSKV\x00\x00\x01")
+
+// Each frame is the length of its payload and the payload's checksum, then
+// the payload: a sequence of operations.
+const frameHeaderSize = 8
+
+const (
+	opPut    byte = 1
+	opDelete byte = 2
+)
+
+// compactMinSize is the size below which the log is never compacted
+const compactMinSize = 1 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrClosed is returned when using a store that has been closed
+var ErrClosed = errors.New("kvstore: store is closed")
+
+// A CorruptError is returned by Open when a transaction in the log, other
+// than the last one, is damaged. The transactions after it cannot be trusted
+// to apply, so the store must be recovered by other means.
+type CorruptError struct {
+	Path   string
+	Offset int64
+	Err    error
+}
+
+func (err *CorruptError) Error() string {
+	return fmt.Sprintf("kvstore: %s is corrupt at offset %d: %v", err.Path, err.Offset, err.Err)
+}
+
+// A ReopenError is returned when the log was compacted but the new log could
+// not be opened. The store is closed and must be opened again to be used.
+type ReopenError struct {
+	Err error
+}
+
+func (err *ReopenError) Error() string {
+	return "kvstore: unable to open the compacted store: " + err.Err.Error()
+}
+
+// openLog opens the log at path for appending
+var openLog = func(path string, flag int) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDWR|os.O_APPEND|flag, 0600)
+}
+
+// DB is an open store. It is safe for concurrent use, though transactions
+// which update it run one at a time.
+type DB struct {
+	lock sync.RWMutex
+	path string
+	file *os.File
+	data map[string][]byte
+
+	size int64 // the size of the log
+	live int64 // roughly the size of the live records in it
+}
+
+// Open opens the store at path, creating it if it does not exist. If the
+// last transaction written was cut short, such as by a crash, it is
+// discarded. If any other transaction is damaged, it returns a *CorruptError
+// and leaves the file as it is.
+func Open(path string) (*DB, error) {
+	file, err := openLog(path, os.O_CREATE)
+	if err != nil {
+		return nil, err
+	}
+	db := &DB{path: path, file: file, data: make(map[string][]byte)}
+	if err := db.replay(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// replay loads the log into memory
+func (db *DB) replay() error {
+	contents, err := ioutil.ReadAll(db.file)
+	if err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		if _, err := db.file.Write(magic); err != nil {
+			return err
+		}
+		db.size = int64(len(magic))
+		return db.file.Sync()
+	}
+	if !bytes.HasPrefix(contents, magic) {
+		return fmt.Errorf("kvstore: %s is not a store", db.path)
+	}
+
+	offset := len(magic)
+	for offset < len(contents) {
+		payload, ok := readFrame(contents[offset:])
+		if !ok {
+			if !tornTail(contents[offset:]) {
+				return &CorruptError{Path: db.path, Offset: int64(offset), Err: errors.New("transaction fails its checksum")}
+			}
+			break
+		}
+		ops, err := decodeOps(payload)
+		if err != nil {
+			// The frame is intact, so it was written this way
+			return &CorruptError{Path: db.path, Offset: int64(offset), Err: err}
+		}
+		db.apply(ops)
+		offset += frameHeaderSize + len(payload)
+	}
+	if offset < len(contents) {
+		log.Warn("Discarding an incomplete transaction at the end of the store", "path", db.path, "bytes", len(contents)-offset)
+		if err := db.file.Truncate(int64(offset)); err != nil {
+			return err
+		}
+		if err := db.file.Sync(); err != nil {
+			return err
+		}
+	}
+	db.size = int64(offset)
+	return nil
+}
+
+// readFrame returns the payload of the frame at the start of data, if it is
+// complete and intact
+func readFrame(data []byte) ([]byte, bool) {
+	if len(data) < frameHeaderSize {
+		return nil, false
+	}
+	length := binary.BigEndian.Uint32(data[0:4])
+	checksum := binary.BigEndian.Uint32(data[4:8])
+	// Transactions are never empty, so an empty frame is most likely zeroes
+	// left where a write was cut short
+	if length == 0 || uint64(length) > uint64(len(data)-frameHeaderSize) {
+		return nil, false
+	}
+	payload := data[frameHeaderSize : frameHeaderSize+int(length)]
+	if crc32.Checksum(payload, crcTable) != checksum {
+		return nil, false
+	}
+	return payload, true
+}
+
+// tornTail reports whether data, which begins with a frame that is incomplete
+// or fails its checksum, is what a write cut short leaves at the end of the
+// log: a frame which runs to or past the end of the file, or zeroes. A damaged
+// frame followed by others is corruption instead.
+func tornTail(data []byte) bool {
+	if len(data) < frameHeaderSize {
+		return true
+	}
+	length := binary.BigEndian.Uint32(data[0:4])
+	if length == 0 {
+		return len(bytes.Trim(data, "\x00")) == 0
+	}
+	return uint64(length) >= uint64(len(data)-frameHeaderSize)
+}
+
+// op is a single put or delete; a nil value is a delete
+type op struct {
+	key   string
+	value []byte
+}
+
+func encodeFrame(ops []op) []byte {
+	var payload bytes.Buffer
+	varint := make([]byte, binary.MaxVarintLen64)
+	for _, o := range ops {
+		if o.value == nil {
+			payload.WriteByte(opDelete)
+		} else {
+			payload.WriteByte(opPut)
+		}
+		payload.Write(varint[:binary.PutUvarint(varint, uint64(len(o.key)))])
+		payload.WriteString(o.key)
+		if o.value != nil {
+			payload.Write(varint[:binary.PutUvarint(varint, uint64(len(o.value)))])
+			payload.Write(o.value)
+		}
+	}
+	frame := make([]byte, frameHeaderSize, frameHeaderSize+payload.Len())
+	binary.BigEndian.PutUint32(frame[0:4], uint32(payload.Len()))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.Checksum(payload.Bytes(), crcTable))
+	return append(frame, payload.Bytes()...)
+}
+
+func decodeOps(payload []byte) ([]op, error) {
+	var ops []op
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+		key, rest, err := readBytes(payload)
+		if err != nil {
+			return nil, err
+		}
+		payload = rest
+		switch kind {
+		case opDelete:
+			ops = append(ops, op{key: string(key)})
+		case opPut:
+			value, rest, err := readBytes(payload)
+			if err != nil {
+				return nil, err
+			}
+			payload = rest
+			ops = append(ops, op{key: string(key), value: value})
+		default:
+			return nil, fmt.Errorf("kvstore: unknown operation %d", kind)
+		}
+	}
+	return ops, nil
+}
+
+// readBytes reads a length-prefixed byte string
+func readBytes(data []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || length > uint64(len(data)-n) {
+		return nil, nil, errors.New("kvstore: malformed operation")
+	}
+	end := n + int(length)
+	return data[n:end:end], data[end:], nil
+}
+
+// apply applies committed operations to the in-memory copy of the store
+func (db *DB) apply(ops []op) {
+	for _, o := range ops {
+		if old, ok := db.data[o.key]; ok {
+			db.live -= int64(len(o.key) + len(old))
+		}
+		if o.value == nil {
+			delete(db.data, o.key)
+			continue
+		}
+		db.data[o.key] = o.value
+		db.live += int64(len(o.key) + len(o.value))
+	}
+}
+
+// Get returns a copy of the value stored under key
+func (db *DB) Get(key string) ([]byte, bool) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	value, ok := db.data[key]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte{}, value...), true
+}
+
+// ForEach calls fn, in key order, with a copy of each key and value whose key
+// begins with prefix. It stops at the first error fn returns.
+func (db *DB) ForEach(prefix string, fn func(key string, value []byte) error) error {
+	db.lock.RLock()
+	keys := []string{}
+	values := make(map[string][]byte)
+	for key, value := range db.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+			values[key] = append([]byte{}, value...)
+		}
+	}
+	db.lock.RUnlock()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tx is a transaction which updates the store
+type Tx struct {
+	db     *DB
+	writes map[string][]byte
+	order  []string
+}
+
+// Get returns the value stored under key, including any changes made earlier
+// in this transaction. The value must not be modified.
+func (tx *Tx) Get(key string) ([]byte, bool) {
+	if value, ok := tx.writes[key]; ok {
+		return value, value != nil
+	}
+	value, ok := tx.db.data[key]
+	return value, ok
+}
+
+// Put stores a copy of value under key
+func (tx *Tx) Put(key string, value []byte) {
+	tx.write(key, append([]byte{}, value...))
+}
+
+// Delete removes key from the store
+func (tx *Tx) Delete(key string) {
+	tx.write(key, nil)
+}
+
+func (tx *Tx) write(key string, value []byte) {
+	if _, ok := tx.writes[key]; !ok {
+		tx.order = append(tx.order, key)
+	}
+	tx.writes[key] = value
+}
+
+// Update runs fn in a transaction, and commits the changes it makes unless it
+// returns an error. Once Update returns nil, the changes are on disk.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.file == nil {
+		return ErrClosed
+	}
+
+	tx := &Tx{db: db, writes: make(map[string][]byte)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.order) == 0 {
+		return nil
+	}
+	ops := make([]op, 0, len(tx.order))
+	for _, key := range tx.order {
+		ops = append(ops, op{key: key, value: tx.writes[key]})
+	}
+
+	frame := encodeFrame(ops)
+	_, err := db.file.Write(frame)
+	if err == nil {
+		err = db.file.Sync()
+	}
+	if err != nil {
+		// Drop whatever part of the frame was written so that the log and
+		// the in-memory store still agree
+		db.file.Truncate(db.size)
+		return err
+	}
+	db.size += int64(len(frame))
+	db.apply(ops)
+
+	if db.size > compactMinSize && db.size > 2*db.live {
+		if err := db.compact(); err != nil {
+			if _, ok := err.(*ReopenError); ok {
+				// The transaction is in the compacted log, but
+				// no further ones can be
+				return err
+			}
+			// The transaction is committed either way; the log is
+			// only larger than it needs to be
+			log.Warn("Unable to compact the store", "path", db.path, "err", err)
+		}
+	}
+	return nil
+}
+
+// Compact rewrites the log to hold only the live records. If it returns a
+// *ReopenError, the store has been closed.
+func (db *DB) Compact() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.file == nil {
+		return ErrClosed
+	}
+	return db.compact()
+}
+
+// compact writes the live records to a new log as a single transaction and
+// renames it over the current one
+func (db *DB) compact() error {
+	keys := make([]string, 0, len(db.data))
+	for key := range db.data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ops := make([]op, 0, len(keys))
+	for _, key := range keys {
+		ops = append(ops, op{key: key, value: db.data[key]})
+	}
+	contents := append(append([]byte{}, magic...), encodeFrame(ops)...)
+
+	temp, err := ioutil.TempFile(filepath.Dir(db.path), filepath.Base(db.path)+".compact")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(contents)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), db.path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	// The current log has been replaced and no longer has a name, so it
+	// must not be written to again, even if the rename is not yet durable
+	db.file.Close()
+	file, err := openLog(db.path, 0)
+	if err != nil {
+		db.file = nil
+		return &ReopenError{Err: err}
+	}
+	db.file = file
+	db.size = int64(len(contents))
+	return syncDir(filepath.Dir(db.path))
+}
+
+// syncDir makes a rename within dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Close closes the store
+func (db *DB) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if db.file == nil {
+		return ErrClosed
+	}
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
@@ -0,0 +1,257 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package kvstore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tempStore(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "kvstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "test.db"), func() { os.RemoveAll(dir) }
+}
+
+func mustOpen(t *testing.T, path string) *DB {
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestUpdateAndReopen(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	db := mustOpen(t, path)
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("task/a", []byte("1"))
+		tx.Put("task/b", []byte("2"))
+		tx.Put("other", []byte("3"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *Tx) error {
+		tx.Delete("task/a")
+		tx.Put("task/b", []byte("4"))
+		if value, _ := tx.Get("task/b"); string(value) != "4" {
+			t.Error("Expected the transaction to see its own writes")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db = mustOpen(t, path)
+	defer db.Close()
+	if _, ok := db.Get("task/a"); ok {
+		t.Error("Expected task/a to have been deleted")
+	}
+	keys := []string{}
+	db.ForEach("task/", func(key string, value []byte) error {
+		keys = append(keys, key+"="+string(value))
+		return nil
+	})
+	if strings.Join(keys, ",") != "task/b=4" {
+		t.Error("Unexpected records", keys)
+	}
+}
+
+func TestUpdateError(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+	db := mustOpen(t, path)
+	defer db.Close()
+
+	expected := errors.New("rolled back")
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("key", []byte("value"))
+		return expected
+	})
+	if err != expected {
+		t.Error("Expected the transaction's error, got", err)
+	}
+	if _, ok := db.Get("key"); ok {
+		t.Error("Expected a failed transaction to change nothing")
+	}
+}
+
+func TestTornTransaction(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	db := mustOpen(t, path)
+	db.Update(func(tx *Tx) error {
+		tx.Put("first", []byte("committed"))
+		return nil
+	})
+	db.Update(func(tx *Tx) error {
+		tx.Put("second", []byte("cut short"))
+		return nil
+	})
+	db.Close()
+
+	// Cut the last transaction short as a crash part way through writing it
+	// would
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-3)
+
+	db = mustOpen(t, path)
+	if value, _ := db.Get("first"); string(value) != "committed" {
+		t.Error("Expected the first transaction to survive")
+	}
+	if _, ok := db.Get("second"); ok {
+		t.Error("Expected the torn transaction to be discarded")
+	}
+	db.Update(func(tx *Tx) error {
+		tx.Put("third", []byte("after"))
+		return nil
+	})
+	db.Close()
+
+	db = mustOpen(t, path)
+	defer db.Close()
+	if _, ok := db.Get("third"); !ok {
+		t.Error("Expected transactions after the torn one to be kept")
+	}
+}
+
+func TestCorruptTransaction(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	db := mustOpen(t, path)
+	db.Update(func(tx *Tx) error {
+		tx.Put("key", []byte("value"))
+		return nil
+	})
+	db.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	data[len(data)-1] ^= 0xff
+	ioutil.WriteFile(path, data, 0600)
+
+	db = mustOpen(t, path)
+	defer db.Close()
+	if _, ok := db.Get("key"); ok {
+		t.Error("Expected a transaction failing its checksum to be discarded")
+	}
+}
+
+func TestCorruptTransactionBeforeOthers(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+
+	db := mustOpen(t, path)
+	for _, key := range []string{"first", "second"} {
+		db.Update(func(tx *Tx) error {
+			tx.Put(key, []byte("value"))
+			return nil
+		})
+	}
+	db.Close()
+
+	// Flip a bit in the first transaction's payload
+	data, _ := ioutil.ReadFile(path)
+	data[len(magic)+frameHeaderSize+2] ^= 1
+	ioutil.WriteFile(path, data, 0600)
+
+	_, err := Open(path)
+	if corrupt, ok := err.(*CorruptError); !ok || corrupt.Offset != int64(len(magic)) {
+		t.Fatal("Expected the store to be reported corrupt at the first transaction, got", err)
+	}
+	if after, _ := ioutil.ReadFile(path); len(after) != len(data) {
+		t.Error("Expected the corrupt store to be left as it is")
+	}
+}
+
+func TestNotAStore(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+	ioutil.WriteFile(path, []byte(`{"Version":1}`), 0600)
+	if _, err := Open(path); err == nil {
+		t.Error("Expected an error opening a file that is not a store")
+	}
+}
+
+func TestCompact(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+	db := mustOpen(t, path)
+
+	value := make([]byte, 4096)
+	for i := 0; i < 1000; i++ {
+		err := db.Update(func(tx *Tx) error {
+			tx.Put("key"+strconv.Itoa(i%10), value)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, _ := os.Stat(path)
+	if info.Size() > 2*compactMinSize {
+		t.Error("Expected the log to have been compacted, it is", info.Size())
+	}
+	db.Close()
+
+	db = mustOpen(t, path)
+	defer db.Close()
+	count := 0
+	db.ForEach("key", func(string, []byte) error {
+		count++
+		return nil
+	})
+	if count != 10 {
+		t.Error("Expected 10 records after compaction, got", count)
+	}
+}
+
+func TestCompactReopenFails(t *testing.T) {
+	path, cleanup := tempStore(t)
+	defer cleanup()
+	db := mustOpen(t, path)
+	db.Update(func(tx *Tx) error {
+		tx.Put("key", []byte("value"))
+		return nil
+	})
+
+	defer func(original func(string, int) (*os.File, error)) { openLog = original }(openLog)
+	openLog = func(string, int) (*os.File, error) {
+		return nil, errors.New("no more files")
+	}
+	if _, ok := db.Compact().(*ReopenError); !ok {
+		t.Fatal("Expected compacting to fail to reopen the store")
+	}
+	err := db.Update(func(tx *Tx) error {
+		tx.Put("lost", []byte("value"))
+		return nil
+	})
+	if err != ErrClosed {
+		t.Error("Expected updates after a failed reopen to fail, got", err)
+	}
+}
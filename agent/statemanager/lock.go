@@ -0,0 +1,47 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// Filename in the ECS_DATADIR locked by whoever is using the state file
+const ecsDataLockFile = "ecs_agent_data.lock"
+
+// ErrDataDirLocked is returned by LockDataDir if another process, such as a
+// running agent, holds the lock
+var ErrDataDirLocked = errors.New("the data directory is in use by another process")
+
+// LockDataDir takes an exclusive lock on the state file in dataDir, so that
+// offline tools cannot edit it while the agent is running and vice versa. The
+// lock is held until the returned file is closed or the process exits.
+func LockDataDir(dataDir string) (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(dataDir, ecsDataLockFile), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, ErrDataDirLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,24 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import "encoding/json"
+
+func init() {
+	// Agents which saved their state without a version saved it in the
+	// same format as version 1, which only added the version itself
+	registerMigration(0, "add the data version", func(map[string]json.RawMessage) error {
+		return nil
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// A migration upgrades saved data from one version to the next. To change the
+// format of saved data, increment EcsDataVersion and register a migration
+// from the previous version in a file of its own, named for the version it
+// upgrades from (e.g. migrate_v1.go), and add a state file saved by the
+// previous version to testdata so TestMigrateFixtures loads it.
+type migration struct {
+	description string
+	// migrate changes the JSON of each saveable, keyed by its name, in place
+	migrate func(data map[string]json.RawMessage) error
+}
+
+// migrations holds the registered migrations by the version they upgrade from
+var migrations = make(map[int]migration)
+
+// registerMigration registers the migration from version from to from+1. It
+// is meant to be called from init and panics if one is already registered.
+func registerMigration(from int, description string, migrate func(data map[string]json.RawMessage) error) {
+	if _, ok := migrations[from]; ok {
+		panic(fmt.Sprintf("statemanager: migration from version %d registered twice", from))
+	}
+	migrations[from] = migration{description: description, migrate: migrate}
+}
+
+// migrate upgrades data saved at version from to version to, one version at
+// a time, using steps. Data is left partially migrated if a step fails.
+func migrate(steps map[int]migration, data map[string]json.RawMessage, from, to int) error {
+	for version := from; version < to; version++ {
+		step, ok := steps[version]
+		if !ok {
+			return fmt.Errorf("no migration from data version %d to %d", version, version+1)
+		}
+		log.Info("Migrating state", "from", version, "to", version+1, "migration", step.description)
+		if err := step.migrate(data); err != nil {
+			return fmt.Errorf("migrating data version %d to %d (%s): %v", version, version+1, step.description, err)
+		}
+	}
+	return nil
+}
+
+// PendingMigrations describes the migrations that would upgrade data saved at
+// version to the current version, in the order they would run
+func PendingMigrations(version int) []string {
+	pending := []string{}
+	for from := version; from < EcsDataVersion; from++ {
+		if step, ok := migrations[from]; ok {
+			pending = append(pending, fmt.Sprintf("%d to %d: %s", from, from+1, step.description))
+		} else {
+			pending = append(pending, fmt.Sprintf("%d to %d: missing", from, from+1))
+		}
+	}
+	return pending
+}
@@ -0,0 +1,151 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+func TestMigrate(t *testing.T) {
+	var ran []int
+	step := func(version int) migration {
+		return migration{description: "test", migrate: func(data map[string]json.RawMessage) error {
+			ran = append(ran, version)
+			data["Version"] = json.RawMessage(strconv.Itoa(version + 1))
+			return nil
+		}}
+	}
+	steps := map[int]migration{1: step(1), 2: step(2)}
+
+	data := map[string]json.RawMessage{}
+	if err := migrate(steps, data, 1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 || ran[0] != 1 || ran[1] != 2 || string(data["Version"]) != "3" {
+		t.Error("Expected both migrations to run in order", ran, string(data["Version"]))
+	}
+
+	if err := migrate(steps, data, 0, 3); err == nil || !strings.Contains(err.Error(), "no migration from data version 0") {
+		t.Error("Expected a missing migration to be an error, got", err)
+	}
+
+	steps[2] = migration{description: "broken", migrate: func(map[string]json.RawMessage) error {
+		return errors.New("boom")
+	}}
+	if err := migrate(steps, data, 1, 3); err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Error("Expected the failed migration to be named, got", err)
+	}
+}
+
+// replaceMigration replaces the migration registered from version from, and
+// returns a function which restores it
+func replaceMigration(from int, description string, migrate func(data map[string]json.RawMessage) error) func() {
+	original, registered := migrations[from]
+	migrations[from] = migration{description: description, migrate: migrate}
+	return func() {
+		if registered {
+			migrations[from] = original
+		} else {
+			delete(migrations, from)
+		}
+	}
+}
+
+func TestLoadMigratesAndBacksUp(t *testing.T) {
+	// Pretend version 0 saved the cluster under another name
+	defer replaceMigration(0, "rename ClusterName to Cluster", func(data map[string]json.RawMessage) error {
+		data["Cluster"] = data["ClusterName"]
+		delete(data, "ClusterName")
+		return nil
+	})()
+
+	dir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dir)
+	old := []byte(`{"Data":{"ClusterName":"default"},"Version":0}`)
+	ioutil.WriteFile(DataFile(dir), old, 0600)
+
+	var cluster string
+	manager, _ := NewStateManager(&config.Config{DataDir: dir}, AddSaveable("Cluster", &cluster))
+	if err := manager.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if cluster != "default" {
+		t.Error("Expected the migrated cluster to be loaded, got", cluster)
+	}
+	backup, err := ioutil.ReadFile(filepath.Join(dir, ecsDataFile+".v0.bak"))
+	if err != nil || string(backup) != string(old) {
+		t.Error("Expected the original file to be backed up", err, string(backup))
+	}
+	if pending := PendingMigrations(0); len(pending) != EcsDataVersion || !strings.Contains(pending[0], "rename") {
+		t.Error("Unexpected pending migrations", pending)
+	}
+}
+
+func TestRawStateRoundTrip(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dir)
+	path := DataFile(dir)
+
+	if _, err := ReadRawState(path, nil); !os.IsNotExist(err) {
+		t.Error("Expected a missing state file to be reported as such, got", err)
+	}
+	if err := WriteRawState(path, &RawState{Version: EcsDataVersion - 1}, nil); err == nil {
+		t.Error("Expected an old data version not to be written")
+	}
+
+	written := &RawState{Data: map[string]json.RawMessage{"Cluster": json.RawMessage(`"default"`)}, Version: EcsDataVersion}
+	if err := WriteRawState(path, written, nil); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRawState(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Version != EcsDataVersion || string(read.Data["Cluster"]) != `"default"` {
+		t.Error("Unexpected state read back", read)
+	}
+
+	ioutil.WriteFile(path, []byte(`{"Data":{},"Version":1000}`), 0600)
+	if _, err := ReadRawState(path, nil); err == nil {
+		t.Error("Expected data from a newer agent to be refused")
+	}
+}
+
+func TestLockDataDir(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dir)
+
+	lock, err := LockDataDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockDataDir(dir); err != ErrDataDirLocked {
+		t.Error("Expected the data directory to be locked, got", err)
+	}
+	lock.Close()
+	lock, err = LockDataDir(dir)
+	if err != nil {
+		t.Error("Expected the lock to be released, got", err)
+	}
+	lock.Close()
+}
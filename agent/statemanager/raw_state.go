@@ -0,0 +1,252 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// RawState is saved state as it is on disk, before it is loaded into the
+// saveables: the JSON of each saveable by the name it was added with. It lets
+// tools read and edit a state file without the agent running.
+type RawState struct {
+	Data    map[string]json.RawMessage
+	Version int
+}
+
+// backupSuffix ends the name of a backup of the state file, which is kept as
+// ecs_agent_data.json.v<version>.bak
+const backupSuffix = ".bak"
+
+// DataFile returns the path of the state file in dataDir
+func DataFile(dataDir string) string {
+	return filepath.Join(dataDir, ecsDataFile)
+}
+
+// CorruptStateError is returned when a state file cannot be parsed or fails
+// its checksum, such as when it was truncated
+type CorruptStateError struct {
+	Path string
+	Err  error
+}
+
+func (err *CorruptStateError) Error() string {
+	return "corrupt state file " + err.Path + ": " + err.Err.Error()
+}
+
+// ReadRawState reads the state file at path, decrypting it with cipher if it
+// is encrypted. It returns an error satisfying os.IsNotExist if there is none,
+// a *CorruptStateError if it is corrupt, and refuses files saved by a newer
+// version of the agent.
+func ReadRawState(path string, cipher *Cipher) (*RawState, error) {
+	raw, _, err := readRawState(path, cipher)
+	return raw, err
+}
+
+// readRawState performs the work of ReadRawState, and also reports whether
+// the file was encrypted
+func readRawState(path string, cipher *Cipher) (*RawState, bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	var file stateFile
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		log.Crit("Could not unmarshal existing state; corrupted data?", "err", err)
+		return nil, false, &CorruptStateError{Path: path, Err: err}
+	}
+	if file.Version > EcsDataVersion {
+		strversion := strconv.Itoa(file.Version)
+		return nil, false, errors.New("Unsupported data format: Version " + strversion + " not " + strconv.Itoa(EcsDataVersion))
+	}
+	encrypted := len(file.Encrypted) > 0
+	if encrypted {
+		if cipher == nil {
+			return nil, true, ErrNoStateKey
+		}
+		plaintext, _, err := cipher.open(file.Encrypted, nil)
+		if err == errStateAuthentication {
+			return nil, true, &CorruptStateError{Path: path, Err: err}
+		}
+		if err != nil {
+			return nil, true, err
+		}
+		file = stateFile{}
+		if err := json.Unmarshal(plaintext, &file); err != nil {
+			return nil, true, &CorruptStateError{Path: path, Err: err}
+		}
+	}
+	if file.Checksum != "" {
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, file.Data); err != nil {
+			return nil, encrypted, &CorruptStateError{Path: path, Err: err}
+		}
+		if checksum(compacted.Bytes()) != file.Checksum {
+			return nil, encrypted, &CorruptStateError{Path: path, Err: errors.New("checksum mismatch")}
+		}
+	}
+
+	intermediate := make(intermediateSaveableState)
+	if len(file.Data) > 0 && string(file.Data) != "null" {
+		if err := json.Unmarshal(file.Data, &intermediate); err != nil {
+			log.Debug("Could not unmarshal into intermediate")
+			return nil, encrypted, &CorruptStateError{Path: path, Err: err}
+		}
+	}
+	return &RawState{Data: intermediate, Version: file.Version}, encrypted, nil
+}
+
+// checksum returns the hex encoded SHA-256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeStateFile returns the contents of a state file holding data, the
+// compact JSON of the saveables, encrypted with cipher if it is not nil
+func encodeStateFile(data []byte, version int, cipher *Cipher) ([]byte, error) {
+	contents, err := json.Marshal(stateFile{
+		Checksum: checksum(data),
+		Data:     data,
+		Version:  version,
+	})
+	if err != nil || cipher == nil {
+		return contents, err
+	}
+	return sealStateFile(contents, version, cipher)
+}
+
+// sealStateFile returns the contents of a state file holding contents, those
+// of a plaintext state file saved at version, encrypted with cipher
+func sealStateFile(contents []byte, version int, cipher *Cipher) ([]byte, error) {
+	sealed, err := cipher.seal(contents, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stateFile{Encrypted: sealed, Version: version})
+}
+
+// encryptStateFile returns data, the contents of a state file, encrypted with
+// cipher, and whether it had to encrypt them. If cipher is nil or the state
+// file is already encrypted, it returns data as it is.
+func encryptStateFile(data []byte, cipher *Cipher) ([]byte, bool, error) {
+	var file stateFile
+	// A corrupt state file may not unmarshal, but is encrypted all the same
+	json.Unmarshal(data, &file)
+	if cipher == nil || len(file.Encrypted) > 0 {
+		return data, false, nil
+	}
+	sealed, err := sealStateFile(data, file.Version, cipher)
+	if err != nil {
+		return nil, false, err
+	}
+	return sealed, true, nil
+}
+
+// Upgrade migrates the state to the current data version
+func (s *RawState) Upgrade() error {
+	if err := migrate(migrations, s.Data, s.Version, EcsDataVersion); err != nil {
+		return err
+	}
+	s.Version = EcsDataVersion
+	return nil
+}
+
+// WriteRawState atomically replaces the state file at path, encrypting it
+// with cipher if it is not nil. The state must be at the current data
+// version.
+func WriteRawState(path string, s *RawState, cipher *Cipher) error {
+	if s.Version != EcsDataVersion {
+		return errors.New("Refusing to write data version " + strconv.Itoa(s.Version) + "; upgrade it first")
+	}
+	data, err := json.Marshal(s.Data)
+	if err != nil {
+		return err
+	}
+	contents, err := encodeStateFile(data, s.Version, cipher)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, contents)
+}
+
+// writeFile atomically and durably replaces the file at path with data, by
+// renaming a temporary file written alongside it over it
+func writeFile(path string, data []byte) error {
+	// Make our temp-file on the same volume as our data-file to ensure we can
+	// actually move it atomically; cross-device renaming will error out.
+	tmpfile, err := ioutil.TempFile(filepath.Dir(path), "tmp_ecs_agent_data")
+	if err != nil {
+		log.Error("Error saving state; could not create temp file to save state", "err", err)
+		return err
+	}
+	_, err = tmpfile.Write(data)
+	if err == nil {
+		// Without this, a crash soon after the rename can leave the file
+		// renamed but empty or truncated
+		err = tmpfile.Sync()
+	}
+	if closeErr := tmpfile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Error("Error saving state; could not write to temp file to save state", "err", err)
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	err = os.Rename(tmpfile.Name(), path)
+	if err != nil {
+		log.Error("Error saving state; could not move to data file", "err", err)
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes a rename within dir durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// backupFile copies the state file read from source, either the state file at
+// path or one of its snapshots, to path.v<version>.bak, so that the data from
+// before a migration can be restored along with an older agent. If cipher is
+// set, the backup is encrypted with it.
+func backupFile(source, path string, version int, cipher *Cipher) error {
+	data, err := ioutil.ReadFile(source)
+	if err != nil {
+		return err
+	}
+	data, _, err = encryptStateFile(data, cipher)
+	if err != nil {
+		return err
+	}
+	backup := path + ".v" + strconv.Itoa(version) + backupSuffix
+	log.Info("Backing up state before migrating it", "backup", backup)
+	return ioutil.WriteFile(backup, data, 0600)
+}
@@ -0,0 +1,208 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
+)
+
+// A snapshot is a state file as it was before one of the most recent saves,
+// kept alongside it as ecs_agent_data.json.snapshot-<n>, newest first
+const snapshotInfix = ".snapshot-"
+
+// corruptSuffix is appended to the name of a corrupt state file once it has
+// been recovered from a snapshot
+const corruptSuffix = ".corrupt"
+
+var stateRecoveries = metrics.NewCounter("ecs_agent_state_recoveries_total",
+	"Corrupt state files recovered from a snapshot.")
+
+// Recovery describes the recovery of a corrupt state file from a snapshot
+type Recovery struct {
+	Time time.Time
+	// CorruptFile is where the corrupt state file was moved to
+	CorruptFile string
+	// Snapshot is the snapshot the state was recovered from
+	Snapshot string
+	// Error describes what was wrong with the state file
+	Error string
+}
+
+var lastRecovery struct {
+	sync.RWMutex
+	recovery *Recovery
+}
+
+// LastRecovery returns the most recent recovery of the state from a
+// snapshot, or nil if there has been none since the agent started
+func LastRecovery() *Recovery {
+	lastRecovery.RLock()
+	defer lastRecovery.RUnlock()
+	return lastRecovery.recovery
+}
+
+func snapshotFile(path string, n int) string {
+	return path + snapshotInfix + strconv.Itoa(n)
+}
+
+// listSnapshots returns the snapshots of the state file at path, newest first
+func listSnapshots(path string) []string {
+	matches, _ := filepath.Glob(path + snapshotInfix + "*")
+	numbers := []int{}
+	for _, match := range matches {
+		n, err := strconv.Atoi(strings.TrimPrefix(match, path+snapshotInfix))
+		if err == nil && n > 0 {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	snapshots := make([]string, 0, len(numbers))
+	for _, n := range numbers {
+		snapshots = append(snapshots, snapshotFile(path, n))
+	}
+	return snapshots
+}
+
+// rotateSnapshots keeps the state file at path, if it loads, as the newest of
+// up to keep snapshots. If cipher is set, it only keeps encrypted snapshots.
+// It must be called before the file is replaced.
+func rotateSnapshots(path string, keep int, cipher *Cipher) {
+	_, encrypted, err := readRawState(path, cipher)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Not keeping a snapshot of a state file which does not load", "err", err)
+		}
+		return
+	}
+	if cipher != nil && !encrypted {
+		return
+	}
+	for _, snapshot := range listSnapshots(path) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(snapshot, path+snapshotInfix))
+		if n >= keep {
+			os.Remove(snapshot)
+		}
+	}
+	for n := keep - 1; n >= 1; n-- {
+		err := os.Rename(snapshotFile(path, n), snapshotFile(path, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			log.Warn("Unable to rotate state snapshots", "err", err)
+		}
+	}
+	// The state file is about to be replaced by renaming over it, so a
+	// hard link to it is all a snapshot needs
+	if err := os.Link(path, snapshotFile(path, 1)); err != nil {
+		log.Warn("Unable to snapshot the state file", "err", err)
+	}
+}
+
+// removePlaintextSnapshots removes any snapshots of the state file at path
+// saved before it was encrypted
+func removePlaintextSnapshots(path string) {
+	for _, snapshot := range listSnapshots(path) {
+		if _, encrypted, err := readRawState(snapshot, nil); !encrypted && !os.IsNotExist(err) {
+			log.Info("Removing a state snapshot saved before encryption was enabled", "snapshot", snapshot)
+			os.Remove(snapshot)
+		}
+	}
+}
+
+// removePlaintextCopies removes any snapshots of the state file at path saved
+// before it was encrypted, and encrypts with cipher any other copies of it
+// kept aside: backups from before a migration, and corrupt or migrated state
+// files
+func removePlaintextCopies(path string, cipher *Cipher) {
+	removePlaintextSnapshots(path)
+	copies, _ := filepath.Glob(path + ".v*" + backupSuffix)
+	copies = append(copies, path+corruptSuffix, path+migratedSuffix)
+	for _, file := range copies {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				log.Warn("Unable to read a copy of the state file to encrypt it", "file", file, "err", err)
+			}
+			continue
+		}
+		sealed, encrypted, err := encryptStateFile(data, cipher)
+		if err == nil && encrypted {
+			log.Info("Encrypting a copy of the state file saved before encryption was enabled", "file", file)
+			err = writeFile(file, sealed)
+		}
+		if err != nil {
+			log.Warn("Unable to encrypt a copy of the state file", "file", file, "err", err)
+		}
+	}
+}
+
+// recoverState loads the newest snapshot of the corrupt state file at path
+// which loads with cipher, and moves the corrupt file aside so that it is not
+// loaded again. It returns the state and the snapshot it was read from, or the
+// error the file was corrupt with if no snapshot loads.
+func recoverState(path string, corruption error, cipher *Cipher) (*RawState, string, error) {
+	log.Crit("State file is corrupt; recovering it from a snapshot", "err", corruption)
+	for _, snapshot := range listSnapshots(path) {
+		raw, err := ReadRawState(snapshot, cipher)
+		if err != nil {
+			log.Warn("Unable to load state snapshot", "snapshot", snapshot, "err", err)
+			continue
+		}
+		corruptFile := path + corruptSuffix
+		if err := moveCorruptFile(path, corruptFile, cipher); err != nil {
+			return nil, "", err
+		}
+		recovery := &Recovery{
+			Time:        time.Now(),
+			CorruptFile: corruptFile,
+			Snapshot:    snapshot,
+			Error:       corruption.Error(),
+		}
+		lastRecovery.Lock()
+		lastRecovery.recovery = recovery
+		lastRecovery.Unlock()
+		stateRecoveries.Inc()
+		log.Crit("Recovered state from a snapshot; changes saved since it was taken are lost", "snapshot", snapshot, "corruptFile", corruptFile)
+		return raw, snapshot, nil
+	}
+	log.Crit("No snapshot of the corrupt state file loads", "file", path)
+	return nil, "", corruption
+}
+
+// moveCorruptFile moves the corrupt state file at path to corruptFile,
+// encrypting it with cipher if it is set and the file is not encrypted
+func moveCorruptFile(path, corruptFile string, cipher *Cipher) error {
+	if cipher == nil {
+		return os.Rename(path, corruptFile)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, _, err = encryptStateFile(data, cipher)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(corruptFile, data); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
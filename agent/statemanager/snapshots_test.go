@@ -0,0 +1,172 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package statemanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/config"
+)
+
+// newTestManager returns a state manager saving value to a temporary data
+// directory, and a function which removes the directory
+func newTestManager(t *testing.T, snapshots int, value *string) (StateManager, string, func()) {
+	dataDir, err := ioutil.TempDir("", "ecs_statemanager_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager, err := NewStateManager(&config.Config{DataDir: dataDir, StateSnapshots: snapshots}, AddSaveable("Value", value))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager, dataDir, func() { os.RemoveAll(dataDir) }
+}
+
+func TestChecksum(t *testing.T) {
+	value := "intact"
+	manager, dataDir, cleanup := newTestManager(t, 0, &value)
+	defer cleanup()
+	if err := manager.ForceSave(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := DataFile(dataDir)
+	if _, err := ReadRawState(path, nil); err != nil {
+		t.Fatal("Expected the saved state to read", err)
+	}
+	data, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, bytes.Replace(data, []byte("intact"), []byte("flip!!"), 1), 0600)
+	if _, err := ReadRawState(path, nil); err == nil {
+		t.Error("Expected a change to the data to fail the checksum")
+	} else if _, ok := err.(*CorruptStateError); !ok {
+		t.Error("Expected a CorruptStateError, got", err)
+	}
+}
+
+func TestSnapshotsRotate(t *testing.T) {
+	var value string
+	manager, dataDir, cleanup := newTestManager(t, 2, &value)
+	defer cleanup()
+	for _, value = range []string{"first", "second", "third", "fourth"} {
+		if err := manager.ForceSave(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := DataFile(dataDir)
+	snapshots := listSnapshots(path)
+	if len(snapshots) != 2 {
+		t.Fatal("Expected 2 snapshots, got", snapshots)
+	}
+	for i, expected := range []string{`"third"`, `"second"`} {
+		raw, err := ReadRawState(snapshots[i], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw.Data["Value"]) != expected {
+			t.Errorf("Expected snapshot %s to be %s, got %s", snapshots[i], expected, raw.Data["Value"])
+		}
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	value := "value"
+	dataDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+	defer os.RemoveAll(dataDir)
+	key := base64.StdEncoding.EncodeToString(testKey(1))
+	manager, err := NewStateManager(&config.Config{DataDir: dataDir, StateSnapshots: 3, StateEncryptionKey: key}, AddSaveable("Value", &value))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shutdown's ForceSave may overlap a throttled Save
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- manager.ForceSave()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error("Expected concurrent saves to succeed, got", err)
+		}
+	}
+	cipher, _ := NewCipher([][]byte{testKey(1)})
+	snapshots := listSnapshots(DataFile(dataDir))
+	if len(snapshots) != 3 {
+		t.Fatal("Expected 3 snapshots, got", snapshots)
+	}
+	for _, snapshot := range snapshots {
+		if _, err := ReadRawState(snapshot, cipher); err != nil {
+			t.Error("Expected snapshot to load", snapshot, err)
+		}
+	}
+}
+
+func TestRecoverFromSnapshot(t *testing.T) {
+	defer func() { lastRecovery.recovery = nil }()
+	var value string
+	manager, dataDir, cleanup := newTestManager(t, 3, &value)
+	defer cleanup()
+	for _, value = range []string{"older", "newer", "latest"} {
+		manager.ForceSave()
+	}
+
+	// Corrupt the newest snapshot too, so that the one before it is used
+	path := DataFile(dataDir)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()/2)
+	os.Truncate(snapshotFile(path, 1), 10)
+
+	var loaded string
+	manager, _ = NewStateManager(&config.Config{DataDir: dataDir}, AddSaveable("Value", &loaded))
+	if err := manager.Load(); err != nil {
+		t.Fatal("Expected the state to be recovered", err)
+	}
+	if loaded != "older" {
+		t.Error("Expected the state from the newest snapshot which loads, got", loaded)
+	}
+	if _, err := os.Stat(path + corruptSuffix); err != nil {
+		t.Error("Expected the corrupt state file to be kept", err)
+	}
+	recovery := LastRecovery()
+	if recovery == nil || recovery.Snapshot != snapshotFile(path, 2) || recovery.CorruptFile != path+corruptSuffix {
+		t.Errorf("Unexpected recovery %+v", recovery)
+	}
+}
+
+func TestCorruptWithoutSnapshots(t *testing.T) {
+	value := "value"
+	manager, dataDir, cleanup := newTestManager(t, 0, &value)
+	defer cleanup()
+	manager.ForceSave()
+	ioutil.WriteFile(DataFile(dataDir), []byte(`{"Data":{"Val`), 0600)
+
+	if err := manager.Load(); err == nil {
+		t.Error("Expected an error loading a corrupt state file without snapshots")
+	}
+	if LastRecovery() != nil {
+		t.Error("Expected no recovery")
+	}
+}
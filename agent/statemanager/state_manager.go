@@ -20,15 +20,13 @@ package statemanager
 import (
 	"encoding/json"
 	"errors"
-	"io/ioutil"
 	"os"
-	"path/filepath"
-	"strconv"
 	"sync"
 	"time"
 
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 )
 
 // The current version of saved data. Any backwards or forwards incompatible
@@ -39,6 +37,13 @@ const EcsDataVersion = 1
 // Filename in the ECS_DATADIR
 const ecsDataFile = "ecs_agent_data.json"
 
+var (
+	saveSeconds = metrics.NewHistogram("ecs_agent_state_save_seconds",
+		"Time taken to write the agent's state to disk.", metrics.DefBuckets)
+	saveFailures = metrics.NewCounter("ecs_agent_state_save_failures_total",
+		"Failed attempts to write the agent's state to disk.")
+)
+
 // How frequently to flush to disk
 const minSaveInterval = 10 * time.Second
 
@@ -75,12 +80,16 @@ type state struct {
 	Version int
 }
 
-type intermediateState struct {
-	Data intermediateSaveableState
-}
-
-type versionOnlyState struct {
-	Version int
+// stateFile is the state file as it is on disk. Checksum is the hex encoded
+// SHA-256 of the compacted Data, and is absent from files saved by older
+// agents.
+type stateFile struct {
+	Checksum string          `json:",omitempty"`
+	Data     json.RawMessage `json:",omitempty"`
+	// Encrypted, if set, is the state file sealed by a Cipher, in place of
+	// Checksum and Data
+	Encrypted []byte `json:",omitempty"`
+	Version   int
 }
 
 // A StateManager can load and save state from disk.
@@ -90,11 +99,31 @@ type StateManager interface {
 	Load() error
 }
 
+// A RecordSaveable is a Saveable which can also be saved as a set of separate
+// records, so that a StateManager which supports it need only rewrite the
+// records which have changed. Each record is keyed by a string unique within
+// the Saveable.
+type RecordSaveable interface {
+	SaveRecords() (map[string]json.RawMessage, error)
+	LoadRecords(records map[string]json.RawMessage) error
+}
+
+// saveableAdder is implemented by StateManagers which AddSaveable works with
+type saveableAdder interface {
+	addSaveable(name string, saveable Saveable)
+}
+
 type basicStateManager struct {
 	statePath string // The path to a file in which state can be serialized
 
 	state *state // pointers to the data we should save / load into
 
+	snapshots int     // how many snapshots of the state file to keep
+	cipher    *Cipher // encrypts the state file, if configured
+
+	saveLock         sync.Mutex // serializes writing the state file and its snapshots
+	removedPlaintext bool       // whether copies of the state file saved before encryption was enabled have been encrypted or removed
+
 	sync.Mutex                // guards save times
 	lastSave        time.Time //the last time a save completed
 	nextPlannedSave time.Time //the next time a save is planned
@@ -105,21 +134,23 @@ type basicStateManager struct {
 // The returned StateManager will not save more often than every 10 seconds and
 // will not reliably return errors with Save, but will log them appropriately.
 func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error) {
-	fi, err := os.Stat(cfg.DataDir)
-	if err != nil {
+	if err := checkDataDir(cfg.DataDir); err != nil {
 		return nil, err
 	}
-	if !fi.IsDir() {
-		return nil, errors.New("State manager DataDir must exist")
-	}
 
 	state := &state{
 		Data:    make(saveableState),
 		Version: EcsDataVersion,
 	}
+	cipher, err := CipherFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
 	manager := &basicStateManager{
 		statePath: cfg.DataDir,
 		state:     state,
+		snapshots: cfg.StateSnapshots,
+		cipher:    cipher,
 	}
 
 	for _, option := range options {
@@ -129,21 +160,36 @@ func NewStateManager(cfg *config.Config, options ...Option) (StateManager, error
 	return manager, nil
 }
 
+func checkDataDir(dataDir string) error {
+	fi, err := os.Stat(dataDir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("State manager DataDir must exist")
+	}
+	return nil
+}
+
 // AddSaveable is an option that adds a given saveable as one that should be saved
 // under the given name. The name must be the same across uses of the
 // statemanager (e.g. program invocations) for it to be serialized and
 // deserialized correctly.
 func AddSaveable(name string, saveable Saveable) Option {
 	return (Option)(func(m StateManager) {
-		manager, ok := m.(*basicStateManager)
+		manager, ok := m.(saveableAdder)
 		if !ok {
 			log.Crit("Unable to add to state manager; unknown instantiation")
 			return
 		}
-		manager.state.Data[name] = &saveable
+		manager.addSaveable(name, saveable)
 	})
 }
 
+func (manager *basicStateManager) addSaveable(name string, saveable Saveable) {
+	manager.state.Data[name] = &saveable
+}
+
 // Save triggers a save to file, though respects a minimum save interval to wait
 // between saves.
 func (manager *basicStateManager) Save() error {
@@ -177,32 +223,44 @@ func (manager *basicStateManager) Save() error {
 // In addition, the StateManager internally buffers save requests in order to
 // only save at most every STATE_SAVE_INTERVAL.
 func (manager *basicStateManager) ForceSave() error {
+	start := time.Now()
+	err := manager.writeState()
+	saveSeconds.Since(start)
+	if err != nil {
+		saveFailures.Inc()
+	}
+	return err
+}
+
+// writeState performs the work of ForceSave
+func (manager *basicStateManager) writeState() error {
+	manager.saveLock.Lock()
+	defer manager.saveLock.Unlock()
 	log.Info("Saving state!")
 	s := manager.state
 	s.Version = EcsDataVersion
 
-	data, err := json.Marshal(s)
+	data, err := json.Marshal(s.Data)
 	if err != nil {
 		log.Error("Error saving state; could not marshal data; this is odd", "err", err)
 		return err
 	}
-	// Make our temp-file on the same volume as our data-file to ensure we can
-	// actually move it atomically; cross-device renaming will error out.
-	tmpfile, err := ioutil.TempFile(manager.statePath, "tmp_ecs_agent_data")
+	contents, err := encodeStateFile(data, s.Version, manager.cipher)
 	if err != nil {
-		log.Error("Error saving state; could not create temp file to save state", "err", err)
+		log.Error("Error saving state; could not encrypt data", "err", err)
 		return err
 	}
-	_, err = tmpfile.Write(data)
-	if err != nil {
-		log.Error("Error saving state; could not write to temp file to save state", "err", err)
-		return err
+	path := DataFile(manager.statePath)
+	if manager.cipher != nil && !manager.removedPlaintext {
+		removePlaintextCopies(path, manager.cipher)
+		manager.removedPlaintext = true
 	}
-	err = os.Rename(tmpfile.Name(), filepath.Join(manager.statePath, ecsDataFile))
-	if err != nil {
-		log.Error("Error saving state; could not move to data file", "err", err)
+	if manager.snapshots > 0 {
+		// A failure to snapshot is logged, but does not keep the state
+		// from being saved
+		rotateSnapshots(path, manager.snapshots, manager.cipher)
 	}
-	return err
+	return writeFile(path, contents)
 }
 
 // Load reads state off the disk from the well-known filepath and loads it into
@@ -213,7 +271,7 @@ func (manager *basicStateManager) Load() error {
 	// needed (given Linux and the ext* family of fs at least).
 	s := manager.state
 	log.Info("Loading state!")
-	file, err := os.Open(filepath.Join(manager.statePath, ecsDataFile))
+	raw, err := readStateFile(DataFile(manager.statePath), manager.cipher)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Happens every first run; not a real error
@@ -221,49 +279,62 @@ func (manager *basicStateManager) Load() error {
 		}
 		return err
 	}
-	data, err := ioutil.ReadAll(file)
-	if err != nil {
-		log.Error("Error reading existing state file", "err", err)
-		return err
-	}
-	// Dry-run to make sure this is a version we can understand
-	tmps := versionOnlyState{}
-	err = json.Unmarshal(data, &tmps)
-	if err != nil {
-		log.Crit("Could not unmarshal existing state; corrupted data?", "err", err, "data", data)
+
+	if err := loadSaveables(manager.state.Data, raw.Data); err != nil {
 		return err
 	}
-	if tmps.Version > EcsDataVersion {
-		strversion := strconv.Itoa(tmps.Version)
-		return errors.New("Unsupported data format: Version " + strversion + " not " + strconv.Itoa(EcsDataVersion))
+
+	log.Debug("Loaded state!", "state", s)
+	return nil
+}
+
+// readStateFile reads the state file at path, decrypting it with cipher,
+// backing it up and migrating it if it was saved by an older agent, or
+// recovering it from a snapshot if it is corrupt
+func readStateFile(path string, cipher *Cipher) (*RawState, error) {
+	source := path
+	raw, err := ReadRawState(path, cipher)
+	if _, ok := err.(*CorruptStateError); ok {
+		raw, source, err = recoverState(path, err, cipher)
 	}
-	// Now load it into the actual state. The reason we do this with the
-	// intermediate state is that we *must* unmarshal directly into the
-	// "saveable" pointers we were given in AddSaveable; if we unmarshal
-	// directly into a map with values of pointers, those pointers are lost.
-	// We *must* unmarshal this way because the existing pointers could have
-	// semi-initialized data (and are actually expected to)
-
-	var intermediate intermediateState
-	err = json.Unmarshal(data, &intermediate)
 	if err != nil {
-		log.Debug("Could not unmarshal into intermediate")
-		return err
+		if !os.IsNotExist(err) {
+			log.Error("Error reading existing state file", "err", err)
+		}
+		return nil, err
 	}
+	if raw.Version < EcsDataVersion {
+		if err := backupFile(source, path, raw.Version, cipher); err != nil {
+			log.Error("Unable to back up state before migrating it", "err", err)
+			return nil, err
+		}
+		if err := raw.Upgrade(); err != nil {
+			log.Crit("Unable to migrate state", "err", err)
+			return nil, err
+		}
+	}
+	return raw, nil
+}
 
-	for key, rawJSON := range intermediate.Data {
-		actualPointer, ok := manager.state.Data[key]
+// loadSaveables unmarshals each saveable's data into it.
+func loadSaveables(saveables saveableState, data map[string]json.RawMessage) error {
+	// The reason we do this with the intermediate state is that we *must*
+	// unmarshal directly into the "saveable" pointers we were given in
+	// AddSaveable; if we unmarshal directly into a map with values of
+	// pointers, those pointers are lost. We *must* unmarshal this way because
+	// the existing pointers could have semi-initialized data (and are
+	// actually expected to)
+	for key, rawJSON := range data {
+		actualPointer, ok := saveables[key]
 		if !ok {
 			log.Error("Loading state: potentially malformed json key of " + key)
 			continue
 		}
-		err = json.Unmarshal(rawJSON, actualPointer)
+		err := json.Unmarshal(rawJSON, actualPointer)
 		if err != nil {
 			log.Debug("Could not unmarshal into actual")
 			return err
 		}
 	}
-
-	log.Debug("Loaded state!", "state", s)
 	return nil
 }
@@ -16,6 +16,7 @@ package statemanager_test
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/aws/amazon-ecs-agent/agent/api"
@@ -107,3 +108,40 @@ func TestStateManagerNonexistantDirectory(t *testing.T) {
 		t.Fatal("State manager should not load if the directory doesn't exist")
 	}
 }
+
+// TestMigrateFixtures loads a state file saved by each data version, which
+// must all still load once migrated
+func TestMigrateFixtures(t *testing.T) {
+	fixtures, _ := filepath.Glob("testdata/ecs_agent_data_v*.json")
+	if len(fixtures) != statemanager.EcsDataVersion+1 {
+		t.Errorf("Expected a fixture for each of the %d data versions, found %v", statemanager.EcsDataVersion+1, fixtures)
+	}
+	for _, fixture := range fixtures {
+		data, err := ioutil.ReadFile(fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpDir, _ := ioutil.TempDir("", "ecs_statemanager_test")
+		defer os.RemoveAll(tmpDir)
+		ioutil.WriteFile(statemanager.DataFile(tmpDir), data, 0600)
+
+		taskEngine := engine.NewTaskEngine(&config.Config{})
+		var containerInstanceArn, cluster, ec2InstanceID string
+		var instanceAttributes map[string]string
+		manager, _ := statemanager.NewStateManager(&config.Config{DataDir: tmpDir},
+			statemanager.AddSaveable("TaskEngine", taskEngine),
+			statemanager.AddSaveable("ContainerInstanceArn", &containerInstanceArn),
+			statemanager.AddSaveable("Cluster", &cluster),
+			statemanager.AddSaveable("EC2InstanceID", &ec2InstanceID),
+			statemanager.AddSaveable("InstanceAttributes", &instanceAttributes),
+		)
+		if err := manager.Load(); err != nil {
+			t.Error("Unable to load", fixture, err)
+			continue
+		}
+		tasks, _ := taskEngine.ListTasks()
+		if len(tasks) != 2 || cluster != "default" || containerInstanceArn == "" || instanceAttributes["stack"] != "prod" {
+			t.Error("Unexpected state loaded from", fixture, tasks, cluster, containerInstanceArn, instanceAttributes)
+		}
+	}
+}
@@ -18,6 +18,8 @@
 package stats
 
 import (
+	"io"
+
 	gomock "code.google.com/p/gomock/gomock"
 	api "github.com/aws/amazon-ecs-agent/agent/api"
 	"github.com/aws/amazon-ecs-agent/agent/engine"
@@ -77,6 +79,16 @@ func (_mr *_MockDockerClientRecorder) PullImage(arg0 interface{}) *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "PullImage", arg0)
 }
 
+func (_m *MockDockerClient) ContainerLogs(_param0 string, _param1 int, _param2 bool, _param3 io.Writer) error {
+	ret := _m.ctrl.Call(_m, "ContainerLogs", _param0, _param1, _param2, _param3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+func (_mr *_MockDockerClientRecorder) ContainerLogs(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "ContainerLogs", arg0, arg1, arg2, arg3)
+}
+
 func (_m *MockDockerClient) CreateContainer(_param0 *go_dockerclient.Config, _param1 string) (string, error) {
 	ret := _m.ctrl.Call(_m, "CreateContainer", _param0, _param1)
 	ret0, _ := ret[0].(string)
@@ -172,3 +184,14 @@ func (_m *MockDockerClient) Version() (string, error) {
 func (_mr *_MockDockerClientRecorder) Version() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "Version")
 }
+
+func (_m *MockDockerClient) Info() (*go_dockerclient.Env, error) {
+	ret := _m.ctrl.Call(_m, "Info")
+	ret0, _ := ret[0].(*go_dockerclient.Env)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+func (_mr *_MockDockerClientRecorder) Info() *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "Info")
+}
@@ -18,6 +18,7 @@ package stats
 import (
 	"errors"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -52,6 +53,7 @@ type DockerContainerMetadataResolver struct {
 // defined to make testing easier.
 type Engine interface {
 	GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.TaskMetric, error)
+	GetTaskUsage(taskArn string, numSamples int) []TaskUsage
 }
 
 // DockerStatsEngine is used to monitor docker container events and to report
@@ -67,6 +69,9 @@ type DockerStatsEngine struct {
 	tasksToContainers map[string]map[string]*CronContainer
 	// tasksToDefinitions maps task arns to task definiton name and family metadata objects.
 	tasksToDefinitions map[string]*taskDefinition
+	// initialized is set once MustInit has succeeded; until then no
+	// containers are watched
+	initialized bool
 }
 
 // dockerStatsEngine is a singleton object of DockerStatsEngine.
@@ -141,7 +146,17 @@ func (engine *DockerStatsEngine) MustInit(taskEngine ecsengine.TaskEngine, md *e
 		return err
 	}
 
-	return engine.Init()
+	err = engine.Init()
+	if err != nil {
+		return err
+	}
+	engine.initialized = true
+	return nil
+}
+
+// Initialized returns true once MustInit has succeeded
+func (engine *DockerStatsEngine) Initialized() bool {
+	return engine.initialized
 }
 
 // Init initializes the docker client's event engine. This must be called
@@ -314,6 +329,52 @@ func (engine *DockerStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata,
 	return engine.metricsMetadata, taskMetrics, nil
 }
 
+// GetTaskUsage returns the utilization of the containers being watched,
+// grouped by task and sorted by task arn and then container name. If taskArn is
+// not empty, only that task is returned. At most numSamples of the most recent
+// samples are returned for each container.
+func (engine *DockerStatsEngine) GetTaskUsage(taskArn string, numSamples int) []TaskUsage {
+	engine.containersLock.RLock()
+	defer engine.containersLock.RUnlock()
+
+	taskArns := make([]string, 0, len(engine.tasksToContainers))
+	for arn := range engine.tasksToContainers {
+		if taskArn == "" || arn == taskArn {
+			taskArns = append(taskArns, arn)
+		}
+	}
+	sort.Strings(taskArns)
+
+	usage := make([]TaskUsage, 0, len(taskArns))
+	for _, arn := range taskArns {
+		taskUsage := TaskUsage{TaskArn: arn}
+		if taskDef, exists := engine.tasksToDefinitions[arn]; exists {
+			taskUsage.Family = taskDef.family
+			taskUsage.Version = taskDef.version
+		}
+		for _, container := range engine.tasksToContainers[arn] {
+			containerUsage := ContainerUsage{
+				DockerID:   *container.containerMetadata.DockerID,
+				DockerName: *container.containerMetadata.Name,
+			}
+			// Errors only mean that there is no data yet
+			containerUsage.Samples, _ = container.statsQueue.GetRawUsageStats(numSamples)
+			containerUsage.CPU, _ = container.statsQueue.GetCPUStatsSet()
+			containerUsage.Memory, _ = container.statsQueue.GetMemoryStatsSet()
+			taskUsage.Containers = append(taskUsage.Containers, containerUsage)
+		}
+		sort.Sort(byDockerName(taskUsage.Containers))
+		usage = append(usage, taskUsage)
+	}
+	return usage
+}
+
+type byDockerName []ContainerUsage
+
+func (c byDockerName) Len() int           { return len(c) }
+func (c byDockerName) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+func (c byDockerName) Less(i, j int) bool { return c[i].DockerName < c[j].DockerName }
+
 func (engine *DockerStatsEngine) isIdle() bool {
 	return len(engine.tasksToContainers) == 0
 }
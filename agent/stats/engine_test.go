@@ -24,6 +24,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/api"
 	ecsengine "github.com/aws/amazon-ecs-agent/agent/engine"
+	"github.com/aws/amazon-ecs-agent/agent/engine/dockerstate"
 	"github.com/aws/amazon-ecs-agent/agent/statemanager"
 	mock_resolver "github.com/aws/amazon-ecs-agent/agent/stats/resolver/mock"
 )
@@ -52,6 +53,9 @@ func (engine *MockTaskEngine) TaskEvents() <-chan api.ContainerStateChange {
 func (engine *MockTaskEngine) SetSaver(statemanager.Saver) {
 }
 
+func (engine *MockTaskEngine) SetContainerInstanceArn(string) {
+}
+
 func (engine *MockTaskEngine) AddTask(*api.Task) error {
 	return nil
 }
@@ -60,6 +64,10 @@ func (engine *MockTaskEngine) ListTasks() ([]*api.Task, error) {
 	return nil, nil
 }
 
+func (engine *MockTaskEngine) TaskState() dockerstate.TaskEngineState {
+	return nil
+}
+
 func (engine *MockTaskEngine) UnmarshalJSON([]byte) error {
 	return nil
 }
@@ -374,3 +382,63 @@ func TestStatsEngineDisabledEnvVar(t *testing.T) {
 		t.Error("Stats engine enabled when ECS_DISABLE_METRICS is true")
 	}
 }
+
+func TestStatsEngineTaskUsage(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	resolver := mock_resolver.NewMockContainerMetadataResolver(mockCtrl)
+	t1 := &api.Task{Arn: "t1", Family: "f1", Version: "1"}
+	t2 := &api.Task{Arn: "t2", Family: "f2", Version: "3"}
+	resolver.EXPECT().ResolveTask("c1").AnyTimes().Return(t1, nil)
+	resolver.EXPECT().ResolveName("c1").AnyTimes().Return("n-c1", nil)
+	resolver.EXPECT().ResolveTask("c2").AnyTimes().Return(t2, nil)
+	resolver.EXPECT().ResolveName("c2").AnyTimes().Return("n-c2", nil)
+
+	engine := NewDockerStatsEngine()
+	engine.resolver = resolver
+	engine.AddContainer("c1")
+	engine.AddContainer("c2")
+	defer engine.RemoveContainer("c1")
+	defer engine.RemoveContainer("c2")
+	containerStats := []*ContainerStats{
+		CreateContainerStats(22400432, 1839104, ParseNanoTime("2015-02-12T21:22:05.131117533Z")),
+		CreateContainerStats(116499979, 3649536, ParseNanoTime("2015-02-12T21:22:05.232291187Z")),
+		CreateContainerStats(248503503, 3649536, ParseNanoTime("2015-02-12T21:22:05.333776335Z")),
+	}
+	for _, stat := range containerStats {
+		engine.tasksToContainers["t1"]["c1"].statsQueue.Add(stat)
+	}
+
+	usage := engine.GetTaskUsage("", 2)
+	if len(usage) != 2 || usage[0].TaskArn != "t1" || usage[1].TaskArn != "t2" {
+		t.Fatal("Expected usage for t1 and t2, got: ", usage)
+	}
+	if usage[0].Family != "f1" || usage[0].Version != "1" {
+		t.Error("Incorrect task definition: ", usage[0].Family, usage[0].Version)
+	}
+	if len(usage[0].Containers) != 1 || usage[0].Containers[0].DockerName != "n-c1" {
+		t.Fatal("Expected usage for n-c1, got: ", usage[0].Containers)
+	}
+	c1 := usage[0].Containers[0]
+	if len(c1.Samples) != 2 {
+		t.Fatal("Expected the 2 most recent samples, got: ", len(c1.Samples))
+	}
+	if !c1.Samples[0].Timestamp.After(c1.Samples[1].Timestamp) {
+		t.Error("Expected samples to be newest first")
+	}
+	if c1.CPU == nil || c1.Memory == nil || *c1.Memory.SampleCount != 3 {
+		t.Error("Expected aggregated stats over all samples, got: ", c1.CPU, c1.Memory)
+	}
+	c2 := usage[1].Containers[0]
+	if len(c2.Samples) != 0 || c2.CPU != nil || c2.Memory != nil {
+		t.Error("Expected no usage for a container without samples, got: ", c2)
+	}
+
+	usage = engine.GetTaskUsage("t2", 2)
+	if len(usage) != 1 || usage[0].TaskArn != "t2" {
+		t.Error("Expected only t2, got: ", usage)
+	}
+	if usage = engine.GetTaskUsage("t3", 2); len(usage) != 0 {
+		t.Error("Expected no usage for an unknown task, got: ", usage)
+	}
+}
@@ -18,6 +18,7 @@ package mock_stats
 
 import (
 	ecstcs "github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
+	stats "github.com/aws/amazon-ecs-agent/agent/stats"
 	gomock "code.google.com/p/gomock/gomock"
 )
 
@@ -53,3 +54,13 @@ func (_m *MockEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []*ecstcs.T
 func (_mr *_MockEngineRecorder) GetInstanceMetrics() *gomock.Call {
 	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetInstanceMetrics")
 }
+
+func (_m *MockEngine) GetTaskUsage(taskArn string, numSamples int) []stats.TaskUsage {
+	ret := _m.ctrl.Call(_m, "GetTaskUsage", taskArn, numSamples)
+	ret0, _ := ret[0].([]stats.TaskUsage)
+	return ret0
+}
+
+func (_mr *_MockEngineRecorder) GetTaskUsage(arg0, arg1 interface{}) *gomock.Call {
+	return _mr.mock.ctrl.RecordCall(_mr.mock, "GetTaskUsage", arg0, arg1)
+}
@@ -16,6 +16,7 @@ package stats
 import (
 	"time"
 
+	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
 	"golang.org/x/net/context"
 )
 
@@ -55,3 +56,22 @@ type taskDefinition struct {
 	family  string
 	version string
 }
+
+// TaskUsage is the recent resource utilization of the containers of a task.
+type TaskUsage struct {
+	TaskArn    string
+	Family     string
+	Version    string
+	Containers []ContainerUsage
+}
+
+// ContainerUsage is the recent resource utilization of a container. Samples
+// are in descending order of timestamps. CPU and Memory aggregate every sample
+// held for the container and are nil until there are enough to do so.
+type ContainerUsage struct {
+	DockerID   string
+	DockerName string
+	Samples    []UsageStats
+	CPU        *ecstcs.CWStatsSet
+	Memory     *ecstcs.CWStatsSet
+}
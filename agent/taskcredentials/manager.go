@@ -0,0 +1,200 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+// Package taskcredentials fetches and caches AWS credentials for the IAM roles
+// of tasks, so that they can be served to each task's containers in place of
+// the instance's own credentials.
+package taskcredentials
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/logger"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+var log = logger.ForModule("taskcredentials")
+
+const (
+	// refreshWindow is how long before they expire credentials are refreshed
+	refreshWindow = 15 * time.Minute
+	// refreshInterval is how often credentials are checked for expiry
+	refreshInterval = time.Minute
+
+	maxSessionNameLength = 64
+)
+
+// TaskCredentials are temporary credentials for a task's role
+type TaskCredentials struct {
+	RoleArn         string
+	AccessKeyId     string
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+// Source obtains credentials for a role. The default Source assumes the role
+// with STS; others may be substituted, for example in tests.
+type Source interface {
+	AssumeRole(roleArn, sessionName string) (*TaskCredentials, error)
+}
+
+type entry struct {
+	taskArn     string
+	roleArn     string
+	credentials *TaskCredentials
+}
+
+// Manager caches the credentials of each running task which has a role, keyed
+// by a credentials id which is given only to that task's containers
+type Manager struct {
+	source Source
+
+	entries map[string]*entry
+	// fetching counts the fetches in flight for each id, and removed records
+	// which of those ids were removed while they were fetched, so that the
+	// credentials of a task which has stopped are not cached again
+	fetching map[string]int
+	removed  map[string]bool
+	lock     sync.RWMutex
+}
+
+// NewManager creates a Manager which obtains credentials from source
+func NewManager(source Source) *Manager {
+	return &Manager{
+		source:   source,
+		entries:  make(map[string]*entry),
+		fetching: make(map[string]int),
+		removed:  make(map[string]bool),
+	}
+}
+
+// Ensure makes sure fresh credentials for the given task's role are cached
+// under id, fetching them if they are missing or about to expire
+func (manager *Manager) Ensure(id, taskArn, roleArn string) error {
+	if id == "" || roleArn == "" {
+		return errors.New("A credentials id and role are required")
+	}
+	manager.lock.RLock()
+	existing, ok := manager.entries[id]
+	manager.lock.RUnlock()
+	if ok && existing.roleArn == roleArn && !needsRefresh(existing.credentials) {
+		return nil
+	}
+	return manager.fetch(id, taskArn, roleArn, false)
+}
+
+// Get returns the unexpired credentials cached under id
+func (manager *Manager) Get(id string) (*TaskCredentials, bool) {
+	manager.lock.RLock()
+	defer manager.lock.RUnlock()
+	cached, ok := manager.entries[id]
+	if !ok || !ttime.Now().Before(cached.credentials.Expiration) {
+		return nil, false
+	}
+	credentials := *cached.credentials
+	return &credentials, true
+}
+
+// Remove forgets the credentials cached under id, once their task has stopped
+func (manager *Manager) Remove(id string) {
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	if cached, ok := manager.entries[id]; ok {
+		log.Info("Removing task credentials", "task", cached.taskArn)
+		delete(manager.entries, id)
+	}
+	if manager.fetching[id] > 0 {
+		manager.removed[id] = true
+	}
+}
+
+// Run refreshes cached credentials shortly before they expire. It does not
+// return.
+func (manager *Manager) Run() {
+	for {
+		ttime.Sleep(refreshInterval)
+		manager.refreshExpiring()
+	}
+}
+
+func (manager *Manager) refreshExpiring() {
+	manager.lock.RLock()
+	expiring := make(map[string]entry)
+	for id, cached := range manager.entries {
+		if needsRefresh(cached.credentials) {
+			expiring[id] = *cached
+		}
+	}
+	manager.lock.RUnlock()
+
+	for id, cached := range expiring {
+		// Failures are logged by fetch; the old credentials remain usable
+		// until they expire and are retried until then
+		manager.fetch(id, cached.taskArn, cached.roleArn, true)
+	}
+}
+
+// fetch gets credentials for the given task's role and caches them under id,
+// unless the id is removed meanwhile. A refresh only replaces credentials
+// which are still cached for the same task and role.
+func (manager *Manager) fetch(id, taskArn, roleArn string, refresh bool) error {
+	manager.lock.Lock()
+	manager.fetching[id]++
+	manager.lock.Unlock()
+
+	credentials, err := manager.source.AssumeRole(roleArn, sessionName(taskArn))
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+	removed := manager.removed[id]
+	manager.fetching[id]--
+	if manager.fetching[id] == 0 {
+		delete(manager.fetching, id)
+		delete(manager.removed, id)
+	}
+	if err != nil {
+		log.Warn("Unable to get task credentials", "task", taskArn, "role", roleArn, "err", err)
+		return err
+	}
+	if existing, ok := manager.entries[id]; removed || refresh && (!ok || existing.taskArn != taskArn || existing.roleArn != roleArn) {
+		log.Info("Discarding credentials for a removed task", "task", taskArn, "role", roleArn)
+		return nil
+	}
+	credentials.RoleArn = roleArn
+	manager.entries[id] = &entry{taskArn: taskArn, roleArn: roleArn, credentials: credentials}
+	log.Info("Refreshed task credentials", "task", taskArn, "role", roleArn, "expiration", credentials.Expiration)
+	return nil
+}
+
+func needsRefresh(credentials *TaskCredentials) bool {
+	return ttime.Now().Add(refreshWindow).After(credentials.Expiration)
+}
+
+// sessionName names the role session after the task, so that its calls can
+// be told apart in CloudTrail
+func sessionName(taskArn string) string {
+	name := "ecs-task"
+	for i := len(taskArn) - 1; i >= 0; i-- {
+		if taskArn[i] == '/' {
+			name += "-" + taskArn[i+1:]
+			break
+		}
+	}
+	if len(name) > maxSessionNameLength {
+		name = name[:maxSessionNameLength]
+	}
+	return name
+}
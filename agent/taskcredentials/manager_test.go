@@ -0,0 +1,197 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package taskcredentials
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/utils/ttime"
+)
+
+// localSource hands out numbered credentials without calling AWS
+type localSource struct {
+	calls    int
+	validFor time.Duration
+	err      error
+}
+
+func (source *localSource) AssumeRole(roleArn, sessionName string) (*TaskCredentials, error) {
+	if source.err != nil {
+		return nil, source.err
+	}
+	source.calls++
+	return &TaskCredentials{
+		AccessKeyId:     "AKID" + strconv.Itoa(source.calls),
+		SecretAccessKey: "secret",
+		Token:           sessionName,
+		Expiration:      ttime.Now().Add(source.validFor),
+	}, nil
+}
+
+func TestManagerCachesAndRefreshes(t *testing.T) {
+	testTime := ttime.NewTestTime()
+	ttime.SetTime(testTime)
+	defer ttime.SetTime(&ttime.DefaultTime{})
+
+	source := &localSource{validFor: time.Hour}
+	manager := NewManager(source)
+	taskArn := "arn:aws:ecs:us-west-2:123456789012:task/a1b2c3"
+	if err := manager.Ensure("id1", taskArn, "arn:role"); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.Ensure("id1", taskArn, "arn:role"); err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 1 {
+		t.Error("Expected cached credentials to be reused", source.calls)
+	}
+
+	creds, ok := manager.Get("id1")
+	if !ok || creds.AccessKeyId != "AKID1" || creds.RoleArn != "arn:role" || creds.Token != "ecs-task-a1b2c3" {
+		t.Error("Unexpected credentials", creds)
+	}
+	if _, ok := manager.Get("id2"); ok {
+		t.Error("Expected no credentials for an unknown id")
+	}
+
+	testTime.Warp(50 * time.Minute)
+	manager.refreshExpiring()
+	creds, _ = manager.Get("id1")
+	if source.calls != 2 || creds.AccessKeyId != "AKID2" {
+		t.Error("Expected credentials to be refreshed before they expire", creds)
+	}
+
+	manager.Remove("id1")
+	if _, ok := manager.Get("id1"); ok {
+		t.Error("Expected credentials to be removed")
+	}
+}
+
+func TestManagerKeepsCredentialsWhenRefreshFails(t *testing.T) {
+	testTime := ttime.NewTestTime()
+	ttime.SetTime(testTime)
+	defer ttime.SetTime(&ttime.DefaultTime{})
+
+	source := &localSource{validFor: time.Hour}
+	manager := NewManager(source)
+	manager.Ensure("id1", "task", "arn:role")
+
+	source.err = errors.New("throttled")
+	testTime.Warp(50 * time.Minute)
+	manager.refreshExpiring()
+	if _, ok := manager.Get("id1"); !ok {
+		t.Error("Expected old credentials to be served until they expire")
+	}
+
+	testTime.Warp(11 * time.Minute)
+	if _, ok := manager.Get("id1"); ok {
+		t.Error("Expected expired credentials not to be served")
+	}
+}
+
+// blockingSource hands out credentials once it is released, so that tests
+// can act while a fetch is in flight
+type blockingSource struct {
+	called  chan struct{}
+	release chan struct{}
+}
+
+func (source *blockingSource) AssumeRole(roleArn, sessionName string) (*TaskCredentials, error) {
+	source.called <- struct{}{}
+	<-source.release
+	return &TaskCredentials{AccessKeyId: "AKID", Expiration: ttime.Now().Add(time.Hour)}, nil
+}
+
+func TestManagerDiscardsFetchForRemovedTask(t *testing.T) {
+	source := &blockingSource{called: make(chan struct{}), release: make(chan struct{})}
+	manager := NewManager(source)
+	manager.entries["id1"] = &entry{
+		taskArn:     "arn:task",
+		roleArn:     "arn:role",
+		credentials: &TaskCredentials{AccessKeyId: "OLD", Expiration: ttime.Now().Add(time.Minute)},
+	}
+
+	for _, fetch := range []func(){
+		manager.refreshExpiring,
+		func() { manager.Ensure("id2", "arn:task2", "arn:role") },
+	} {
+		done := make(chan struct{})
+		go func() {
+			fetch()
+			close(done)
+		}()
+		<-source.called
+		// The task stops while its credentials are being fetched
+		manager.Remove("id1")
+		manager.Remove("id2")
+		close(source.release)
+		<-done
+		source.release = make(chan struct{})
+	}
+
+	for _, id := range []string{"id1", "id2"} {
+		if creds, ok := manager.Get(id); ok {
+			t.Error("Expected no credentials after the task was removed", id, creds)
+		}
+	}
+	if len(manager.fetching) != 0 || len(manager.removed) != 0 {
+		t.Error("Expected fetches to be forgotten", manager.fetching, manager.removed)
+	}
+}
+
+func TestSTSSourceAssumeRole(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("Action") != "AssumeRole" || r.URL.Query().Get("RoleArn") != "arn:role" {
+			t.Error("Unexpected request", r.URL)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			t.Error("Expected a signed request")
+		}
+		w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+			<AccessKeyId>AKID</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+			<SessionToken>token</SessionToken><Expiration>2015-03-01T12:00:00Z</Expiration>
+			</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+	}))
+	defer server.Close()
+
+	source := NewSTSSource(server.URL, "us-east-1", &credentials.AWSCredentials{AccessKey: "a", SecretKey: "b"})
+	creds, err := source.AssumeRole("arn:role", "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyId != "AKID" || creds.Token != "token" || creds.Expiration.Hour() != 12 {
+		t.Error("Unexpected credentials", creds)
+	}
+}
+
+func TestSTSSourceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+		w.Write([]byte(`<ErrorResponse><Error><Code>AccessDenied</Code><Message>not trusted</Message></Error></ErrorResponse>`))
+	}))
+	defer server.Close()
+
+	source := NewSTSSource(server.URL, "us-east-1", &credentials.AWSCredentials{AccessKey: "a", SecretKey: "b"})
+	_, err := source.AssumeRole("arn:role", "session")
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Error("Expected the STS error", err)
+	}
+}
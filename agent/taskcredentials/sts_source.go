@@ -0,0 +1,121 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package taskcredentials
+
+import (
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4"
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+)
+
+const (
+	// DefaultSTSEndpoint is the global STS endpoint
+	DefaultSTSEndpoint = "https://sts.amazonaws.com"
+	// defaultSTSRegion is the region requests to the global endpoint are
+	// signed for
+	defaultSTSRegion = "us-east-1"
+
+	stsAPIVersion = "2011-06-15"
+	stsTimeout    = 30 * time.Second
+)
+
+// STSSource assumes roles with the STS AssumeRole API, signing its requests
+// with the agent's own credentials
+type STSSource struct {
+	endpoint string
+	signer   authv4.HttpSigner
+	client   *http.Client
+}
+
+// NewSTSSource creates an STSSource which calls the given endpoint, signing
+// requests for region with creds
+func NewSTSSource(endpoint, region string, creds credentials.AWSCredentialProvider) *STSSource {
+	return &STSSource{
+		endpoint: endpoint,
+		signer:   authv4.NewHttpSigner(region, "sts", creds, nil),
+		client:   &http.Client{Timeout: stsTimeout},
+	}
+}
+
+// NewDefaultSTSSource creates an STSSource which calls the global endpoint
+func NewDefaultSTSSource(creds credentials.AWSCredentialProvider) *STSSource {
+	return NewSTSSource(DefaultSTSEndpoint, defaultSTSRegion, creds)
+}
+
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyId     string
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      time.Time
+	} `xml:"AssumeRoleResult>Credentials"`
+}
+
+type stsErrorResponse struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+func (source *STSSource) AssumeRole(roleArn, sessionName string) (*TaskCredentials, error) {
+	query := url.Values{}
+	query.Set("Action", "AssumeRole")
+	query.Set("Version", stsAPIVersion)
+	query.Set("RoleArn", roleArn)
+	query.Set("RoleSessionName", sessionName)
+	req, err := http.NewRequest("GET", source.endpoint+"/?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := source.signer.SignHttpRequest(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := source.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var stsErr stsErrorResponse
+		if xml.Unmarshal(body, &stsErr) == nil && stsErr.Code != "" {
+			return nil, errors.New("AssumeRole failed: " + stsErr.Code + ": " + stsErr.Message)
+		}
+		return nil, errors.New("AssumeRole failed: " + resp.Status)
+	}
+
+	var result assumeRoleResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	if result.Credentials.AccessKeyId == "" {
+		return nil, errors.New("AssumeRole returned no credentials")
+	}
+	return &TaskCredentials{
+		AccessKeyId:     result.Credentials.AccessKeyId,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		Token:           result.Credentials.SessionToken,
+		Expiration:      result.Credentials.Expiration,
+	}, nil
+}
@@ -23,6 +23,7 @@ import (
 	"reflect"
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
+	wsclient "github.com/aws/amazon-ecs-agent/agent/websocket/client"
 )
 
 var tcsTypeMappings map[string]reflect.Type
@@ -58,9 +59,21 @@ func init() {
 // typeMappings implements wsclient.TypeMappings.
 type typeMappings struct{}
 
+// NewTypeMappings returns the wsclient.TypeMappings of the messages
+// exchanged with TCS
+func NewTypeMappings() wsclient.TypeMappings {
+	return &typeMappings{}
+}
+
 // decoder implments wsclient.TypeDecoder.
 type decoder struct{}
 
+// NewDecoder returns a wsclient.TypeDecoder of the messages exchanged with
+// TCS
+func NewDecoder() wsclient.TypeDecoder {
+	return &decoder{}
+}
+
 func (dc *decoder) NewOfType(tcsType string) (interface{}, bool) {
 	rtype, ok := tcsTypeMappings[tcsType]
 	if !ok {
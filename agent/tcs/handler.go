@@ -29,6 +29,7 @@ import (
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/config"
 	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+	"github.com/aws/amazon-ecs-agent/agent/metrics"
 	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/utils"
 )
@@ -42,6 +43,13 @@ const (
 
 var endpoint string
 
+var (
+	tcsConnected = metrics.NewGauge("ecs_agent_tcs_connected",
+		"Whether the agent is connected to the telemetry backend.")
+	tcsReconnects = metrics.NewCounter("ecs_agent_tcs_reconnects_total",
+		"Connections to the telemetry backend made after the first.")
+)
+
 // sessionWrapper encapsulates the parameters required to start a session
 // with the backend. It defines the startSession method for this purpose.
 type sessionWrapper struct {
@@ -50,6 +58,8 @@ type sessionWrapper struct {
 	region             string
 	statsEngine        stats.Engine
 	url                string
+	// hasConnected is set once a session has been established
+	hasConnected bool
 }
 
 // StartSession creates a session with the backend and handles requests
@@ -78,6 +88,12 @@ func (sw *sessionWrapper) startSession() error {
 	if err != nil {
 		return err
 	}
+	if sw.hasConnected {
+		tcsReconnects.Inc()
+	}
+	sw.hasConnected = true
+	tcsConnected.Set(1)
+	defer tcsConnected.Set(0)
 
 	return client.Serve()
 }
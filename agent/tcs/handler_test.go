@@ -27,6 +27,7 @@ import (
 
 	"github.com/aws/amazon-ecs-agent/agent/acs/model/ecstcs"
 	"github.com/aws/amazon-ecs-agent/agent/auth"
+	"github.com/aws/amazon-ecs-agent/agent/stats"
 	"github.com/aws/amazon-ecs-agent/agent/websocket/client"
 	"github.com/aws/amazon-ecs-agent/agent/websocket/client/mock/utils"
 )
@@ -117,3 +118,7 @@ func (engine *mockStatsEngine) GetInstanceMetrics() (*ecstcs.MetricsMetadata, []
 	req := createPublishMetricsRequest()
 	return req.Metadata, req.TaskMetrics, nil
 }
+
+func (engine *mockStatsEngine) GetTaskUsage(taskArn string, numSamples int) []stats.TaskUsage {
+	return nil
+}
@@ -43,7 +43,7 @@ func ZeroOrNil(obj interface{}) bool {
 	if obj == nil {
 		return true
 	}
-	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array || value.Kind() == reflect.Map {
 		return value.Len() == 0
 	}
 	zero := reflect.Zero(reflect.TypeOf(obj))
@@ -77,6 +77,17 @@ func TestZeroOrNil(t *testing.T) {
 		t.Error("[] is Zero")
 	}
 
+	var nilMap map[string]string
+	if !ZeroOrNil(nilMap) {
+		t.Error("A nil map is zero")
+	}
+	if !ZeroOrNil(map[string]string{}) {
+		t.Error("An empty map is zero")
+	}
+	if ZeroOrNil(map[string]string{"foo": "bar"}) {
+		t.Error("A populated map is not zero")
+	}
+
 }
 
 func TestSlicesDeepEqual(t *testing.T) {
@@ -140,6 +140,9 @@ func (cs *ClientServerImpl) Connect() error {
 		return err
 	}
 
+	// The signer adds the Host header it signed, but the websocket client
+	// always writes its own; servers reject requests with both
+	request.Header.Del("Host")
 	websocketConn, httpResponse, err := websocket.NewClient(wsConn, parsedURL, request.Header, readBufSize, writeBufSize)
 	if httpResponse != nil {
 		defer httpResponse.Body.Close()
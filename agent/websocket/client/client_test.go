@@ -0,0 +1,71 @@
+// Copyright 2014-2015 Amazon.com, Inc. or its affiliates. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License"). You may
+// not use this file except in compliance with the License. A copy of the
+// License is located at
+//
+//	http://aws.amazon.com/apache2.0/
+//
+// or in the "license" file accompanying this file. This file is distributed
+// on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing
+// permissions and limitations under the License.
+
+package wsclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/amazon-ecs-agent/agent/ecs_client/authv4/credentials"
+	"github.com/gorilla/websocket"
+)
+
+// TestConnectSendsSingleHostHeader makes sure the Host header added by the
+// signer doesn't end up on the wire next to the one the websocket client
+// writes; net/http rejects requests carrying both.
+func TestConnectSendsSingleHostHeader(t *testing.T) {
+	type request struct {
+		host          string
+		authorization string
+	}
+	requests := make(chan request, 1)
+	upgrader := websocket.Upgrader{ReadBufferSize: readBufSize, WriteBufferSize: writeBufSize}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- request{r.Host, r.Header.Get("Authorization")}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	cs := &ClientServerImpl{
+		AcceptInvalidCert:  true,
+		CredentialProvider: credentials.NewCredentialProvider("AKIDEXAMPLE", "SECRET"),
+		Region:             "us-east-1",
+		URL:                server.URL + "/ws",
+	}
+	if err := cs.Connect(); err != nil {
+		t.Fatal("Expected to connect, got: ", err)
+	}
+	defer cs.Conn.Close()
+
+	var received request
+	select {
+	case received = <-requests:
+	default:
+		t.Fatal("Server never saw the request")
+	}
+	serverURL, _ := url.Parse(server.URL)
+	if received.host != serverURL.Host {
+		t.Errorf("Expected Host %s, got %s", serverURL.Host, received.host)
+	}
+	if !strings.Contains(received.authorization, "SignedHeaders=host") {
+		t.Errorf("Expected the Host header to be signed, got Authorization: %s", received.authorization)
+	}
+}